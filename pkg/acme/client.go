@@ -0,0 +1,198 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+const (
+	acmeChallengeHTTP01 = "http-01"
+	acmeSignTimeout     = 5 * time.Minute
+)
+
+// ClientResolver ...
+type ClientResolver interface {
+	GetKey() (crypto.Signer, error)
+	SetToken(domain string, uri, token string) error
+}
+
+// Client ...
+type Client interface {
+	Sign(dnsnames []string) (crt, key []byte, err error)
+}
+
+// NewClient ...
+func NewClient(logger types.Logger, resolver ClientResolver, endpoint, emails string, termsAgreed bool) (Client, error) {
+	key, err := resolver.GetKey()
+	if err != nil {
+		return nil, err
+	}
+	var contact []string
+	for _, email := range strings.Split(emails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			contact = append(contact, "mailto:"+email)
+		}
+	}
+	c := &client{
+		logger:   logger,
+		resolver: resolver,
+		client: &acme.Client{
+			Key:          key,
+			DirectoryURL: directoryURL(endpoint),
+		},
+		contact:     contact,
+		termsAgreed: termsAgreed,
+	}
+	if err := c.ensureAccount(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func directoryURL(endpoint string) string {
+	switch endpoint {
+	case "v2", "v02":
+		return "https://acme-v02.api.letsencrypt.org/directory"
+	case "v2-staging", "v02-staging":
+		return "https://acme-staging-v02.api.letsencrypt.org/directory"
+	}
+	return endpoint
+}
+
+type client struct {
+	logger      types.Logger
+	resolver    ClientResolver
+	client      *acme.Client
+	contact     []string
+	termsAgreed bool
+}
+
+func (c *client) ensureAccount() error {
+	ctx, cancel := context.WithTimeout(context.Background(), acmeSignTimeout)
+	defer cancel()
+	account := &acme.Account{Contact: c.contact}
+	_, err := c.client.Register(ctx, account, func(tosURL string) bool {
+		return c.termsAgreed
+	})
+	if err == acme.ErrAccountAlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error registering acme account: %v", err)
+	}
+	c.logger.Info("acme: new account registered: %s", strings.Join(c.contact, ","))
+	return nil
+}
+
+func (c *client) Sign(dnsnames []string) (crt, key []byte, err error) {
+	if len(dnsnames) == 0 {
+		return nil, nil, fmt.Errorf("dnsnames is empty")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), acmeSignTimeout)
+	defer cancel()
+	order, err := c.client.AuthorizeOrder(ctx, acme.DomainIDs(dnsnames...))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := c.authorize(ctx, authzURL); err != nil {
+			return nil, nil, err
+		}
+	}
+	order, err = c.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	crtKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: dnsnames[0]},
+		DNSNames: dnsnames,
+	}, crtKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, _, err := c.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, block := range der {
+		crt = append(crt, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: block})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(crtKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	key = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return crt, key, nil
+}
+
+func (c *client) authorize(ctx context.Context, authzURL string) error {
+	authz, err := c.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	var challenge *acme.Challenge
+	for _, ch := range authz.Challenges {
+		if ch.Type == acmeChallengeHTTP01 {
+			challenge = ch
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("acme server does not provide %s challenge for '%s'", acmeChallengeHTTP01, authz.Identifier.Value)
+	}
+	domain := authz.Identifier.Value
+	uri := c.client.HTTP01ChallengePath(challenge.Token)
+	token, err := c.client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	if err := c.resolver.SetToken(domain, uri, token); err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.resolver.SetToken(domain, uri, ""); err != nil {
+			c.logger.Warn("acme: error removing token of '%s': %v", domain, err)
+		}
+	}()
+	if _, err := c.client.Accept(ctx, challenge); err != nil {
+		return err
+	}
+	_, err = c.client.WaitAuthorization(ctx, authz.URI)
+	return err
+}
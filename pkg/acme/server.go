@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// ServerResolver ...
+type ServerResolver interface {
+	GetToken(domain, uri string) string
+}
+
+// Server ...
+type Server interface {
+	Listen(stopCh chan struct{}) error
+}
+
+// NewServer ...
+func NewServer(logger types.Logger, socket string, resolver ServerResolver) Server {
+	return &server{
+		logger:   logger,
+		socket:   socket,
+		resolver: resolver,
+	}
+}
+
+type server struct {
+	logger   types.Logger
+	socket   string
+	resolver ServerResolver
+	server   *http.Server
+}
+
+func (s *server) Listen(stopCh chan struct{}) error {
+	s.logger.Info("acme: starting challenge server on %s", s.socket)
+	s.server = &http.Server{Handler: s}
+	if err := os.Remove(s.socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.server.Serve(l); err != http.ErrServerClosed {
+			s.logger.Error("acme: error listening challenge server: %v", err)
+		}
+	}()
+	go func() {
+		<-stopCh
+		s.logger.Info("acme: closing challenge server")
+		s.server.Shutdown(context.Background())
+	}()
+	return nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := strings.Split(r.Host, ":")[0]
+	token := s.resolver.GetToken(host, r.URL.Path)
+	if token == "" {
+		s.logger.Warn("acme: token not found for domain '%s' and uri '%s'", host, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.logger.InfoV(2, "acme: responding token for domain '%s'", host)
+	w.Write([]byte(token))
+}
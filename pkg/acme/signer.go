@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// SignerResolver ...
+type SignerResolver interface {
+	GetTLSSecretContent(secretName string) *TLSSecret
+	SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error
+}
+
+// TLSSecret ...
+type TLSSecret struct {
+	Crt *x509.Certificate
+}
+
+// Cache ...
+type Cache interface {
+	ClientResolver
+	SignerResolver
+}
+
+// Signer ...
+type Signer interface {
+	AcmeAccount(endpoint, emails string, termsAgreed bool)
+	AcmeConfig(expiring time.Duration)
+	HasAccount() bool
+	Notify(item interface{}) error
+}
+
+// NewSigner ...
+func NewSigner(logger types.Logger, cache Cache) Signer {
+	return &signer{
+		logger:    logger,
+		cache:     cache,
+		newClient: NewClient,
+		now:       time.Now,
+	}
+}
+
+type signer struct {
+	logger    types.Logger
+	cache     Cache
+	newClient func(logger types.Logger, resolver ClientResolver, endpoint, emails string, termsAgreed bool) (Client, error)
+	now       func() time.Time
+	client    Client
+	account   account
+	expiring  time.Duration
+}
+
+type account struct {
+	endpoint    string
+	emails      string
+	termsAgreed bool
+}
+
+func (s *signer) AcmeAccount(endpoint, emails string, termsAgreed bool) {
+	newAccount := account{
+		endpoint:    endpoint,
+		emails:      emails,
+		termsAgreed: termsAgreed,
+	}
+	if newAccount == s.account && s.client != nil {
+		return
+	}
+	s.account = newAccount
+	s.client = nil
+	if endpoint == "" {
+		return
+	}
+	client, err := s.newClient(s.logger, s.cache, endpoint, emails, termsAgreed)
+	if err != nil {
+		s.logger.Error("acme: error creating the acme client: %v", err)
+		return
+	}
+	s.client = client
+}
+
+func (s *signer) AcmeConfig(expiring time.Duration) {
+	s.expiring = expiring
+}
+
+func (s *signer) HasAccount() bool {
+	return s.client != nil
+}
+
+func (s *signer) Notify(item interface{}) error {
+	if s.client == nil {
+		return fmt.Errorf("acme client wasn't properly configured")
+	}
+	cert := strings.Split(item.(string), ",")
+	if len(cert) < 2 {
+		return fmt.Errorf("invalid certificate item: %v", item)
+	}
+	name := cert[0]
+	domains := cert[1:]
+	reason := s.verify(name, domains)
+	if reason == "" {
+		s.logger.InfoV(2, "acme: skipping sign, certificate '%s' is valid and up to date", name)
+		return nil
+	}
+	s.logger.Info("acme: authorizing: id=%s secret=%s domain(s)=%s reason='%s'",
+		s.account.endpoint, name, strings.Join(domains, ","), reason)
+	crt, key, err := s.client.Sign(domains)
+	if err != nil {
+		s.logger.Warn("acme: error signing new certificate: id=%s secret=%s domain(s)=%s error=%v",
+			s.account.endpoint, name, strings.Join(domains, ","), err)
+		return err
+	}
+	if err := s.cache.SetTLSSecretContent(name, crt, key); err != nil {
+		s.logger.Warn("acme: error storing new certificate: secret=%s error=%v", name, err)
+		return err
+	}
+	s.logger.Info("acme: new certificate issued: secret=%s domain(s)=%s", name, strings.Join(domains, ","))
+	return nil
+}
+
+// verify returns a human readable reason why a certificate
+// should be (re)issued, or an empty string if it is up to date
+func (s *signer) verify(name string, domains []string) string {
+	secret := s.cache.GetTLSSecretContent(name)
+	if secret == nil || secret.Crt == nil {
+		return "certificate does not exist"
+	}
+	crt := secret.Crt
+	for _, domain := range domains {
+		if err := crt.VerifyHostname(domain); err != nil {
+			return fmt.Sprintf("domain '%s' is not covered by the certificate", domain)
+		}
+	}
+	expiresIn := crt.NotAfter.Sub(s.now())
+	if expiresIn < s.expiring {
+		return fmt.Sprintf("certificate expires in %s", crt.NotAfter.String())
+	}
+	return ""
+}
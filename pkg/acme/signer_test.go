@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"crypto"
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestNotify(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expiring time.Duration
+		cert     string
+		logging  string
+	}{
+		// 0
+		{
+			input: "default/s1,d1.local",
+			logging: `
+INFO acme: authorizing: id=https://acme.local/dir secret=default/s1 domain(s)=d1.local reason='certificate does not exist'
+INFO acme: new certificate issued: secret=default/s1 domain(s)=d1.local`,
+		},
+		// 1
+		{
+			input:    "default/s1,d1.local",
+			expiring: 10 * 24 * time.Hour,
+			cert:     "d1.local",
+			logging: `
+INFO-V(2) acme: skipping sign, certificate 'default/s1' is valid and up to date`,
+		},
+		// 2
+		{
+			input:    "default/s1,d1.local,d2.local",
+			expiring: 10 * 24 * time.Hour,
+			cert:     "d1.local",
+			logging: `
+INFO acme: authorizing: id=https://acme.local/dir secret=default/s1 domain(s)=d1.local,d2.local reason='domain 'd2.local' is not covered by the certificate'
+INFO acme: new certificate issued: secret=default/s1 domain(s)=d1.local,d2.local`,
+		},
+		// 3
+		{
+			input:    "default/s1,d1.local",
+			expiring: 90 * 24 * time.Hour,
+			cert:     "d1.local",
+			logging: `
+INFO acme: authorizing: id=https://acme.local/dir secret=default/s1 domain(s)=d1.local reason='certificate expires in 2000-01-31 00:00:00 +0000 UTC'
+INFO acme: new certificate issued: secret=default/s1 domain(s)=d1.local`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		if test.cert != "" {
+			c.cache.secrets["default/s1"] = &TLSSecret{
+				Crt: &x509.Certificate{
+					DNSNames: strings.Split(test.cert, ","),
+					NotAfter: c.now.Add(30 * 24 * time.Hour),
+				},
+			}
+		}
+		signer := c.newSigner()
+		signer.AcmeConfig(test.expiring)
+		if err := signer.Notify(test.input); err != nil {
+			t.Errorf("unexpected error on %d: %v", i, err)
+		}
+		c.logger.CompareLogging(test.logging)
+	}
+}
+
+type testConfig struct {
+	t      *testing.T
+	now    time.Time
+	logger *types_helper.LoggerMock
+	cache  *cache
+}
+
+func setup(t *testing.T) *testConfig {
+	return &testConfig{
+		t:      t,
+		now:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		logger: types_helper.NewLoggerMock(t),
+		cache: &cache{
+			secrets: map[string]*TLSSecret{},
+		},
+	}
+}
+
+func (c *testConfig) newSigner() *signer {
+	s := NewSigner(c.logger, c.cache).(*signer)
+	s.now = func() time.Time { return c.now }
+	s.newClient = func(logger types.Logger, resolver ClientResolver, endpoint, emails string, termsAgreed bool) (Client, error) {
+		return &clientMock{}, nil
+	}
+	s.AcmeAccount("https://acme.local/dir", "admin@acme.local", true)
+	return s
+}
+
+type clientMock struct{}
+
+func (c *clientMock) Sign(dnsnames []string) (crt, key []byte, err error) {
+	return []byte("crt"), []byte("key"), nil
+}
+
+type cache struct {
+	secrets map[string]*TLSSecret
+}
+
+func (c *cache) GetKey() (crypto.Signer, error) {
+	return nil, nil
+}
+
+func (c *cache) SetToken(domain string, uri, token string) error {
+	return nil
+}
+
+func (c *cache) GetTLSSecretContent(secretName string) *TLSSecret {
+	return c.secrets[secretName]
+}
+
+func (c *cache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error {
+	return nil
+}
@@ -153,6 +153,9 @@ type Configuration struct {
 
 	SortBackends bool
 
+	WatchHPA         bool
+	WatchReplicaSets bool
+
 	V07 bool
 }
 
@@ -1622,8 +1625,13 @@ func (ic *GenericController) SetForceReload(shouldReload bool) {
 	}
 }
 
+// Notify starts a new sync of the ingress objects
+func (ic *GenericController) Notify() {
+	ic.syncQueue.Enqueue(&networking.Ingress{})
+}
+
 // CreateDefaultSSLCertificate ...
-func (ic *GenericController) CreateDefaultSSLCertificate() (path, hash string) {
+func (ic *GenericController) CreateDefaultSSLCertificate() *ingress.SSLCert {
 	defCert, defKey := ssl.GetFakeSSLCert(
 		[]string{"Acme Co"}, "Kubernetes Ingress Controller Fake Certificate", []string{"ingress.local"},
 	)
@@ -1635,5 +1643,5 @@ func (ic *GenericController) CreateDefaultSSLCertificate() (path, hash string) {
 	fakeCertificateSHA = c.PemSHA
 	fakeCertificatePath = c.PemFileName
 
-	return fakeCertificatePath, fakeCertificateSHA
+	return c
 }
@@ -127,6 +127,12 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		useNodeInternalIP = flags.Bool("report-node-internal-ip-address", false,
 			`Defines if the nodes IP address to be returned in the ingress status should be the internal instead of the external IP address`)
 
+		watchHPA = flags.Bool("watch-hpa", false,
+			`Defines if HorizontalPodAutoscalers should be watched, needed by slots-from-hpa`)
+
+		watchReplicaSets = flags.Bool("watch-replicasets", false,
+			`Defines if ReplicaSets should be watched, needed by the current and previous revision groups of blue-green-balance`)
+
 		v07 = flags.Bool("v07-controller", false,
 			`Defines if legacy v07 controller code should be used`)
 
@@ -272,6 +278,8 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		UpdateStatusOnShutdown:  *updateStatusOnShutdown,
 		SortBackends:            *sortBackends,
 		UseNodeInternalIP:       *useNodeInternalIP,
+		WatchHPA:                *watchHPA,
+		WatchReplicaSets:        *watchReplicaSets,
 		V07:                     *v07,
 	}
 
@@ -22,7 +22,10 @@ import (
 
 	"github.com/golang/glog"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v1"
 	apiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/runtime"
@@ -32,36 +35,46 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/annotations/class"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/annotations/parser"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/store"
 )
 
 type cacheController struct {
-	Ingress   cache.Controller
-	Endpoint  cache.Controller
-	Service   cache.Controller
-	Node      cache.Controller
-	Secret    cache.Controller
-	Configmap cache.Controller
-	Pod       cache.Controller
+	Ingress       cache.Controller
+	Endpoint      cache.Controller
+	EndpointSlice cache.Controller
+	Service       cache.Controller
+	Node          cache.Controller
+	Secret        cache.Controller
+	Configmap     cache.Controller
+	Pod           cache.Controller
+	HPA           cache.Controller
+	ReplicaSet    cache.Controller
 }
 
 func (c *cacheController) Run(stopCh chan struct{}) {
 	go c.Ingress.Run(stopCh)
 	go c.Endpoint.Run(stopCh)
+	go c.EndpointSlice.Run(stopCh)
 	go c.Service.Run(stopCh)
 	go c.Node.Run(stopCh)
 	go c.Secret.Run(stopCh)
 	go c.Configmap.Run(stopCh)
 	go c.Pod.Run(stopCh)
+	go c.HPA.Run(stopCh)
+	go c.ReplicaSet.Run(stopCh)
 
 	// Wait for all involved caches to be synced, before processing items from the queue is started
 	if !cache.WaitForCacheSync(stopCh,
 		c.Ingress.HasSynced,
 		c.Endpoint.HasSynced,
+		c.EndpointSlice.HasSynced,
 		c.Service.HasSynced,
 		c.Node.HasSynced,
 		c.Secret.HasSynced,
 		c.Configmap.HasSynced,
 		c.Pod.HasSynced,
+		c.HPA.HasSynced,
+		c.ReplicaSet.HasSynced,
 	) {
 		runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 	}
@@ -132,6 +145,11 @@ func (ic *GenericController) createListers(disableNodeLister bool) (*ingress.Sto
 				sec := cur.(*apiv1.Secret)
 				key := fmt.Sprintf("%v/%v", sec.Namespace, sec.Name)
 				ic.syncSecret(key)
+				if _, found := sec.Data[ingress.CRLFilename]; found {
+					// CRLs aren't tracked by syncSecret, which doesn't enqueue
+					// an update if only the CRL of a CA secret was changed
+					ic.syncQueue.Enqueue(sec)
+				}
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -169,20 +187,43 @@ func (ic *GenericController) createListers(disableNodeLister bool) (*ingress.Sto
 		},
 	}
 
-	endpointEventHandler := cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			ic.syncQueue.Enqueue(obj)
-		},
-		DeleteFunc: func(obj interface{}) {
-			ic.syncQueue.Enqueue(obj)
-		},
-		UpdateFunc: func(old, cur interface{}) {
-			oep := old.(*apiv1.Endpoints)
-			ocur := cur.(*apiv1.Endpoints)
-			if !reflect.DeepEqual(ocur.Subsets, oep.Subsets) {
-				ic.syncQueue.Enqueue(cur)
-			}
-		},
+	// v0.7 reads core Endpoints and v0.8 reads EndpointSlices, only
+	// changes of the objects read by the running controller should
+	// start a new sync
+	endpointEventHandler := cache.ResourceEventHandlerFuncs{}
+	endpointSliceEventHandler := cache.ResourceEventHandlerFuncs{}
+	if ic.cfg.V07 {
+		endpointEventHandler = cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				ic.syncQueue.Enqueue(obj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				ic.syncQueue.Enqueue(obj)
+			},
+			UpdateFunc: func(old, cur interface{}) {
+				oep := old.(*apiv1.Endpoints)
+				ocur := cur.(*apiv1.Endpoints)
+				if !reflect.DeepEqual(ocur.Subsets, oep.Subsets) {
+					ic.syncQueue.Enqueue(cur)
+				}
+			},
+		}
+	} else {
+		endpointSliceEventHandler = cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				ic.syncQueue.Enqueue(obj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				ic.syncQueue.Enqueue(obj)
+			},
+			UpdateFunc: func(old, cur interface{}) {
+				oslice := old.(*discovery.EndpointSlice)
+				cslice := cur.(*discovery.EndpointSlice)
+				if !reflect.DeepEqual(oslice.Endpoints, cslice.Endpoints) || !reflect.DeepEqual(oslice.Ports, cslice.Ports) {
+					ic.syncQueue.Enqueue(cur)
+				}
+			},
+		}
 	}
 
 	mapEventHandler := cache.ResourceEventHandlerFuncs{
@@ -213,6 +254,9 @@ func (ic *GenericController) createListers(disableNodeLister bool) (*ingress.Sto
 		},
 	}
 
+	// pods can declare the weight of its endpoint, other annotations are
+	// frequently changed by sidecar injectors and rollout tools
+	podWeightAnn := ic.cfg.AnnPrefix + "/weight"
 	podEventHandler := cache.ResourceEventHandlerFuncs{
 		DeleteFunc: func(obj interface{}) {
 			ic.syncQueue.Enqueue(obj)
@@ -222,6 +266,25 @@ func (ic *GenericController) createListers(disableNodeLister bool) (*ingress.Sto
 			newPod := cur.(*apiv1.Pod)
 			if oldPod.DeletionTimestamp != newPod.DeletionTimestamp {
 				ic.syncQueue.Enqueue(cur)
+			} else if oldPod.Annotations[podWeightAnn] != newPod.Annotations[podWeightAnn] {
+				ic.syncQueue.Enqueue(cur)
+			}
+		},
+	}
+
+	hpaEventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ic.syncQueue.Enqueue(obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			ic.syncQueue.Enqueue(obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldHPA := old.(*autoscaling.HorizontalPodAutoscaler)
+			curHPA := cur.(*autoscaling.HorizontalPodAutoscaler)
+			// status changes on every scale, only the spec has the slots size
+			if !reflect.DeepEqual(oldHPA.Spec, curHPA.Spec) {
+				ic.syncQueue.Enqueue(cur)
 			}
 		},
 	}
@@ -243,6 +306,10 @@ func (ic *GenericController) createListers(disableNodeLister bool) (*ingress.Sto
 		cache.NewListWatchFromClient(ic.cfg.Client.CoreV1().RESTClient(), "endpoints", watchNs, fields.Everything()),
 		&apiv1.Endpoints{}, ic.cfg.ResyncPeriod, endpointEventHandler)
 
+	lister.EndpointSlice.Indexer, controller.EndpointSlice = cache.NewIndexerInformer(
+		cache.NewListWatchFromClient(ic.cfg.Client.DiscoveryV1().RESTClient(), "endpointslices", watchNs, fields.Everything()),
+		&discovery.EndpointSlice{}, ic.cfg.ResyncPeriod, endpointSliceEventHandler, store.EndpointSliceIndexers)
+
 	lister.Secret.Store, controller.Secret = cache.NewInformer(
 		cache.NewListWatchFromClient(ic.cfg.Client.CoreV1().RESTClient(), "secrets", watchNs, fields.Everything()),
 		&apiv1.Secret{}, ic.cfg.ResyncPeriod, secrEventHandler)
@@ -259,6 +326,31 @@ func (ic *GenericController) createListers(disableNodeLister bool) (*ingress.Sto
 		cache.NewListWatchFromClient(ic.cfg.Client.CoreV1().RESTClient(), "pods", ic.cfg.Namespace, fields.Everything()),
 		&apiv1.Pod{}, ic.cfg.ResyncPeriod, podEventHandler)
 
+	// HPAs are only watched if asked to, deployments without slots-from-hpa
+	// don't need the RBAC rule
+	var hpaListerWatcher cache.ListerWatcher
+	if ic.cfg.WatchHPA {
+		hpaListerWatcher = cache.NewListWatchFromClient(ic.cfg.Client.AutoscalingV1().RESTClient(), "horizontalpodautoscalers", watchNs, fields.Everything())
+	} else {
+		hpaListerWatcher = fcache.NewFakeControllerSource()
+	}
+	lister.HPA.Indexer, controller.HPA = cache.NewIndexerInformer(
+		hpaListerWatcher,
+		&autoscaling.HorizontalPodAutoscaler{}, ic.cfg.ResyncPeriod, hpaEventHandler,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+
+	// ReplicaSets are only read, changes of their pods already start a new sync.
+	// They are only watched if asked to, as the HPAs
+	var rsListerWatcher cache.ListerWatcher
+	if ic.cfg.WatchReplicaSets {
+		rsListerWatcher = cache.NewListWatchFromClient(ic.cfg.Client.AppsV1().RESTClient(), "replicasets", watchNs, fields.Everything())
+	} else {
+		rsListerWatcher = fcache.NewFakeControllerSource()
+	}
+	lister.ReplicaSet.Store, controller.ReplicaSet = cache.NewInformer(
+		rsListerWatcher,
+		&appsv1.ReplicaSet{}, ic.cfg.ResyncPeriod, cache.ResourceEventHandlerFuncs{})
+
 	var nodeListerWatcher cache.ListerWatcher
 	if disableNodeLister {
 		nodeListerWatcher = fcache.NewFakeControllerSource()
@@ -18,8 +18,12 @@ package store
 
 import (
 	"fmt"
+	"sort"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v1"
 	apiv1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -101,6 +105,85 @@ func (s *EndpointLister) GetServiceEndpoints(svc *apiv1.Service) (ep apiv1.Endpo
 	return
 }
 
+// EndpointSliceServiceIndex indexes EndpointSlices by the namespace
+// and the name of their service, read from the service name label.
+const EndpointSliceServiceIndex = "service"
+
+// EndpointSliceIndexers ...
+var EndpointSliceIndexers = cache.Indexers{
+	EndpointSliceServiceIndex: func(obj interface{}) ([]string, error) {
+		slice, ok := obj.(*discovery.EndpointSlice)
+		if !ok {
+			return nil, fmt.Errorf("object is not an EndpointSlice: %T", obj)
+		}
+		svcName := slice.Labels[discovery.LabelServiceName]
+		if svcName == "" {
+			return nil, nil
+		}
+		return []string{slice.Namespace + "/" + svcName}, nil
+	},
+}
+
+// EndpointSliceLister makes an Indexer that lists EndpointSlices.
+type EndpointSliceLister struct {
+	cache.Indexer
+}
+
+// GetServiceEndpointSlices returns all the endpoint slices of a service, matched on
+// the service name label and sorted by name.
+func (s *EndpointSliceLister) GetServiceEndpointSlices(svc *apiv1.Service) ([]*discovery.EndpointSlice, error) {
+	objs, err := s.Indexer.ByIndex(EndpointSliceServiceIndex, svc.Namespace+"/"+svc.Name)
+	if err != nil {
+		return nil, err
+	}
+	slices := make([]*discovery.EndpointSlice, len(objs))
+	for i, obj := range objs {
+		slices[i] = obj.(*discovery.EndpointSlice)
+	}
+	if len(slices) == 0 {
+		return nil, fmt.Errorf("could not find endpoint slices for service: %v", svc.Name)
+	}
+	sort.Slice(slices, func(i, j int) bool {
+		return slices[i].Name < slices[j].Name
+	})
+	return slices, nil
+}
+
+// HPALister makes an Indexer that lists HorizontalPodAutoscalers.
+type HPALister struct {
+	cache.Indexer
+}
+
+// GetNamespaceHPAs returns the HorizontalPodAutoscalers of a namespace.
+func (s *HPALister) GetNamespaceHPAs(namespace string) ([]*autoscaling.HorizontalPodAutoscaler, error) {
+	objs, err := s.Indexer.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+	hpas := make([]*autoscaling.HorizontalPodAutoscaler, len(objs))
+	for i, obj := range objs {
+		hpas[i] = obj.(*autoscaling.HorizontalPodAutoscaler)
+	}
+	return hpas, nil
+}
+
+// ReplicaSetLister makes a Store that lists ReplicaSets.
+type ReplicaSetLister struct {
+	cache.Store
+}
+
+// GetReplicaSet returns the ReplicaSet with the provided namespace and name.
+func (s *ReplicaSetLister) GetReplicaSet(namespace, name string) (*appsv1.ReplicaSet, error) {
+	obj, exists, err := s.Store.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("could not find replicaset %v/%v", namespace, name)
+	}
+	return obj.(*appsv1.ReplicaSet), nil
+}
+
 // PodLister makes a store that lists Pods.
 type PodLister struct {
 	cache.Store
@@ -62,6 +62,9 @@ var (
 	DefaultCACertsDirectory = "/ingress-controller/cacerts"
 )
 
+// CRLFilename is the key of the certificate revocation list on CA secrets
+const CRLFilename = "ca.crl"
+
 // Controller holds the methods to handle an Ingress backend
 // TODO (#18): Make sure this is sufficiently supportive of other backends.
 type Controller interface {
@@ -126,15 +129,18 @@ type Controller interface {
 }
 
 // StoreLister returns the configured stores for ingresses, services,
-// endpoints, secrets and configmaps.
+// endpoints, endpoint slices, secrets and configmaps.
 type StoreLister struct {
-	Ingress   store.IngressLister
-	Service   store.ServiceLister
-	Node      store.NodeLister
-	Endpoint  store.EndpointLister
-	Secret    store.SecretLister
-	ConfigMap store.ConfigMapLister
-	Pod       store.PodLister
+	Ingress       store.IngressLister
+	Service       store.ServiceLister
+	Node          store.NodeLister
+	Endpoint      store.EndpointLister
+	EndpointSlice store.EndpointSliceLister
+	Secret        store.SecretLister
+	ConfigMap     store.ConfigMapLister
+	Pod           store.PodLister
+	HPA           store.HPALister
+	ReplicaSet    store.ReplicaSetLister
 }
 
 // BackendInfo returns information about the backend.
@@ -327,6 +327,46 @@ func AddOrUpdateDHParam(name string, dh []byte) (string, error) {
 	return pemFileName, nil
 }
 
+// AddOrUpdateCRL creates or updates a PEM file with the content of a
+// certificate revocation list
+func AddOrUpdateCRL(name string, crl []byte) (string, error) {
+	pemName := fmt.Sprintf("%v.pem", name)
+	pemFileName := fmt.Sprintf("%v/%v", ingress.DefaultSSLDirectory, pemName)
+
+	pemBlock, _ := pem.Decode(crl)
+	if pemBlock == nil {
+		return "", fmt.Errorf("no valid PEM formatted block found")
+	}
+	if pemBlock.Type != "X509 CRL" {
+		return "", fmt.Errorf("CRL %v contains invalid data", name)
+	}
+
+	tempPemFile, err := ioutil.TempFile(ingress.DefaultSSLDirectory, pemName)
+	if err != nil {
+		return "", fmt.Errorf("could not create temp pem file %v: %v", pemFileName, err)
+	}
+	glog.V(3).Infof("Creating temp file %v for CRL: %v", tempPemFile.Name(), pemName)
+
+	_, err = tempPemFile.Write(crl)
+	if err != nil {
+		_ = os.Remove(tempPemFile.Name())
+		return "", fmt.Errorf("could not write to pem file %v: %v", tempPemFile.Name(), err)
+	}
+
+	err = tempPemFile.Close()
+	if err != nil {
+		_ = os.Remove(tempPemFile.Name())
+		return "", fmt.Errorf("could not close temp pem file %v: %v", tempPemFile.Name(), err)
+	}
+
+	err = os.Rename(tempPemFile.Name(), pemFileName)
+	if err != nil {
+		return "", fmt.Errorf("could not move temp pem file %v to destination %v: %v", tempPemFile.Name(), pemFileName, err)
+	}
+
+	return pemFileName, nil
+}
+
 // GetFakeSSLCert creates a Self Signed Certificate
 // Based in the code https://golang.org/src/crypto/tls/generate_cert.go
 func GetFakeSSLCert(o []string, cn string, dns []string) (cert, key []byte) {
@@ -0,0 +1,129 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+const (
+	acmeSocket = "/var/run/acme.sock"
+)
+
+func (hc *HAProxyController) configAcme() {
+	podNamespace := os.Getenv("POD_NAMESPACE")
+	podName := os.Getenv("POD_NAME")
+	if podNamespace == "" || podName == "" {
+		hc.logger.Fatal("POD_NAME and POD_NAMESPACE envvars are required when --acme-server is enabled")
+	}
+	hc.cache.acmeSecretKeyName = *hc.acmeSecretKeyName
+	hc.cache.acmeTokenConfigmapName = *hc.acmeTokenConfigmapName
+	hc.acmeSigner = acme.NewSigner(hc.logger, hc.cache)
+	hc.acmeQueue = newFailureRateLimitingQueue(
+		*hc.acmeFailInitialDuration,
+		*hc.acmeFailMaxDuration,
+		hc.syncAcme,
+	)
+	electionID := *hc.acmeElectionID
+	if hc.cfg.IngressClass != "" {
+		electionID = electionID + "-" + hc.cfg.IngressClass
+	}
+	hc.leaderelector = newLeaderElector(electionID, hc.logger, hc.cfg.Client, podNamespace, podName, hc)
+	acmeServer := acme.NewServer(hc.logger, acmeSocket, hc.cache)
+	if err := acmeServer.Listen(hc.stopCh); err != nil {
+		hc.logger.Fatal("error creating the acme server listener: %v", err)
+	}
+	go hc.acmeQueue.Run()
+	go hc.leaderelector.Run(hc.stopCh)
+	go wait.Until(func() { hc.acmeCheck("periodic check") }, *hc.acmeCheckPeriod, hc.stopCh)
+}
+
+// acmeUpdate receives the acme data of the last synchronization and
+// enqueues certificates that weren't found in the former one.
+func (hc *HAProxyController) acmeUpdate(acmeData *hatypes.AcmeData) {
+	storages := acmeData.Storages().BuildAcmeStorages()
+	if acmeData.Endpoint == "" && len(storages) > 0 {
+		hc.logger.Warn("acme: ignoring %d certificate(s) signing request, acme-endpoint is not configured", len(storages))
+		storages = nil
+	}
+	hc.acmeMutex.Lock()
+	oldStorages := hc.acmeStorages
+	hc.acmeData = acmeData
+	hc.acmeStorages = storages
+	hc.acmeMutex.Unlock()
+	if !hc.leaderelector.IsLeader() {
+		return
+	}
+	added := make(map[string]bool, len(oldStorages))
+	for _, storage := range oldStorages {
+		added[storage] = true
+	}
+	for _, storage := range storages {
+		if !added[storage] {
+			hc.acmeQueue.Add(storage)
+		}
+	}
+}
+
+func (hc *HAProxyController) acmeCheck(source string) {
+	if !hc.leaderelector.IsLeader() {
+		hc.logger.InfoV(2, "acme: skipping certificate check (%s), leader is %s", source, hc.leaderelector.LeaderName())
+		return
+	}
+	hc.acmeMutex.Lock()
+	storages := hc.acmeStorages
+	hc.acmeMutex.Unlock()
+	hc.logger.Info("acme: starting certificate check (%s) of %d certificate(s)", source, len(storages))
+	for _, storage := range storages {
+		hc.acmeQueue.Add(storage)
+	}
+}
+
+func (hc *HAProxyController) syncAcme(item interface{}) error {
+	if !hc.leaderelector.IsLeader() {
+		hc.logger.InfoV(2, "acme: skipping %v, leader is %s", item, hc.leaderelector.LeaderName())
+		return nil
+	}
+	hc.acmeMutex.Lock()
+	acmeData := hc.acmeData
+	hc.acmeMutex.Unlock()
+	if acmeData == nil {
+		return nil
+	}
+	hc.acmeSigner.AcmeAccount(acmeData.Endpoint, acmeData.Emails, acmeData.TermsAgreed)
+	hc.acmeSigner.AcmeConfig(acmeData.Expiring)
+	return hc.acmeSigner.Notify(item)
+}
+
+// OnStartedLeading ...
+func (hc *HAProxyController) OnStartedLeading(ctx context.Context) {
+	hc.acmeCheck("started leading")
+}
+
+// OnStoppedLeading ...
+func (hc *HAProxyController) OnStoppedLeading() {
+}
+
+// OnNewLeader ...
+func (hc *HAProxyController) OnNewLeader(identity string) {
+}
@@ -17,11 +17,26 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"path"
 	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v1"
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	cfile "github.com/jcmoraisjr/haproxy-ingress/pkg/common/file"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
@@ -29,17 +44,30 @@ import (
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 )
 
+const (
+	acmePrivateKeyName = "key.pem"
+)
+
 type cache struct {
-	listers    *ingress.StoreLister
-	controller *controller.GenericController
-	crossNS    bool
+	client                 kubernetes.Interface
+	listers                *ingress.StoreLister
+	controller             *controller.GenericController
+	crossNS                bool
+	podNamespace           string
+	podName                string
+	controllerPod          *api.Pod
+	acmeSecretKeyName      string
+	acmeTokenConfigmapName string
 }
 
-func newCache(listers *ingress.StoreLister, controller *controller.GenericController) *cache {
+func newCache(client kubernetes.Interface, listers *ingress.StoreLister, controller *controller.GenericController, podNamespace, podName string) *cache {
 	return &cache{
-		listers:    listers,
-		controller: controller,
-		crossNS:    controller.GetConfig().AllowCrossNamespace,
+		client:       client,
+		listers:      listers,
+		controller:   controller,
+		crossNS:      controller.GetConfig().AllowCrossNamespace,
+		podNamespace: podNamespace,
+		podName:      podName,
 	}
 }
 
@@ -47,21 +75,30 @@ func (c *cache) GetService(serviceName string) (*api.Service, error) {
 	return c.listers.Service.GetByName(serviceName)
 }
 
-func (c *cache) GetEndpoints(service *api.Service) (*api.Endpoints, error) {
-	ep, err := c.listers.Endpoint.GetServiceEndpoints(service)
-	return &ep, err
+func (c *cache) GetServiceList() ([]*api.Service, error) {
+	var services []*api.Service
+	for _, obj := range c.listers.Service.List() {
+		services = append(services, obj.(*api.Service))
+	}
+	return services, nil
+}
+
+func (c *cache) GetEndpointSlices(service *api.Service) ([]*discovery.EndpointSlice, error) {
+	return c.listers.EndpointSlice.GetServiceEndpointSlices(service)
 }
 
-func (c *cache) GetTerminatingPods(service *api.Service) ([]*api.Pod, error) {
-	pods, err := c.listers.Pod.GetTerminatingServicePods(service)
-	if err != nil {
-		return []*api.Pod{}, err
+func (c *cache) GetHPAList(namespace string) ([]*autoscaling.HorizontalPodAutoscaler, error) {
+	if !c.controller.GetConfig().WatchHPA {
+		return nil, fmt.Errorf("HPAs aren't being watched, add --watch-hpa to the command-line options")
 	}
-	podRef := make([]*api.Pod, len(pods))
-	for i := range pods {
-		podRef[i] = &pods[i]
+	return c.listers.HPA.GetNamespaceHPAs(namespace)
+}
+
+func (c *cache) GetReplicaSet(namespace, name string) (*appsv1.ReplicaSet, error) {
+	if !c.controller.GetConfig().WatchReplicaSets {
+		return nil, fmt.Errorf("ReplicaSets aren't being watched, add --watch-replicasets to the command-line options")
 	}
-	return podRef, err
+	return c.listers.ReplicaSet.GetReplicaSet(namespace, name)
 }
 
 func (c *cache) GetPod(podName string) (*api.Pod, error) {
@@ -72,6 +109,34 @@ func (c *cache) GetPod(podName string) (*api.Pod, error) {
 	return c.listers.Pod.GetPod(sname[0], sname[1])
 }
 
+// GetControllerPod reads the pod of the controller from the API server. The pod
+// doesn't need to be in a watched namespace, and it's read only once.
+func (c *cache) GetControllerPod() (*api.Pod, error) {
+	if c.controllerPod != nil {
+		return c.controllerPod, nil
+	}
+	if c.podNamespace == "" || c.podName == "" {
+		return nil, fmt.Errorf("POD_NAME and POD_NAMESPACE envvars are required to read the controller pod")
+	}
+	pod, err := c.client.CoreV1().Pods(c.podNamespace).Get(context.Background(), c.podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c.controllerPod = pod
+	return pod, nil
+}
+
+func (c *cache) GetNode(nodeName string) (*api.Node, error) {
+	node, exists, err := c.listers.Node.GetByKey(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("node not found: '%s'", nodeName)
+	}
+	return node.(*api.Node), nil
+}
+
 func (c *cache) buildSecretName(defaultNamespace, secretName string) (string, error) {
 	if defaultNamespace == "" {
 		return secretName, nil
@@ -101,8 +166,9 @@ func (c *cache) GetTLSSecretPath(defaultNamespace, secretName string) (file conv
 		return file, fmt.Errorf("secret '%s' does not have keys 'tls.crt' and 'tls.key'", fullname)
 	}
 	file = convtypes.File{
-		Filename: sslCert.PemFileName,
-		SHA1Hash: sslCert.PemSHA,
+		Filename:    sslCert.PemFileName,
+		SHA1Hash:    sslCert.PemSHA,
+		Certificate: sslCert.Certificate,
 	}
 	return file, nil
 }
@@ -125,6 +191,31 @@ func (c *cache) GetCASecretPath(defaultNamespace, secretName string) (file convt
 	}, nil
 }
 
+func (c *cache) GetCRLSecretPath(defaultNamespace, secretName string) (file convtypes.File, err error) {
+	fullname, err := c.buildSecretName(defaultNamespace, secretName)
+	if err != nil {
+		return file, err
+	}
+	secret, err := c.listers.Secret.GetByName(fullname)
+	if err != nil {
+		return file, err
+	}
+	crl, found := secret.Data[ingress.CRLFilename]
+	if !found {
+		return file, fmt.Errorf("secret '%s' does not have key '%s'", fullname, ingress.CRLFilename)
+	}
+	pem := strings.Replace(fullname, "/", "_", -1) + "_crl"
+	pemFileName, err := ssl.AddOrUpdateCRL(pem, crl)
+	if err != nil {
+		return file, fmt.Errorf("error creating CRL file '%s': %v", pem, err)
+	}
+	file = convtypes.File{
+		Filename: pemFileName,
+		SHA1Hash: cfile.SHA1(pemFileName),
+	}
+	return file, nil
+}
+
 func (c *cache) GetDHSecretPath(defaultNamespace, secretName string) (file convtypes.File, err error) {
 	fullname, err := c.buildSecretName(defaultNamespace, secretName)
 	if err != nil {
@@ -165,3 +256,152 @@ func (c *cache) GetSecretContent(defaultNamespace, secretName, keyName string) (
 	}
 	return data, nil
 }
+
+// Implements acme.ClientResolver
+func (c *cache) GetKey() (crypto.Signer, error) {
+	ctx := context.Background()
+	secret, err := c.client.CoreV1().Secrets(c.podNamespace).Get(ctx, c.acmeSecretKeyName, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if pemKey, found := secret.Data[acmePrivateKeyName]; found {
+			block, _ := pem.Decode(pemKey)
+			if block == nil {
+				return nil, fmt.Errorf("secret '%s/%s' has an invalid private key", c.podNamespace, c.acmeSecretKeyName)
+			}
+			return x509.ParseECPrivateKey(block.Bytes)
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if secret == nil || secret.Name == "" {
+		secret = &api.Secret{}
+		secret.Namespace = c.podNamespace
+		secret.Name = c.acmeSecretKeyName
+		secret.Data = map[string][]byte{acmePrivateKeyName: pemKey}
+		_, err = c.client.CoreV1().Secrets(c.podNamespace).Create(ctx, secret, metav1.CreateOptions{})
+	} else {
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[acmePrivateKeyName] = pemKey
+		_, err = c.client.CoreV1().Secrets(c.podNamespace).Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func acmeTokenKey(domain, uri string) string {
+	// configmap keys only accept alphanumeric, `-`, `_` and `.`;
+	// domain names and acme tokens fit on this restriction
+	return domain + "_" + path.Base(uri)
+}
+
+// Implements acme.ClientResolver
+func (c *cache) SetToken(domain string, uri, token string) error {
+	ctx := context.Background()
+	key := acmeTokenKey(domain, uri)
+	cm, err := c.client.CoreV1().ConfigMaps(c.podNamespace).Get(ctx, c.acmeTokenConfigmapName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if token == "" {
+			return nil
+		}
+		cm = &api.ConfigMap{}
+		cm.Namespace = c.podNamespace
+		cm.Name = c.acmeTokenConfigmapName
+		cm.Data = map[string]string{key: token}
+		_, err = c.client.CoreV1().ConfigMaps(c.podNamespace).Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	if token == "" {
+		delete(cm.Data, key)
+	} else {
+		cm.Data[key] = token
+	}
+	_, err = c.client.CoreV1().ConfigMaps(c.podNamespace).Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// Implements acme.ServerResolver
+func (c *cache) GetToken(domain, uri string) string {
+	// read from the informer's cache instead of the api: the acme endpoint is
+	// public and shouldn't be translated to api calls. New tokens are visible
+	// to all the replicas as soon as the watch propagates the change
+	cm, err := c.listers.ConfigMap.GetByName(c.podNamespace + "/" + c.acmeTokenConfigmapName)
+	if err != nil {
+		return ""
+	}
+	return cm.Data[acmeTokenKey(domain, uri)]
+}
+
+// Implements acme.SignerResolver
+func (c *cache) GetTLSSecretContent(secretName string) *acme.TLSSecret {
+	secret, err := c.listers.Secret.GetByName(secretName)
+	if err != nil {
+		return nil
+	}
+	pemCrt, found := secret.Data[api.TLSCertKey]
+	if !found {
+		return nil
+	}
+	block, _ := pem.Decode(pemCrt)
+	if block == nil {
+		return nil
+	}
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return &acme.TLSSecret{
+		Crt: crt,
+	}
+}
+
+// Implements acme.SignerResolver
+func (c *cache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error {
+	ctx := context.Background()
+	name := strings.Split(secretName, "/")
+	if len(name) != 2 {
+		return fmt.Errorf("invalid secret name: '%s'", secretName)
+	}
+	secrets := c.client.CoreV1().Secrets(name[0])
+	secret, err := secrets.Get(ctx, name[1], metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		secret = &api.Secret{}
+		secret.Namespace = name[0]
+		secret.Name = name[1]
+		secret.Type = api.SecretTypeTLS
+		secret.Data = map[string][]byte{
+			api.TLSCertKey:       pemCrt,
+			api.TLSPrivateKeyKey: pemKey,
+		}
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[api.TLSCertKey] = pemCrt
+	secret.Data[api.TLSPrivateKeyKey] = pemKey
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
@@ -23,13 +23,16 @@ import (
 	"os/exec"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/pflag"
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/annotations/class"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
@@ -41,6 +44,7 @@ import (
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/version"
@@ -48,26 +52,44 @@ import (
 
 // HAProxyController has internal data of a HAProxyController instance
 type HAProxyController struct {
-	instance          haproxy.Instance
-	logger            types.Logger
-	cache             convtypes.Cache
-	updateCount       int
-	controller        *controller.GenericController
-	cfg               *controller.Configuration
-	configMap         *api.ConfigMap
-	storeLister       *ingress.StoreLister
-	converterOptions  *ingtypes.ConverterOptions
-	command           string
-	reloadStrategy    *string
-	configDir         string
-	configFilePrefix  string
-	configFileSuffix  string
-	maxOldConfigFiles *int
-	validateConfig    *bool
-	haproxyTemplate   *template
-	modsecConfigFile  string
-	modsecTemplate    *template
-	currentConfig     *types.ControllerConfig
+	instance                haproxy.Instance
+	logger                  types.Logger
+	cache                   *cache
+	metrics                 *metrics
+	rollouts                *rollouts
+	stopCh                  chan struct{}
+	updateCount             int
+	controller              *controller.GenericController
+	cfg                     *controller.Configuration
+	configMap               *api.ConfigMap
+	storeLister             *ingress.StoreLister
+	converterOptions        *ingtypes.ConverterOptions
+	leaderelector           leaderElector
+	acmeSigner              acme.Signer
+	acmeQueue               *queue
+	acmeMutex               sync.Mutex
+	acmeData                *hatypes.AcmeData
+	acmeStorages            []string
+	logForwarder            utils.SyslogForwarder
+	command                 string
+	reloadStrategy          *string
+	configDir               string
+	configFilePrefix        string
+	configFileSuffix        string
+	maxOldConfigFiles       *int
+	validateConfig          *bool
+	statsCollector          *bool
+	acmeServer              *bool
+	acmeCheckPeriod         *time.Duration
+	acmeElectionID          *string
+	acmeFailInitialDuration *time.Duration
+	acmeFailMaxDuration     *time.Duration
+	acmeSecretKeyName       *string
+	acmeTokenConfigmapName  *string
+	haproxyTemplate         *template
+	modsecConfigFile        string
+	modsecTemplate          *template
+	currentConfig           *types.ControllerConfig
 }
 
 // NewHAProxyController constructor
@@ -105,59 +127,64 @@ func (hc *HAProxyController) configController() {
 
 	// starting v0.8 only config
 	hc.logger = &logger{depth: 1}
-	hc.cache = newCache(hc.storeLister, hc.controller)
+	hc.stopCh = make(chan struct{})
+	hc.cache = newCache(hc.cfg.Client, hc.storeLister, hc.controller, os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME"))
+	hc.metrics = createMetrics()
 	instanceOptions := haproxy.InstanceOptions{
 		HAProxyCmd:        "haproxy",
 		ReloadCmd:         "/haproxy-reload.sh",
 		HAProxyConfigFile: "/etc/haproxy/haproxy.cfg",
+		Metrics:           hc.metrics,
 		ReloadStrategy:    *hc.reloadStrategy,
 		MaxOldConfigFiles: *hc.maxOldConfigFiles,
 		SortBackends:      hc.cfg.SortBackends,
 		ValidateConfig:    *hc.validateConfig,
 	}
-	hc.instance = haproxy.CreateInstance(hc.logger, hc, instanceOptions)
+	hc.instance = haproxy.CreateInstance(hc.logger, instanceOptions)
 	if err := hc.instance.ParseTemplates(); err != nil {
 		glog.Fatalf("error creating HAProxy instance: %v", err)
 	}
+	hc.rollouts = newRollouts(hc.instance, hc.controller.Notify)
 	hc.converterOptions = &ingtypes.ConverterOptions{
 		Logger:           hc.logger,
 		Cache:            hc.cache,
+		Metrics:          hc.metrics,
+		Rollouts:         hc.rollouts,
 		AnnotationPrefix: hc.cfg.AnnPrefix,
 		DefaultBackend:   hc.cfg.DefaultService,
 		DefaultCrtSecret: hc.cfg.DefaultSSLCertificate,
 		FakeCrtFile:      hc.createFakeCrtFile(),
 		FakeCAFile:       hc.createFakeCAFile(),
 	}
+	if *hc.acmeServer {
+		hc.converterOptions.AcmeSocket = acmeSocket
+	}
+	if *hc.statsCollector {
+		prometheus.MustRegister(newStatsCollector(hc.logger, hc.instance))
+	}
+	if *hc.acmeServer {
+		hc.configAcme()
+	}
 }
 
-func (hc *HAProxyController) createFakeCrtFile() (tlsFile convtypes.File) {
-	path, hash := hc.controller.CreateDefaultSSLCertificate()
-	return convtypes.File{
-		Filename: path,
-		SHA1Hash: hash,
+// configLogForwarder starts the forwarder of the HAProxy logs to stdout,
+// used if syslog-endpoint is configured as stdout
+func (hc *HAProxyController) configLogForwarder() {
+	logForwarder := utils.NewSyslogForwarder(hc.logger, hatypes.SyslogStdoutSocket, os.Stdout)
+	if err := logForwarder.Listen(hc.stopCh); err != nil {
+		hc.logger.Error("error creating the log forwarder listener: %v", err)
+		return
 	}
+	hc.logForwarder = logForwarder
 }
 
-// CreateX509CertsDir hard link files from certs to a single directory.
-func (hc *HAProxyController) CreateX509CertsDir(bindName string, certs []string) (string, error) {
-	x509dir := "/var/haproxy/certs/" + bindName
-	if err := os.RemoveAll(x509dir); err != nil {
-		return "", err
-	}
-	if err := os.MkdirAll(x509dir, 0700); err != nil {
-		return "", err
-	}
-	for _, cert := range certs {
-		srcFile, err := os.Stat(cert)
-		if err != nil {
-			return "", err
-		}
-		dstFile := x509dir + "/" + srcFile.Name()
-		if err := os.Link(cert, dstFile); err != nil {
-			return "", err
-		}
+func (hc *HAProxyController) createFakeCrtFile() (tlsFile convtypes.File) {
+	crt := hc.controller.CreateDefaultSSLCertificate()
+	return convtypes.File{
+		Filename:    crt.PemFileName,
+		SHA1Hash:    crt.PemSHA,
+		Certificate: crt.Certificate,
 	}
-	return x509dir, nil
 }
 
 func (hc *HAProxyController) createFakeCAFile() (crtFile convtypes.File) {
@@ -180,6 +207,12 @@ func (hc *HAProxyController) Stop() error {
 		glog.Infof("Waiting %v before stopping components", waitBeforeShutdown)
 		time.Sleep(waitBeforeShutdown)
 	}
+	if hc.stopCh != nil {
+		close(hc.stopCh)
+	}
+	if hc.acmeQueue != nil {
+		hc.acmeQueue.ShutDown()
+	}
 	err := hc.controller.Stop()
 	return err
 }
@@ -219,6 +252,22 @@ func (hc *HAProxyController) ConfigureFlags(flags *pflag.FlagSet) {
 		`Maximum old haproxy timestamped config files to allow before being cleaned up. A value <= 0 indicates a single non-timestamped config file will be used`)
 	hc.validateConfig = flags.Bool("validate-config", false,
 		`Define if the resulting configuration files should be validated when a dynamic update was applied. Default value is false, which means the validation will only happen when HAProxy need to be reloaded.`)
+	hc.statsCollector = flags.Bool("stats-collector", false,
+		`Exports HAProxy frontend, backend and server statistics, read from the admin socket, on the metrics endpoint. Backends and servers are labelled with the namespace, service, port and pod name.`)
+	hc.acmeServer = flags.Bool("acme-server", false,
+		`Enables the acme server used to answer HTTP-01 challenges and the acme signer used to issue certificates of ingress resources annotated with cert-signer=acme. POD_NAME and POD_NAMESPACE envvars are required.`)
+	hc.acmeCheckPeriod = flags.Duration("acme-check-period", 24*time.Hour,
+		`Time between checks of invalid or expiring certificates`)
+	hc.acmeElectionID = flags.String("acme-election-id", "acme-leader",
+		`Prefix of the election ID used to choose the controller instance that should sign certificates`)
+	hc.acmeFailInitialDuration = flags.Duration("acme-fail-initial-duration", 5*time.Minute,
+		`The initial time to wait to retry sign a new certificate after a failure. The time between retries will grow exponentially until 'acme-fail-max-duration'`)
+	hc.acmeFailMaxDuration = flags.Duration("acme-fail-max-duration", 8*time.Hour,
+		`The maximum time to wait after failing to sign a new certificate`)
+	hc.acmeSecretKeyName = flags.String("acme-secret-key-name", "acme-private-key",
+		`Name of the secret, on the controller namespace, used to store the acme account private key. The secret is created if it does not exist`)
+	hc.acmeTokenConfigmapName = flags.String("acme-token-configmap-name", "acme-validation-tokens",
+		`Name of the configmap, on the controller namespace, used to share the HTTP-01 tokens between controller replicas`)
 	ingressClass := flags.Lookup("ingress-class")
 	if ingressClass != nil {
 		ingressClass.Value.Set("haproxy")
@@ -305,6 +354,7 @@ func (hc *HAProxyController) SyncIngress(item interface{}) error {
 		globalConfig,
 	)
 	ingConverter.Sync(ingress)
+	hc.rollouts.removeMissing(hc.instance.Config().Backends())
 	timer.Tick("ingress")
 
 	//
@@ -328,7 +378,15 @@ func (hc *HAProxyController) SyncIngress(item interface{}) error {
 	//
 	// update proxy
 	//
+	if hc.logForwarder == nil && hc.instance.Config().Global().Syslog.Endpoint == hatypes.SyslogStdoutSocket {
+		hc.configLogForwarder()
+	}
+	acmeData := hc.instance.Config().AcmeData()
 	hc.instance.Update(timer)
+	if hc.acmeSigner != nil {
+		hc.acmeUpdate(acmeData)
+	}
+	hc.metrics.UpdateSyncTime(timer)
 	hc.logger.Info("Finish HAProxy update id=%d: %s", hc.updateCount, timer.AsString("total"))
 	return nil
 }
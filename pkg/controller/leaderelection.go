@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+type leaderSubscriber interface {
+	OnStartedLeading(ctx context.Context)
+	OnStoppedLeading()
+	OnNewLeader(identity string)
+}
+
+type leaderElector interface {
+	IsLeader() bool
+	LeaderName() string
+	Run(stopCh chan struct{})
+}
+
+type leaderelector struct {
+	logger     types.Logger
+	le         *leaderelection.LeaderElector
+	subscriber leaderSubscriber
+}
+
+func newLeaderElector(id string, logger types.Logger, client kubernetes.Interface, namespace, podName string, subscriber leaderSubscriber) leaderElector {
+	le := &leaderelector{
+		logger:     logger,
+		subscriber: subscriber,
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      id,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: podName,
+		},
+	}
+	ttl := 30 * time.Second
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: ttl,
+		RenewDeadline: ttl / 2,
+		RetryPeriod:   ttl / 4,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: le.onStartedLeading,
+			OnStoppedLeading: le.onStoppedLeading,
+			OnNewLeader:      le.onNewLeader,
+		},
+	})
+	if err != nil {
+		logger.Fatal("error starting leader election: %v", err)
+	}
+	le.le = elector
+	return le
+}
+
+func (l *leaderelector) IsLeader() bool {
+	return l.le.IsLeader()
+}
+
+func (l *leaderelector) LeaderName() string {
+	return l.le.GetLeader()
+}
+
+func (l *leaderelector) Run(stopCh chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	// Run() returns when leadership is lost, restart it until stopCh is closed
+	wait.Until(func() { l.le.Run(ctx) }, 0, stopCh)
+}
+
+func (l *leaderelector) onStartedLeading(ctx context.Context) {
+	l.logger.Info("leader acquired")
+	l.subscriber.OnStartedLeading(ctx)
+}
+
+func (l *leaderelector) onStoppedLeading() {
+	l.logger.Info("leader lost")
+	l.subscriber.OnStoppedLeading()
+}
+
+func (l *leaderelector) onNewLeader(identity string) {
+	l.logger.Info("leader changed to %s", identity)
+	l.subscriber.OnNewLeader(identity)
+}
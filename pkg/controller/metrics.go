@@ -0,0 +1,231 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/x509"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+type metrics struct {
+	certNotAfter    *prometheus.GaugeVec
+	certNotBefore   *prometheus.GaugeVec
+	certHostCovered *prometheus.GaugeVec
+	certFallback    *prometheus.GaugeVec
+	certFallbackCnt *prometheus.CounterVec
+	syncTime        *prometheus.HistogramVec
+	updates         *prometheus.CounterVec
+	reloadReason    *prometheus.CounterVec
+	reloadErrors    prometheus.Counter
+	validateErrors  prometheus.Counter
+	dynamicCommands prometheus.Counter
+	configObjects   *prometheus.GaugeVec
+}
+
+func createMetrics() *metrics {
+	metrics := newMetrics()
+	prometheus.MustRegister(metrics.certNotAfter)
+	prometheus.MustRegister(metrics.certNotBefore)
+	prometheus.MustRegister(metrics.certHostCovered)
+	prometheus.MustRegister(metrics.certFallback)
+	prometheus.MustRegister(metrics.certFallbackCnt)
+	prometheus.MustRegister(metrics.syncTime)
+	prometheus.MustRegister(metrics.updates)
+	prometheus.MustRegister(metrics.reloadReason)
+	prometheus.MustRegister(metrics.reloadErrors)
+	prometheus.MustRegister(metrics.validateErrors)
+	prometheus.MustRegister(metrics.dynamicCommands)
+	prometheus.MustRegister(metrics.configObjects)
+	return metrics
+}
+
+// newMetrics creates the metrics without registering them
+func newMetrics() *metrics {
+	namespace := "ingress_controller"
+	certLabels := []string{"namespace", "ingress", "hostname", "secret"}
+	metrics := &metrics{
+		certNotAfter: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cert_not_after_seconds",
+				Help: "Number of seconds since 1970 to the expiration of the certificate used by a hostname. " +
+					"An example to check if a certificate will expire in 10 days: " +
+					"\"ingress_controller_cert_not_after_seconds < (time() + (10 * 24 * 3600))\"",
+			},
+			certLabels,
+		),
+		certNotBefore: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cert_not_before_seconds",
+				Help:      "Number of seconds since 1970 to the start of the validity of the certificate used by a hostname.",
+			},
+			certLabels,
+		),
+		certHostCovered: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cert_hostname_covered",
+				Help:      "1 if the certificate used by a hostname covers it, 0 otherwise.",
+			},
+			certLabels,
+		),
+		certFallback: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "cert_fallback",
+				Help: "1 if a hostname doesn't use a certificate of its own: the secret wasn't declared, " +
+					"wasn't found or is invalid. The fallback label is default or fake. " +
+					"Reset on every synchronization, see also cert_fallback_total.",
+			},
+			[]string{"namespace", "ingress", "hostname", "fallback"},
+		),
+		certFallbackCnt: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "cert_fallback_total",
+				Help: "Cumulative number of hostnames configured with the default or the fake certificate, " +
+					"counted on every synchronization. The fallback label is default or fake.",
+			},
+			[]string{"fallback"},
+		),
+		syncTime: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "sync_phase_duration_seconds",
+				Help:      "Time spent on each phase of the synchronization of the HAProxy configuration.",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+			},
+			[]string{"phase"},
+		),
+		updates: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_updates_total",
+				Help: "Cumulative number of HAProxy updates by type: reload, dynamic - updated without " +
+					"reloading, or unchanged - the new configuration matches the running one.",
+			},
+			[]string{"type"},
+		),
+		reloadReason: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_reload_reason_total",
+				Help:      "Cumulative number of HAProxy reloads by the reason that prevented a dynamic update.",
+			},
+			[]string{"reason"},
+		),
+		reloadErrors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_reload_errors_total",
+				Help:      "Cumulative number of failed HAProxy reloads.",
+			},
+		),
+		validateErrors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_validation_errors_total",
+				Help:      "Cumulative number of configuration files that failed the HAProxy validation.",
+			},
+		),
+		dynamicCommands: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_dynamic_commands_total",
+				Help:      "Cumulative number of commands sent to the HAProxy admin socket by dynamic updates.",
+			},
+		),
+		configObjects: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "haproxy_config_objects",
+				Help:      "Number of hosts, backends, TCP services and endpoints of the current HAProxy configuration.",
+			},
+			[]string{"type"},
+		),
+	}
+	return metrics
+}
+
+func (m *metrics) ClearCertMetrics() {
+	m.certNotAfter.Reset()
+	m.certNotBefore.Reset()
+	m.certHostCovered.Reset()
+	m.certFallback.Reset()
+}
+
+func (m *metrics) SetCertMetrics(namespace, ingress, hostname, secret string, crt *x509.Certificate) {
+	if crt == nil {
+		return
+	}
+	covered := 0.0
+	if crt.VerifyHostname(hostname) == nil {
+		covered = 1.0
+	}
+	m.certNotAfter.WithLabelValues(namespace, ingress, hostname, secret).Set(float64(crt.NotAfter.Unix()))
+	m.certNotBefore.WithLabelValues(namespace, ingress, hostname, secret).Set(float64(crt.NotBefore.Unix()))
+	m.certHostCovered.WithLabelValues(namespace, ingress, hostname, secret).Set(covered)
+}
+
+func (m *metrics) SetCertFallback(namespace, ingress, hostname, fallback string) {
+	m.certFallback.WithLabelValues(namespace, ingress, hostname, fallback).Set(1)
+	m.certFallbackCnt.WithLabelValues(fallback).Inc()
+}
+
+// UpdateSyncTime observes the time spent between the ticks of
+// a synchronization timer, as well as the total time.
+func (m *metrics) UpdateSyncTime(timer *utils.Timer) {
+	last := timer.Start
+	for _, tick := range timer.Ticks {
+		m.syncTime.WithLabelValues(tick.Event).Observe(tick.When.Sub(last).Seconds())
+		last = tick.When
+	}
+	m.syncTime.WithLabelValues("total").Observe(last.Sub(timer.Start).Seconds())
+}
+
+func (m *metrics) IncUpdateNoop() {
+	m.updates.WithLabelValues("unchanged").Inc()
+}
+
+func (m *metrics) IncUpdateDynamic(commands int) {
+	m.updates.WithLabelValues("dynamic").Inc()
+	m.dynamicCommands.Add(float64(commands))
+}
+
+func (m *metrics) IncUpdateReload(reason string) {
+	m.updates.WithLabelValues("reload").Inc()
+	m.reloadReason.WithLabelValues(reason).Inc()
+}
+
+func (m *metrics) IncReloadError() {
+	m.reloadErrors.Inc()
+}
+
+func (m *metrics) IncValidationError() {
+	m.validateErrors.Inc()
+}
+
+func (m *metrics) SetConfigObjects(hosts, backends, tcpServices, endpoints int) {
+	m.configObjects.WithLabelValues("hosts").Set(float64(hosts))
+	m.configObjects.WithLabelValues("backends").Set(float64(backends))
+	m.configObjects.WithLabelValues("tcp_services").Set(float64(tcpServices))
+	m.configObjects.WithLabelValues("endpoints").Set(float64(endpoints))
+}
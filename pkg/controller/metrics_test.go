@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+func TestUpdateSyncTime(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	timer := &utils.Timer{
+		Start: start,
+		Ticks: []*utils.Tick{
+			{Event: "parse_ingress", When: start.Add(200 * time.Millisecond)},
+			{Event: "write_tmpl", When: start.Add(250 * time.Millisecond)},
+			{Event: "reload", When: start.Add(1250 * time.Millisecond)},
+		},
+	}
+	m := newMetrics()
+	m.UpdateSyncTime(timer)
+	m.UpdateSyncTime(timer)
+	expected := map[string]float64{
+		"parse_ingress": 0.2,
+		"write_tmpl":    0.05,
+		"reload":        1,
+		"total":         1.25,
+	}
+	for phase, duration := range expected {
+		metric := &dto.Metric{}
+		if err := m.syncTime.WithLabelValues(phase).(prometheus.Metric).Write(metric); err != nil {
+			t.Errorf("error reading phase '%s': %v", phase, err)
+			continue
+		}
+		histogram := metric.GetHistogram()
+		if count := histogram.GetSampleCount(); count != 2 {
+			t.Errorf("sample count of phase '%s' expected as 2, but was %d", phase, count)
+		}
+		if sum := histogram.GetSampleSum(); sum < 2*duration-0.000001 || sum > 2*duration+0.000001 {
+			t.Errorf("sample sum of phase '%s' expected as %f, but was %f", phase, 2*duration, sum)
+		}
+	}
+}
+
+func TestSetCertFallback(t *testing.T) {
+	m := newMetrics()
+	m.SetCertFallback("default", "ing1", "d1.local", "default")
+	m.SetCertFallback("default", "ing1", "d2.local", "fake")
+	m.ClearCertMetrics()
+	m.SetCertFallback("default", "ing1", "d1.local", "default")
+	expected := map[string]float64{
+		"default": 2,
+		"fake":    1,
+	}
+	for fallback, count := range expected {
+		metric := &dto.Metric{}
+		if err := m.certFallbackCnt.WithLabelValues(fallback).(prometheus.Metric).Write(metric); err != nil {
+			t.Errorf("error reading fallback '%s': %v", fallback, err)
+			continue
+		}
+		if value := metric.GetCounter().GetValue(); value != count {
+			t.Errorf("count of fallback '%s' expected as %f, but was %f", fallback, count, value)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// queue processes its items one at a time, items whose sync
+// fail are added back to the queue with a rate limit
+type queue struct {
+	workqueue workqueue.RateLimitingInterface
+	sync      func(item interface{}) error
+}
+
+// newFailureRateLimitingQueue creates a queue whose items are processed
+// one at a time by sync. Items whose sync fails are added back to the queue
+// after an exponential delay, between failInitialWait and failMaxWait.
+func newFailureRateLimitingQueue(failInitialWait, failMaxWait time.Duration, sync func(item interface{}) error) *queue {
+	return &queue{
+		workqueue: workqueue.NewRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(failInitialWait, failMaxWait),
+		),
+		sync: sync,
+	}
+}
+
+func (q *queue) Add(item interface{}) {
+	q.workqueue.Add(item)
+}
+
+func (q *queue) Len() int {
+	return q.workqueue.Len()
+}
+
+// Run processes the queue until ShutDown() is called
+func (q *queue) Run() {
+	for q.process() {
+	}
+}
+
+func (q *queue) process() bool {
+	item, shutdown := q.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.workqueue.Done(item)
+	if err := q.sync(item); err != nil {
+		q.workqueue.AddRateLimited(item)
+	} else {
+		q.workqueue.Forget(item)
+	}
+	return true
+}
+
+func (q *queue) ShutDown() {
+	q.workqueue.ShutDown()
+}
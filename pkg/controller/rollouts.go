@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// rollouts implements convtypes.Rollouts. The state of the blue/green
+// schedules is kept in memory, and a timer starts a new sync when the
+// next step of a schedule should be applied.
+type rollouts struct {
+	instance haproxy.Instance
+	notify   func()
+	mutex    sync.Mutex
+	states   map[string]*convtypes.RolloutState
+	timer    *time.Timer
+	next     time.Time
+}
+
+func newRollouts(instance haproxy.Instance, notify func()) *rollouts {
+	return &rollouts{
+		instance: instance,
+		notify:   notify,
+		states:   map[string]*convtypes.RolloutState{},
+	}
+}
+
+func (r *rollouts) Now() time.Time {
+	return time.Now()
+}
+
+func (r *rollouts) GetRollout(backend string) *convtypes.RolloutState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.states[backend]
+}
+
+func (r *rollouts) SetRollout(backend string, state *convtypes.RolloutState) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if state == nil {
+		delete(r.states, backend)
+	} else {
+		r.states[backend] = state
+	}
+}
+
+// removeMissing removes the states of the backends which
+// aren't in the configuration anymore
+func (r *rollouts) removeMissing(backends []*hatypes.Backend) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.states) == 0 {
+		return
+	}
+	found := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		found[backend.ID] = true
+	}
+	for backname := range r.states {
+		if !found[backname] {
+			delete(r.states, backname)
+		}
+	}
+}
+
+// ReadCounters sums `show stat` fields of the servers of a backend
+// whose endpoints are one of the pods
+func (r *rollouts) ReadCounters(backend string, pods []string, fields ...string) ([]int64, error) {
+	stats, err := r.instance.Stats()
+	if err != nil {
+		return nil, err
+	}
+	podNames := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		podNames[pod] = true
+	}
+	counters := make([]int64, len(fields))
+	for _, proxy := range stats.Proxies {
+		if proxy.Type != haproxy.ProxyServer || proxy.Proxy != backend || !podNames[proxy.Pod] {
+			continue
+		}
+		for i, field := range fields {
+			value, found := proxy.Fields[field]
+			if !found {
+				return nil, fmt.Errorf("field not found: %s", field)
+			}
+			if value == "" {
+				continue
+			}
+			counter, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			counters[i] += counter
+		}
+	}
+	return counters, nil
+}
+
+// ScheduleSync starts a new sync at the provided time, a sync which
+// is already scheduled to an earlier time is preserved
+func (r *rollouts) ScheduleSync(at time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.timer != nil && r.next.After(time.Now()) && !r.next.After(at) {
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.next = at
+	r.timer = time.AfterFunc(time.Until(at), r.notify)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestRolloutsRemoveMissing(t *testing.T) {
+	r := newRollouts(nil, nil)
+	r.SetRollout("default_app1_8080", &convtypes.RolloutState{Group: "g1"})
+	r.SetRollout("default_app2_8080", &convtypes.RolloutState{Group: "g2"})
+	r.removeMissing([]*hatypes.Backend{{ID: "default_app1_8080"}, {ID: "default_app3_8080"}})
+	if state := r.GetRollout("default_app1_8080"); state == nil || state.Group != "g1" {
+		t.Errorf("expected state of default_app1_8080 to be preserved, but was %+v", state)
+	}
+	if state := r.GetRollout("default_app2_8080"); state != nil {
+		t.Errorf("expected state of default_app2_8080 to be removed, but was %+v", state)
+	}
+	if len(r.states) != 1 {
+		t.Errorf("expected 1 state, but was %d", len(r.states))
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// statsField maps a `show stat` field to a metric of the proxy types it applies
+type statsField struct {
+	field     string
+	name      string
+	help      string
+	valueType prometheus.ValueType
+	types     []haproxy.ProxyType
+}
+
+var (
+	allProxies   = []haproxy.ProxyType{haproxy.ProxyFrontend, haproxy.ProxyBackend, haproxy.ProxyServer}
+	backProxies  = []haproxy.ProxyType{haproxy.ProxyBackend, haproxy.ProxyServer}
+	frontProxies = []haproxy.ProxyType{haproxy.ProxyFrontend}
+)
+
+var statsFields = []statsField{
+	{"scur", "current_sessions", "Current number of sessions.", prometheus.GaugeValue, allProxies},
+	{"stot", "sessions_total", "Cumulative number of sessions.", prometheus.CounterValue, allProxies},
+	{"bin", "bytes_in_total", "Cumulative number of request bytes.", prometheus.CounterValue, allProxies},
+	{"bout", "bytes_out_total", "Cumulative number of response bytes.", prometheus.CounterValue, allProxies},
+	{"ereq", "request_errors_total", "Cumulative number of request errors.", prometheus.CounterValue, frontProxies},
+	{"qcur", "current_queue", "Current number of queued requests.", prometheus.GaugeValue, backProxies},
+	{"econ", "connection_errors_total", "Cumulative number of connection errors.", prometheus.CounterValue, backProxies},
+	{"eresp", "response_errors_total", "Cumulative number of response errors.", prometheus.CounterValue, backProxies},
+	{"weight", "weight", "Current weight.", prometheus.GaugeValue, backProxies},
+}
+
+var statsInfoFields = []statsField{
+	{"Uptime_sec", "process_uptime_seconds", "Time since HAProxy was started.", prometheus.GaugeValue, nil},
+	{"CurrConns", "process_current_connections", "Current number of connections.", prometheus.GaugeValue, nil},
+	{"CumConns", "process_connections_total", "Cumulative number of connections.", prometheus.CounterValue, nil},
+	{"CumReq", "process_requests_total", "Cumulative number of requests.", prometheus.CounterValue, nil},
+}
+
+var statsHTTPCodes = []string{"1xx", "2xx", "3xx", "4xx", "5xx", "other"}
+
+// statsCollector exports the `show info` and `show stat` output of the
+// running HAProxy. Backends and servers are labelled with the namespace,
+// service and port of the Kubernetes service, and servers with the pod name.
+type statsCollector struct {
+	logger    types.Logger
+	instance  haproxy.Instance
+	up        *prometheus.Desc
+	info      map[string]*prometheus.Desc
+	fields    map[haproxy.ProxyType]map[string]*prometheus.Desc
+	status    map[haproxy.ProxyType]*prometheus.Desc
+	responses map[haproxy.ProxyType]*prometheus.Desc
+}
+
+func newStatsCollector(logger types.Logger, instance haproxy.Instance) *statsCollector {
+	namespace := "haproxy"
+	labels := map[haproxy.ProxyType][]string{
+		haproxy.ProxyFrontend: {"proxy"},
+		haproxy.ProxyBackend:  {"proxy", "namespace", "service", "port"},
+		haproxy.ProxyServer:   {"proxy", "server", "namespace", "service", "port", "pod"},
+	}
+	c := &statsCollector{
+		logger:   logger,
+		instance: instance,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"1 if the last scrape of the HAProxy admin socket was successful, 0 otherwise.",
+			nil, nil,
+		),
+		info:      map[string]*prometheus.Desc{},
+		fields:    map[haproxy.ProxyType]map[string]*prometheus.Desc{},
+		status:    map[haproxy.ProxyType]*prometheus.Desc{},
+		responses: map[haproxy.ProxyType]*prometheus.Desc{},
+	}
+	for _, f := range statsInfoFields {
+		c.info[f.field] = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", f.name), f.help, nil, nil)
+	}
+	for _, proxyType := range allProxies {
+		c.fields[proxyType] = map[string]*prometheus.Desc{}
+		subsystem := string(proxyType)
+		c.responses[proxyType] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "http_responses_total"),
+			"Cumulative number of HTTP responses by status code class.",
+			append(labels[proxyType], "code"), nil,
+		)
+	}
+	for _, f := range statsFields {
+		for _, proxyType := range f.types {
+			c.fields[proxyType][f.field] = prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, string(proxyType), f.name),
+				f.help, labels[proxyType], nil,
+			)
+		}
+	}
+	for _, proxyType := range backProxies {
+		c.status[proxyType] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, string(proxyType), "up"),
+			"1 if the status is UP, 0 otherwise.",
+			labels[proxyType], nil,
+		)
+	}
+	return c
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	for _, desc := range c.info {
+		ch <- desc
+	}
+	for _, fields := range c.fields {
+		for _, desc := range fields {
+			ch <- desc
+		}
+	}
+	for _, desc := range c.status {
+		ch <- desc
+	}
+	for _, desc := range c.responses {
+		ch <- desc
+	}
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.instance.Stats()
+	if err != nil {
+		c.logger.Warn("error reading HAProxy stats: %v", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+	for _, f := range statsInfoFields {
+		if value, err := strconv.ParseFloat(stats.Info[f.field], 64); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.info[f.field], f.valueType, value)
+		}
+	}
+	for _, proxy := range stats.Proxies {
+		var labels []string
+		switch proxy.Type {
+		case haproxy.ProxyFrontend:
+			labels = []string{proxy.Proxy}
+		case haproxy.ProxyBackend:
+			labels = []string{proxy.Proxy, proxy.Namespace, proxy.Service, proxy.Port}
+		case haproxy.ProxyServer:
+			labels = []string{proxy.Proxy, proxy.Server, proxy.Namespace, proxy.Service, proxy.Port, proxy.Pod}
+		}
+		for _, f := range statsFields {
+			desc, found := c.fields[proxy.Type][f.field]
+			if !found {
+				continue
+			}
+			if value, err := strconv.ParseFloat(proxy.Fields[f.field], 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(desc, f.valueType, value, labels...)
+			}
+		}
+		for _, code := range statsHTTPCodes {
+			if value, err := strconv.ParseFloat(proxy.Fields["hrsp_"+code], 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(c.responses[proxy.Type], prometheus.CounterValue, value, append(labels, code)...)
+			}
+		}
+		if desc, found := c.status[proxy.Type]; found {
+			up := 0.0
+			if status := proxy.Fields["status"]; strings.HasPrefix(status, "UP") || status == "no check" {
+				up = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, up, labels...)
+		}
+	}
+}
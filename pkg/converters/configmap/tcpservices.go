@@ -62,6 +62,11 @@ func (c *tcpSvcConverter) Sync(tcpservices map[string]string) {
 			c.logger.Warn("skipping invalid public listening port of TCP service: %s", k)
 			continue
 		}
+		if backend := c.haproxy.FindTCPBackend(publicport); backend != nil {
+			// services declared via annotations are converted first and have precedence
+			c.logger.Warn("skipping TCP service '%s' of the ConfigMap on public port %d: port is already declared by the annotations of service '%s'", v, publicport, backend.Name)
+			continue
+		}
 		svc := c.parseService(v)
 		if svc.name == "" {
 			c.logger.Warn("skipping empty TCP service name on public port %d", publicport)
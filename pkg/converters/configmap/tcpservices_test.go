@@ -21,9 +21,10 @@ import (
 	"strings"
 	"testing"
 
+	discovery "k8s.io/api/discovery/v1"
+
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
-	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 )
@@ -32,6 +33,7 @@ func TestTCPSvcSync(t *testing.T) {
 	testCases := []struct {
 		svcmock    map[string]string
 		secretmock map[string]string
+		declared   map[int]string
 		services   map[string]string
 		expected   []*hatypes.TCPBackend
 		logging    string
@@ -59,16 +61,16 @@ func TestTCPSvcSync(t *testing.T) {
 				{
 					Name: "default_pg",
 					Port: 15432,
-					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					Endpoints: []*hatypes.Endpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432, Enabled: true, Weight: 1},
 					},
 				},
 				{
 					Name: "default_sendmail",
 					Port: 10025,
-					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.201", Port: 25},
-						{Name: "srv002", IP: "172.17.0.202", Port: 25},
+					Endpoints: []*hatypes.Endpoint{
+						{Name: "srv001", IP: "172.17.0.201", Port: 25, Enabled: true, Weight: 1},
+						{Name: "srv002", IP: "172.17.0.202", Port: 25, Enabled: true, Weight: 1},
 					},
 				},
 			},
@@ -116,8 +118,8 @@ func TestTCPSvcSync(t *testing.T) {
 					Name:      "default_pg",
 					Port:      5432,
 					ProxyProt: hatypes.TCPProxyProt{Decode: true},
-					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					Endpoints: []*hatypes.Endpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432, Enabled: true, Weight: 1},
 					},
 				},
 			},
@@ -131,8 +133,8 @@ func TestTCPSvcSync(t *testing.T) {
 					Name:      "default_pg",
 					Port:      5432,
 					ProxyProt: hatypes.TCPProxyProt{EncodeVersion: "v1"},
-					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					Endpoints: []*hatypes.Endpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432, Enabled: true, Weight: 1},
 					},
 				},
 			},
@@ -146,8 +148,8 @@ func TestTCPSvcSync(t *testing.T) {
 					Name:      "default_pg",
 					Port:      5432,
 					ProxyProt: hatypes.TCPProxyProt{EncodeVersion: "v2"},
-					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					Endpoints: []*hatypes.Endpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432, Enabled: true, Weight: 1},
 					},
 				},
 			},
@@ -169,12 +171,38 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_pg",
 					Port: 5432,
 					SSL:  hatypes.TCPSSL{Filename: "/var/haproxy/ssl/crt.pem"},
-					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					Endpoints: []*hatypes.Endpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432, Enabled: true, Weight: 1},
 					},
 				},
 			},
 		},
+		// 13
+		{
+			svcmock:  map[string]string{"default/pg:5432": "172.17.0.101"},
+			declared: map[int]string{5432: "default_pg1"},
+			services: map[string]string{"5432": "default/pg:5432"},
+			expected: []*hatypes.TCPBackend{
+				{Name: "default_pg1", Port: 5432},
+			},
+			logging: `WARN skipping TCP service 'default/pg:5432' of the ConfigMap on public port 5432: port is already declared by the annotations of service 'default_pg1'`,
+		},
+		// 14
+		{
+			svcmock:  map[string]string{"default/pg:5432": "172.17.0.101"},
+			declared: map[int]string{15432: "default_pg1"},
+			services: map[string]string{"5432": "default/pg:5432"},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 5432,
+					Endpoints: []*hatypes.Endpoint{
+						{Name: "srv001", IP: "172.17.0.101", Port: 5432, Enabled: true, Weight: 1},
+					},
+				},
+				{Name: "default_pg1", Port: 15432},
+			},
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -182,9 +210,12 @@ func TestTCPSvcSync(t *testing.T) {
 			svcport := strings.Split(svckey, ":")
 			svc, ep := conv_helper.CreateService(svcport[0], svcport[1], endpoinds)
 			c.cache.SvcList = append(c.cache.SvcList, svc)
-			c.cache.EpList[svcport[0]] = ep
+			c.cache.EpList[svcport[0]] = []*discovery.EndpointSlice{ep}
 		}
 		c.cache.SecretTLSPath = test.secretmock
+		for port, name := range test.declared {
+			c.haproxy.AcquireTCPBackend(name, port)
+		}
 		NewTCPServicesConverter(c.logger, c.haproxy, c.cache).Sync(test.services)
 		backends := c.haproxy.TCPBackends()
 		for _, b := range backends {
@@ -213,7 +244,7 @@ func setup(t *testing.T) *testConfig {
 		t:       t,
 		logger:  logger,
 		cache:   conv_helper.NewCacheMock(),
-		haproxy: haproxy.CreateInstance(logger, &ha_helper.BindUtilsMock{}, haproxy.InstanceOptions{}).Config(),
+		haproxy: haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config(),
 	}
 	return c
 }
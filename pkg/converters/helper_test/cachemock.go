@@ -18,10 +18,14 @@ package helper_test
 
 import (
 	"crypto/sha1"
+	"crypto/x509"
 	"fmt"
 	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v1"
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 )
@@ -32,11 +36,16 @@ type SecretContent map[string]map[string][]byte
 // CacheMock ...
 type CacheMock struct {
 	SvcList       []*api.Service
-	EpList        map[string]*api.Endpoints
-	TermPodList   map[string][]*api.Pod
+	EpList        map[string][]*discovery.EndpointSlice
 	PodList       map[string]*api.Pod
+	ControllerPod *api.Pod
+	NodeList      map[string]*api.Node
+	HPAList       []*autoscaling.HorizontalPodAutoscaler
+	RSList        map[string]*appsv1.ReplicaSet
 	SecretTLSPath map[string]string
+	SecretTLSCrt  map[string]*x509.Certificate
 	SecretCAPath  map[string]string
+	SecretCRLPath map[string]string
 	SecretDHPath  map[string]string
 	SecretContent SecretContent
 }
@@ -44,12 +53,12 @@ type CacheMock struct {
 // NewCacheMock ...
 func NewCacheMock() *CacheMock {
 	return &CacheMock{
-		SvcList:     []*api.Service{},
-		EpList:      map[string]*api.Endpoints{},
-		TermPodList: map[string][]*api.Pod{},
+		SvcList: []*api.Service{},
+		EpList:  map[string][]*discovery.EndpointSlice{},
 		SecretTLSPath: map[string]string{
 			"system/ingress-default": "/tls/tls-default.pem",
 		},
+		SecretTLSCrt: map[string]*x509.Certificate{},
 	}
 }
 
@@ -73,22 +82,18 @@ func (c *CacheMock) GetService(serviceName string) (*api.Service, error) {
 	return nil, fmt.Errorf("service not found: '%s'", serviceName)
 }
 
-// GetEndpoints ...
-func (c *CacheMock) GetEndpoints(service *api.Service) (*api.Endpoints, error) {
-	serviceName := service.Namespace + "/" + service.Name
-	if ep, found := c.EpList[serviceName]; found {
-		return ep, nil
-	}
-	return nil, fmt.Errorf("could not find endpoints for service '%s'", serviceName)
+// GetServiceList ...
+func (c *CacheMock) GetServiceList() ([]*api.Service, error) {
+	return c.SvcList, nil
 }
 
-// GetTerminatingPods ...
-func (c *CacheMock) GetTerminatingPods(service *api.Service) ([]*api.Pod, error) {
+// GetEndpointSlices ...
+func (c *CacheMock) GetEndpointSlices(service *api.Service) ([]*discovery.EndpointSlice, error) {
 	serviceName := service.Namespace + "/" + service.Name
-	if pods, found := c.TermPodList[serviceName]; found {
-		return pods, nil
+	if slices, found := c.EpList[serviceName]; found {
+		return slices, nil
 	}
-	return []*api.Pod{}, nil
+	return nil, fmt.Errorf("could not find endpoints for service '%s'", serviceName)
 }
 
 // GetPod ...
@@ -99,13 +104,49 @@ func (c *CacheMock) GetPod(podName string) (*api.Pod, error) {
 	return nil, fmt.Errorf("pod not found: '%s'", podName)
 }
 
+// GetControllerPod ...
+func (c *CacheMock) GetControllerPod() (*api.Pod, error) {
+	if c.ControllerPod == nil {
+		return nil, fmt.Errorf("controller pod not found")
+	}
+	return c.ControllerPod, nil
+}
+
+// GetHPAList ...
+func (c *CacheMock) GetHPAList(namespace string) ([]*autoscaling.HorizontalPodAutoscaler, error) {
+	var hpas []*autoscaling.HorizontalPodAutoscaler
+	for _, hpa := range c.HPAList {
+		if hpa.Namespace == namespace {
+			hpas = append(hpas, hpa)
+		}
+	}
+	return hpas, nil
+}
+
+// GetReplicaSet ...
+func (c *CacheMock) GetReplicaSet(namespace, name string) (*appsv1.ReplicaSet, error) {
+	if rs, found := c.RSList[namespace+"/"+name]; found {
+		return rs, nil
+	}
+	return nil, fmt.Errorf("replicaset not found: '%s/%s'", namespace, name)
+}
+
+// GetNode ...
+func (c *CacheMock) GetNode(nodeName string) (*api.Node, error) {
+	if node, found := c.NodeList[nodeName]; found {
+		return node, nil
+	}
+	return nil, fmt.Errorf("node not found: '%s'", nodeName)
+}
+
 // GetTLSSecretPath ...
 func (c *CacheMock) GetTLSSecretPath(defaultNamespace, secretName string) (convtypes.File, error) {
 	fullname := c.buildSecretName(defaultNamespace, secretName)
 	if path, found := c.SecretTLSPath[fullname]; found {
 		return convtypes.File{
-			Filename: path,
-			SHA1Hash: fmt.Sprintf("%x", sha1.Sum([]byte(path))),
+			Filename:    path,
+			SHA1Hash:    fmt.Sprintf("%x", sha1.Sum([]byte(path))),
+			Certificate: c.SecretTLSCrt[fullname],
 		}, nil
 	}
 	return convtypes.File{}, fmt.Errorf("secret not found: '%s'", fullname)
@@ -123,6 +164,18 @@ func (c *CacheMock) GetCASecretPath(defaultNamespace, secretName string) (convty
 	return convtypes.File{}, fmt.Errorf("secret not found: '%s'", fullname)
 }
 
+// GetCRLSecretPath ...
+func (c *CacheMock) GetCRLSecretPath(defaultNamespace, secretName string) (convtypes.File, error) {
+	fullname := c.buildSecretName(defaultNamespace, secretName)
+	if path, found := c.SecretCRLPath[fullname]; found {
+		return convtypes.File{
+			Filename: path,
+			SHA1Hash: fmt.Sprintf("%x", sha1.Sum([]byte(path))),
+		}, nil
+	}
+	return convtypes.File{}, fmt.Errorf("secret not found: '%s'", fullname)
+}
+
 // GetDHSecretPath ...
 func (c *CacheMock) GetDHSecretPath(defaultNamespace, secretName string) (convtypes.File, error) {
 	fullname := c.buildSecretName(defaultNamespace, secretName)
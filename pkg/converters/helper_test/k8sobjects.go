@@ -20,12 +20,13 @@ import (
 	"strings"
 
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
 // CreateService ...
-func CreateService(name, port, endpoints string) (*api.Service, *api.Endpoints) {
+func CreateService(name, port, endpoints string) (*api.Service, *discovery.EndpointSlice) {
 	sname := strings.Split(name, "/") // namespace/name of the service
 	sport := strings.Split(port, ":") // numeric-port -or- name:numeric-port -or- name:numeric-port:named-port
 	if len(sport) < 2 {
@@ -47,19 +48,20 @@ spec:
     targetPort: ` + sport[2]).(*api.Service)
 
 	ep := CreateObject(`
-apiVersion: v1
-kind: Endpoints
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
 metadata:
-  name: ` + sname[1] + `
+  name: ` + sname[1] + `-xxxxx
   namespace: ` + sname[0] + `
-subsets:
-- addresses: []
-  ports:
-  - name: ` + sport[0] + `
-    port: ` + sport[1] + `
-    protocol: TCP`).(*api.Endpoints)
+  labels:
+    kubernetes.io/service-name: ` + sname[1] + `
+addressType: IPv4
+endpoints: []
+ports:
+- name: ` + sport[0] + `
+  port: ` + sport[1] + `
+  protocol: TCP`).(*discovery.EndpointSlice)
 
-	addr := []api.EndpointAddress{}
 	for _, e := range strings.Split(endpoints, ",") {
 		if e != "" {
 			target := &api.ObjectReference{
@@ -67,14 +69,27 @@ subsets:
 				Name:      sname[1] + "-xxxxx",
 				Namespace: sname[0],
 			}
-			addr = append(addr, api.EndpointAddress{IP: e, TargetRef: target})
+			ep.Endpoints = append(ep.Endpoints, CreateEndpoint(e, target, true, false))
 		}
 	}
-	ep.Subsets[0].Addresses = addr
 
 	return svc, ep
 }
 
+// CreateEndpoint ...
+func CreateEndpoint(ip string, target *api.ObjectReference, ready, terminating bool) discovery.Endpoint {
+	serving := ready || terminating
+	return discovery.Endpoint{
+		Addresses: []string{ip},
+		Conditions: discovery.EndpointConditions{
+			Ready:       &ready,
+			Serving:     &serving,
+			Terminating: &terminating,
+		},
+		TargetRef: target,
+	}
+}
+
 // CreateObject ...
 func CreateObject(cfg string) runtime.Object {
 	decode := scheme.Codecs.UniversalDeserializer().Decode
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper_test
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// MetricsMock ...
+type MetricsMock struct {
+	CertMetrics  []string
+	CertFallback map[string]int
+}
+
+// NewMetricsMock ...
+func NewMetricsMock() *MetricsMock {
+	return &MetricsMock{
+		CertFallback: map[string]int{},
+	}
+}
+
+// ClearCertMetrics ...
+func (m *MetricsMock) ClearCertMetrics() {
+	m.CertMetrics = nil
+	m.CertFallback = map[string]int{}
+}
+
+// SetCertMetrics ...
+func (m *MetricsMock) SetCertMetrics(namespace, ingress, hostname, secret string, crt *x509.Certificate) {
+	if crt == nil {
+		return
+	}
+	m.CertMetrics = append(m.CertMetrics, fmt.Sprintf("%s/%s %s secret=%s notAfter=%d",
+		namespace, ingress, hostname, secret, crt.NotAfter.Unix()))
+}
+
+// SetCertFallback ...
+func (m *MetricsMock) SetCertFallback(namespace, ingress, hostname, fallback string) {
+	m.CertFallback[fmt.Sprintf("%s/%s %s %s", namespace, ingress, hostname, fallback)] = 1
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper_test
+
+import (
+	"fmt"
+	"time"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+// RolloutsMock ...
+type RolloutsMock struct {
+	NowTime  time.Time
+	States   map[string]*convtypes.RolloutState
+	Counters map[string][]int64
+	Syncs    []time.Time
+}
+
+// NewRolloutsMock ...
+func NewRolloutsMock() *RolloutsMock {
+	return &RolloutsMock{
+		States:   map[string]*convtypes.RolloutState{},
+		Counters: map[string][]int64{},
+	}
+}
+
+// Now ...
+func (r *RolloutsMock) Now() time.Time {
+	return r.NowTime
+}
+
+// GetRollout ...
+func (r *RolloutsMock) GetRollout(backend string) *convtypes.RolloutState {
+	return r.States[backend]
+}
+
+// SetRollout ...
+func (r *RolloutsMock) SetRollout(backend string, state *convtypes.RolloutState) {
+	if state == nil {
+		delete(r.States, backend)
+	} else {
+		r.States[backend] = state
+	}
+}
+
+// ReadCounters ...
+func (r *RolloutsMock) ReadCounters(backend string, pods []string, fields ...string) ([]int64, error) {
+	if counters, found := r.Counters[backend]; found {
+		return counters, nil
+	}
+	return nil, fmt.Errorf("backend not found: '%s'", backend)
+}
+
+// ScheduleSync ...
+func (r *RolloutsMock) ScheduleSync(at time.Time) {
+	r.Syncs = append(r.Syncs, at)
+}
@@ -19,12 +19,18 @@ package annotations
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	api "k8s.io/api/core/v1"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
@@ -127,6 +133,41 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendAuthTLS(d *backData) {
+	if d.backend.ModeTCP {
+		return
+	}
+	for _, cfg := range d.mapper.GetBackendConfig(
+		d.backend,
+		[]string{ingtypes.BackAuthTLSCertRequired, ingtypes.BackAuthTLSMatchCN, ingtypes.BackAuthTLSMatchOU},
+		nil,
+	) {
+		d.backend.AuthTLS = append(d.backend.AuthTLS, &hatypes.BackendConfigAuthTLS{
+			Paths: cfg.Paths,
+			Config: hatypes.AuthTLS{
+				CertRequired: cfg.Get(ingtypes.BackAuthTLSCertRequired).Bool(),
+				MatchCN:      c.splitDNField(cfg.Get(ingtypes.BackAuthTLSMatchCN)),
+				MatchOU:      c.splitDNField(cfg.Get(ingtypes.BackAuthTLSMatchOU)),
+			},
+		})
+	}
+}
+
+func (c *updater) splitDNField(field *ConfigValue) []string {
+	var values []string
+	for _, value := range utils.Split(field.Value, ",") {
+		if value == "" {
+			continue
+		}
+		if strings.Index(value, `"`) >= 0 {
+			c.logger.Warn("ignoring certificate subject field with quotes on %v: %s", field.Source, value)
+		} else {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func extractUserlist(source, secret, users string) ([]hatypes.User, []error) {
 	var userlist []hatypes.User
 	var err []error
@@ -169,6 +210,30 @@ func extractUserlist(source, secret, users string) ([]hatypes.User, []error) {
 	return userlist, err
 }
 
+const (
+	// blueGreenDeployment is the blue/green label name which
+	// references pods of a Deployment
+	blueGreenDeployment = "@deployment"
+	// revisionAnnotation has the revision of a Deployment's ReplicaSet
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+)
+
+type deployWeight struct {
+	labelName  string
+	labelValue string
+	weight     int
+	endpoints  []*hatypes.Endpoint
+	pods       []*api.Pod
+	podWeights []int
+	podSum     int
+}
+
+// podFactor is how bigger the pod weight is than the average of the deployment,
+// it's 1 if the pods don't declare a weight, all of them use `initial-weight`
+func (dw *deployWeight) podFactor(i int) float32 {
+	return float32(dw.podWeights[i]*len(dw.endpoints)) / float32(dw.podSum)
+}
+
 func (c *updater) buildBackendBlueGreen(d *backData) {
 	balance := d.mapper.Get(ingtypes.BackBlueGreenBalance)
 	if balance.Source == nil || balance.Value == "" {
@@ -178,12 +243,6 @@ func (c *updater) buildBackendBlueGreen(d *backData) {
 		}
 	}
 	initialWeight := d.mapper.Get(ingtypes.BackInitialWeight).Int()
-	type deployWeight struct {
-		labelName  string
-		labelValue string
-		weight     int
-		endpoints  []*hatypes.Endpoint
-	}
 	var deployWeights []*deployWeight
 	for _, weight := range strings.Split(balance.Value, ",") {
 		dwSlice := strings.Split(weight, "=")
@@ -211,22 +270,32 @@ func (c *updater) buildBackendBlueGreen(d *backData) {
 		}
 		deployWeights = append(deployWeights, dw)
 	}
+	var revisions map[string]string
+	for _, dw := range deployWeights {
+		if isRevisionGroup(dw) {
+			revisions = c.replicaSetRevisions(d)
+			break
+		}
+	}
 	for _, ep := range d.backend.Endpoints {
 		if ep.Weight == 0 {
 			// Draining endpoint, remove from blue/green calc
 			continue
 		}
 		hasLabel := false
+		// weight assigned from the endpoint's pod, `initial-weight` if not annotated
+		podWeight := ep.Weight
 		if pod, err := c.cache.GetPod(ep.TargetRef); err == nil {
 			for _, dw := range deployWeights {
-				if label, found := pod.Labels[dw.labelName]; found {
-					if label == dw.labelValue {
-						// mode == pod and gcdGroupWeight == 0 need ep.Weight assgined,
-						// otherwise ep.Weight will be rewritten after rebalance
-						ep.Weight = dw.weight
-						dw.endpoints = append(dw.endpoints, ep)
-						hasLabel = true
-					}
+				if blueGreenMatch(pod, dw, revisions) {
+					// mode == pod and gcdGroupWeight == 0 need ep.Weight assgined,
+					// otherwise ep.Weight will be rewritten after rebalance
+					ep.Weight = podBalanceWeight(dw.weight, podWeight, initialWeight)
+					dw.endpoints = append(dw.endpoints, ep)
+					dw.pods = append(dw.pods, pod)
+					dw.podWeights = append(dw.podWeights, podWeight)
+					dw.podSum += podWeight
+					hasLabel = true
 				}
 			}
 		} else {
@@ -246,6 +315,13 @@ func (c *updater) buildBackendBlueGreen(d *backData) {
 			c.logger.InfoV(3, "blue/green balance label '%s=%s' on %v does not reference any endpoint", dw.labelName, dw.labelValue, balance.Source)
 		}
 	}
+	if c.buildBlueGreenSchedule(d, deployWeights) {
+		for _, dw := range deployWeights {
+			for i, ep := range dw.endpoints {
+				ep.Weight = podBalanceWeight(dw.weight, dw.podWeights[i], initialWeight)
+			}
+		}
+	}
 	if mode := d.mapper.Get(ingtypes.BackBlueGreenMode); mode.Value == "pod" {
 		// mode == pod, same weight as defined on balance annotation,
 		// no need to rebalance
@@ -272,7 +348,7 @@ func (c *updater) buildBackendBlueGreen(d *backData) {
 	}
 	gcdGroupWeight := 0
 	minWeight := -1
-	maxWeight := 0
+	var maxWeight float32
 	for _, dw := range deployWeights {
 		count := len(dw.endpoints)
 		if count == 0 || dw.weight == 0 {
@@ -287,8 +363,11 @@ func (c *updater) buildBackendBlueGreen(d *backData) {
 		if groupWeight < minWeight || minWeight < 0 {
 			minWeight = groupWeight
 		}
-		if groupWeight > maxWeight {
-			maxWeight = groupWeight
+		// pods with a bigger weight have a bigger share of the deployment weight
+		for i := range dw.endpoints {
+			if epWeight := float32(groupWeight) * dw.podFactor(i); epWeight > maxWeight {
+				maxWeight = epWeight
+			}
 		}
 	}
 	if gcdGroupWeight == 0 {
@@ -301,11 +380,12 @@ func (c *updater) buildBackendBlueGreen(d *backData) {
 	weightFactorMin := float32(initialWeight*gcdGroupWeight) / float32(minWeight)
 	// HAProxy weight must be between 0..256.
 	// weightFactor has how many times the max weight will be greater than 256.
-	weightFactor := weightFactorMin * float32(maxWeight) / float32(256*gcdGroupWeight)
+	weightFactor := weightFactorMin * maxWeight / float32(256*gcdGroupWeight)
 	// LCM of denominators and GCD of the results are known. Updating ep.Weight
 	for _, dw := range deployWeights {
-		for _, ep := range dw.endpoints {
+		for i, ep := range dw.endpoints {
 			weight := weightFactorMin * float32(dw.weight*lcmCount) / float32(len(dw.endpoints)*gcdGroupWeight)
+			weight *= dw.podFactor(i)
 			if weightFactor > 1 {
 				propWeight := int(weight / weightFactor)
 				if propWeight == 0 && dw.weight > 0 {
@@ -319,6 +399,242 @@ func (c *updater) buildBackendBlueGreen(d *backData) {
 	}
 }
 
+// isRevisionGroup checks if a blue/green group references the current
+// or the previous ReplicaSet of a Deployment
+func isRevisionGroup(dw *deployWeight) bool {
+	return dw.labelName == appsv1.DefaultDeploymentUniqueLabelKey &&
+		(dw.labelValue == "current" || dw.labelValue == "previous")
+}
+
+// blueGreenMatch checks if a pod is part of a blue/green group. Besides pod
+// labels, a group can reference the pods of a Deployment, or the pods of the
+// current or previous ReplicaSet of a Deployment.
+func blueGreenMatch(pod *api.Pod, dw *deployWeight, revisions map[string]string) bool {
+	if dw.labelName == blueGreenDeployment {
+		_, _, deployment := podController(pod)
+		return deployment == dw.labelValue
+	}
+	if isRevisionGroup(dw) {
+		_, name, _ := podController(pod)
+		return revisions[name] == dw.labelValue
+	}
+	label, found := pod.Labels[dw.labelName]
+	return found && label == dw.labelValue
+}
+
+// replicaSetRevisions maps the ReplicaSets of the pods of a backend to `current`,
+// the last revision of their Deployment, or `previous`, the revision before the
+// last one. Only ReplicaSets with pods in the backend are considered.
+func (c *updater) replicaSetRevisions(d *backData) map[string]string {
+	type replicaSet struct {
+		name     string
+		revision int
+	}
+	deployments := map[string][]*replicaSet{}
+	read := map[string]bool{}
+	for _, ep := range d.backend.Endpoints {
+		pod, err := c.cache.GetPod(ep.TargetRef)
+		if err != nil {
+			// warned by blue/green balance
+			continue
+		}
+		kind, name, deployment := podController(pod)
+		if kind != "ReplicaSet" || deployment == "" || read[name] {
+			continue
+		}
+		read[name] = true
+		rs, err := c.cache.GetReplicaSet(pod.Namespace, name)
+		if err != nil {
+			c.logger.Warn("ignoring replicaset of backend '%s' on blue/green revision: %v", d.backend.ID, err)
+			continue
+		}
+		revision, err := strconv.Atoi(rs.Annotations[revisionAnnotation])
+		if err != nil {
+			c.logger.Warn("ignoring replicaset '%s/%s' on blue/green revision: invalid revision '%s'", rs.Namespace, rs.Name, rs.Annotations[revisionAnnotation])
+			continue
+		}
+		deployments[deployment] = append(deployments[deployment], &replicaSet{name: name, revision: revision})
+	}
+	revisions := map[string]string{}
+	for _, replicaSets := range deployments {
+		sort.Slice(replicaSets, func(i, j int) bool {
+			return replicaSets[i].revision > replicaSets[j].revision
+		})
+		revisions[replicaSets[0].name] = "current"
+		if len(replicaSets) > 1 {
+			revisions[replicaSets[1].name] = "previous"
+		}
+	}
+	return revisions
+}
+
+// buildBlueGreenSchedule changes the weights of a blue/green balance with two
+// groups: the first one receives the percentage of the current step and the
+// second one the remaining. Steps are advanced by the controller, and paused
+// while the error rate of the first group is above the configured threshold.
+// Returns true if the weights were changed.
+func (c *updater) buildBlueGreenSchedule(d *backData, deployWeights []*deployWeight) bool {
+	if c.rollouts == nil {
+		return false
+	}
+	backname := d.backend.ID
+	schedule := d.mapper.Get(ingtypes.BackBlueGreenSchedule)
+	if schedule.Source == nil || schedule.Value == "" {
+		c.rollouts.SetRollout(backname, nil)
+		return false
+	}
+	var steps []int
+	for _, step := range strings.Split(schedule.Value, ",") {
+		weight, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(step), "%"))
+		if err != nil || weight < 0 || weight > 100 {
+			c.logger.Warn("ignoring blue/green schedule on %v: invalid percentage '%s'", schedule.Source, step)
+			return false
+		}
+		steps = append(steps, weight)
+	}
+	if len(deployWeights) != 2 {
+		c.logger.Warn("ignoring blue/green schedule on %v: balance should have two groups, found %d", schedule.Source, len(deployWeights))
+		return false
+	}
+	intervalCfg := d.mapper.Get(ingtypes.BackBlueGreenInterval)
+	interval, err := time.ParseDuration(intervalCfg.Value)
+	if err != nil || interval <= 0 {
+		c.logger.Warn("ignoring blue/green schedule on %v: invalid interval '%s'", schedule.Source, intervalCfg.Value)
+		return false
+	}
+	var maxErrorRate float64
+	errorRate := d.mapper.Get(ingtypes.BackBlueGreenErrorRate)
+	checkErrors := errorRate.Value != ""
+	if checkErrors {
+		maxErrorRate, err = strconv.ParseFloat(strings.TrimSuffix(errorRate.Value, "%"), 64)
+		if err != nil {
+			c.logger.Warn("ignoring blue/green schedule error rate on %v: %s", errorRate.Source, errorRate.Value)
+			checkErrors = false
+		}
+	}
+	canary := deployWeights[0]
+	if len(canary.endpoints) == 0 {
+		// the schedule starts again when the first group has endpoints
+		c.rollouts.SetRollout(backname, nil)
+		return false
+	}
+
+	// the schedule is restarted if the controllers of the first group change,
+	// eg a new revision of a Deployment
+	var pods []string
+	controllers := map[string]bool{}
+	for _, pod := range canary.pods {
+		pods = append(pods, pod.Name)
+		if _, name, _ := podController(pod); name != "" {
+			controllers[name] = true
+		}
+	}
+	group := canary.labelName + "=" + canary.labelValue
+	var names []string
+	for name := range controllers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		group += ":" + strings.Join(names, ",")
+	}
+	readCounters := func() []int64 {
+		counters, err := c.rollouts.ReadCounters(backname, pods, d.mapper.Get(ingtypes.BackBlueGreenErrorMetric).Value, "stot")
+		if err != nil {
+			c.logger.Warn("error reading counters of blue/green schedule of backend '%s': %v", backname, err)
+			return nil
+		}
+		return counters
+	}
+
+	now := c.rollouts.Now()
+	last := len(steps) - 1
+	state := c.rollouts.GetRollout(backname)
+	if state == nil || state.Group != group {
+		// first sync of the group, or the controller was restarted. The schedule
+		// always starts on the first step, the state of the former controller is
+		// unknown, eg it might be paused due to the error rate
+		state = &convtypes.RolloutState{
+			Group:     group,
+			Step:      0,
+			StepStart: now,
+		}
+		if checkErrors {
+			state.Counters = readCounters()
+		}
+		c.logger.InfoV(2, "starting blue/green schedule of backend '%s' on %d%%", backname, steps[0])
+	} else if state.Step < last && !now.Before(state.StepStart.Add(interval)) {
+		step := state.Step
+		if checkErrors {
+			// a single step is advanced per check, the error rate of the
+			// skipped steps would never be checked
+			c.checkBlueGreenErrors(backname, state, readCounters(), maxErrorRate, now)
+		} else {
+			for state.Step < last && !now.Before(state.StepStart.Add(interval)) {
+				state.Step++
+				state.StepStart = state.StepStart.Add(interval)
+			}
+		}
+		if state.Step != step {
+			c.logger.InfoV(2, "blue/green schedule of backend '%s' advanced to %d%%", backname, steps[state.Step])
+		}
+	}
+	c.rollouts.SetRollout(backname, state)
+	if state.Step < last {
+		c.rollouts.ScheduleSync(state.StepStart.Add(interval))
+	}
+	canary.weight = steps[state.Step]
+	deployWeights[1].weight = 100 - canary.weight
+	return true
+}
+
+// checkBlueGreenErrors advances the step of a schedule if the error rate since
+// the last check is below the threshold, otherwise the schedule is paused.
+// A new check is made after another interval.
+func (c *updater) checkBlueGreenErrors(backname string, state *convtypes.RolloutState, counters []int64, maxErrorRate float64, now time.Time) {
+	lastCounters := state.Counters
+	state.Counters = counters
+	state.StepStart = now
+	if counters == nil || len(lastCounters) != len(counters) || counters[0] < lastCounters[0] || counters[1] < lastCounters[1] {
+		// counters unknown or reset, eg HAProxy was reloaded
+		return
+	}
+	var rate float64
+	if sessions := counters[1] - lastCounters[1]; sessions > 0 {
+		rate = float64(counters[0]-lastCounters[0]) * 100 / float64(sessions)
+	}
+	if rate > maxErrorRate {
+		if !state.Paused {
+			c.logger.Warn("pausing blue/green schedule of backend '%s': error rate %.2f%% is above %g%%", backname, rate, maxErrorRate)
+		}
+		state.Paused = true
+		return
+	}
+	if state.Paused {
+		c.logger.Info("resuming blue/green schedule of backend '%s': error rate %.2f%%", backname, rate)
+	}
+	state.Paused = false
+	state.Step++
+}
+
+// podBalanceWeight applies the weight of a pod, which is relative to
+// `initial-weight`, to the blue/green weight of its deployment. This is
+// the same proportion readPodWeight applies on backends without blue/green,
+// where non annotated pods have `initial-weight`.
+func podBalanceWeight(weight, podWeight, initialWeight int) int {
+	if initialWeight <= 0 || podWeight == initialWeight {
+		return weight
+	}
+	w := weight * podWeight / initialWeight
+	if w > 256 {
+		w = 256
+	}
+	if w == 0 && weight > 0 {
+		w = 1
+	}
+	return w
+}
+
 func (c *updater) buildBackendBodySize(d *backData) {
 	config := d.mapper.GetBackendConfig(
 		d.backend,
@@ -412,9 +728,51 @@ func (c *updater) buildBackendDynamic(d *backData) {
 		DynUpdate:    d.mapper.Get(ingtypes.BackDynamicScaling).Bool(),
 		BlockSize:    d.mapper.Get(ingtypes.BackBackendServerSlotsInc).Int(),
 		MinFreeSlots: d.mapper.Get(ingtypes.BackSlotsMinFree).Int(),
+		DrainTimeout: c.validateTime(d.mapper.Get(ingtypes.BackDrainTimeout)),
+	}
+	if d.backend.Dynamic.DynUpdate && d.mapper.Get(ingtypes.BackSlotsFromHPA).Bool() {
+		d.backend.Dynamic.MinSlots = c.hpaMaxReplicas(d)
 	}
 }
 
+// hpaMaxReplicas finds the HorizontalPodAutoscalers which scale the workloads
+// of the pods of a backend, and returns the highest maxReplicas.
+func (c *updater) hpaMaxReplicas(d *backData) int {
+	workloads := map[string]bool{}
+	for _, ep := range d.backend.Endpoints {
+		if ep.TargetRef == "" || ep.Backup {
+			continue
+		}
+		pod, err := c.cache.GetPod(ep.TargetRef)
+		if err != nil {
+			c.logger.Warn("ignoring pod of backend '%s' on HPA lookup: %v", d.backend.ID, err)
+			continue
+		}
+		if kind, name, deployment := podController(pod); kind != "" {
+			workloads[kind+"/"+name] = true
+			if deployment != "" {
+				workloads["Deployment/"+deployment] = true
+			}
+		}
+	}
+	hpas, err := c.cache.GetHPAList(d.backend.Namespace)
+	if err != nil {
+		c.logger.Warn("error reading HPAs of backend '%s': %v", d.backend.ID, err)
+		return 0
+	}
+	maxReplicas := 0
+	for _, hpa := range hpas {
+		target := hpa.Spec.ScaleTargetRef
+		if workloads[target.Kind+"/"+target.Name] && int(hpa.Spec.MaxReplicas) > maxReplicas {
+			maxReplicas = int(hpa.Spec.MaxReplicas)
+		}
+	}
+	if maxReplicas == 0 && len(workloads) > 0 {
+		c.logger.Warn("HPA not found for the pods of backend '%s', using slots-min-free only", d.backend.ID)
+	}
+	return maxReplicas
+}
+
 func (c *updater) buildBackendAgentCheck(d *backData) {
 	d.backend.AgentCheck.Addr = d.mapper.Get(ingtypes.BackAgentCheckAddr).Value
 	d.backend.AgentCheck.Interval = c.validateTime(d.mapper.Get(ingtypes.BackAgentCheckInterval))
@@ -422,17 +780,273 @@ func (c *updater) buildBackendAgentCheck(d *backData) {
 	d.backend.AgentCheck.Send = d.mapper.Get(ingtypes.BackAgentCheckSend).Value
 }
 
+var (
+	healthCheckMethodRegex = regexp.MustCompile(`^[A-Z]+$`)
+	healthCheckHostRegex   = regexp.MustCompile(`^[A-Za-z0-9.:-]+$`)
+	healthCheckStatusRegex = regexp.MustCompile(`^[1-5][0-9x]{2}$`)
+	// escapes chars that HAProxy would otherwise read as
+	// a word separator, a comment or a quoted string
+	healthCheckBodyEscaper = strings.NewReplacer(`\`, `\\`, " ", `\ `, "\t", `\t`, "#", `\#`, `"`, `\"`, `'`, `\'`)
+)
+
 func (c *updater) buildBackendHealthCheck(d *backData) {
-	d.backend.HealthCheck.Addr = d.mapper.Get(ingtypes.BackHealthCheckAddr).Value
-	d.backend.HealthCheck.FallCount = d.mapper.Get(ingtypes.BackHealthCheckFallCount).Int()
+	hc := &d.backend.HealthCheck
+	hc.Addr = d.mapper.Get(ingtypes.BackHealthCheckAddr).Value
+	hc.FallCount = d.mapper.Get(ingtypes.BackHealthCheckFallCount).Int()
 	interval := d.mapper.Get(ingtypes.BackHealthCheckInterval)
 	if interval.Value == "" {
 		interval = d.mapper.Get(ingtypes.BackBackendCheckInterval)
 	}
-	d.backend.HealthCheck.Interval = c.validateTime(interval)
-	d.backend.HealthCheck.Port = d.mapper.Get(ingtypes.BackHealthCheckPort).Int()
-	d.backend.HealthCheck.RiseCount = d.mapper.Get(ingtypes.BackHealthCheckRiseCount).Int()
-	d.backend.HealthCheck.URI = d.mapper.Get(ingtypes.BackHealthCheckURI).Value
+	hc.Interval = c.validateTime(interval)
+	hc.Port = d.mapper.Get(ingtypes.BackHealthCheckPort).Int()
+	hc.RiseCount = d.mapper.Get(ingtypes.BackHealthCheckRiseCount).Int()
+	hc.URI = d.mapper.Get(ingtypes.BackHealthCheckURI).Value
+	if method := d.mapper.Get(ingtypes.BackHealthCheckMethod); method.Value != "" {
+		if healthCheckMethodRegex.MatchString(method.Value) {
+			hc.Method = method.Value
+		} else {
+			c.logger.Warn("ignoring invalid health check method on %v: %s", method.Source, method.Value)
+		}
+	}
+	if host := d.mapper.Get(ingtypes.BackHealthCheckHost); host.Value != "" {
+		if healthCheckHostRegex.MatchString(host.Value) {
+			hc.Host = host.Value
+		} else {
+			c.logger.Warn("ignoring invalid health check host on %v: %s", host.Source, host.Value)
+		}
+	}
+	if status := d.mapper.Get(ingtypes.BackHealthCheckExpStatus); status.Value != "" {
+		hc.ExpectStatus = c.healthCheckStatusRegex(status)
+	}
+	if body := d.mapper.Get(ingtypes.BackHealthCheckExpBody); body.Value != "" {
+		if hc.ExpectStatus != "" {
+			// HAProxy 1.8 supports only one http-check expect per backend
+			c.logger.Warn("ignoring health check expected body on %v: expected status is also configured", body.Source)
+		} else if strings.ContainsAny(body.Value, "\r\n") {
+			c.logger.Warn("ignoring invalid health check expected body on %v: line breaks are not allowed", body.Source)
+		} else {
+			hc.ExpectBody = healthCheckBodyEscaper.Replace(body.Value)
+		}
+	}
+	hc.SSL = d.mapper.Get(ingtypes.BackHealthCheckSSL).Bool()
+	if d.mapper.Get(ingtypes.BackHealthCheckFromProbe).Bool() {
+		if probe := c.readinessProbe(d); probe != nil {
+			// options declared as annotations or global config have precedence
+			if !c.isExplicit(d.mapper, ingtypes.BackHealthCheckInterval) {
+				hc.Interval = probe.interval
+			}
+			if hc.URI == "" {
+				hc.URI = probe.uri
+			}
+			if hc.Port == 0 {
+				hc.Port = probe.port
+			}
+			if hc.Host == "" {
+				hc.Host = probe.host
+			}
+			if hc.Method == "" {
+				hc.Method = "GET"
+			}
+			if hc.RiseCount == 0 {
+				hc.RiseCount = probe.riseCount
+			}
+			if hc.FallCount == 0 {
+				hc.FallCount = probe.fallCount
+			}
+			hc.SSL = hc.SSL || probe.ssl
+		}
+	}
+	if hc.Host != "" && hc.Method == "" {
+		// HAProxy needs the method to add the HTTP version and the Host
+		// header, OPTIONS is also its default method
+		hc.Method = "OPTIONS"
+	}
+	if hc.URI == "" && (hc.Method != "" || hc.ExpectStatus != "" || hc.ExpectBody != "") {
+		hc.URI = "/"
+	}
+	hc.SNI = d.mapper.Get(ingtypes.BackHealthCheckSNI).Value
+	if hc.SNI == "" {
+		hc.SNI = hc.Host
+	}
+	hc.DownInterval = c.validateTime(d.mapper.Get(ingtypes.BackHealthCheckDownInter))
+}
+
+// healthCheckProbe is a health check built from an httpGet readinessProbe
+type healthCheckProbe struct {
+	uri       string
+	port      int
+	host      string
+	ssl       bool
+	interval  string
+	riseCount int
+	fallCount int
+}
+
+// readinessProbe reads the httpGet readinessProbe of the pods of a backend.
+// The probe is only used if all the pods declare the same one.
+func (c *updater) readinessProbe(d *backData) *healthCheckProbe {
+	var probe *healthCheckProbe
+	var created time.Time
+	var distinct bool
+	for _, ep := range d.backend.Endpoints {
+		if ep.TargetRef == "" {
+			continue
+		}
+		pod, err := c.cache.GetPod(ep.TargetRef)
+		if err != nil {
+			// eg the pod was already removed and the endpoints weren't updated yet
+			c.logger.InfoV(2, "skipping pod on readiness probe of backend '%s': %v", d.backend.ID, err)
+			continue
+		}
+		podProbe, err := podReadinessProbe(pod, ep.Port)
+		if err != nil {
+			c.logger.Warn("ignoring readiness probe of backend '%s': %v", d.backend.ID, err)
+			return nil
+		}
+		if probe != nil && *probe != *podProbe {
+			distinct = true
+		}
+		// the probe of the oldest pod is used while pods declare distinct probes,
+		// eg in the middle of a rolling update, so the health check changes only
+		// once, when the pods of the former revision are gone
+		if probe == nil || pod.CreationTimestamp.Time.Before(created) {
+			probe = podProbe
+			created = pod.CreationTimestamp.Time
+		}
+	}
+	if distinct {
+		c.logger.InfoV(2, "pods of backend '%s' declare distinct readiness probes, using the probe of the oldest one", d.backend.ID)
+	}
+	if probe != nil && probe.ssl && !d.mapper.Get(ingtypes.BackSecureBackends).Bool() {
+		c.logger.Warn("ignoring readiness probe of backend '%s': HTTPS probes need secure-backends", d.backend.ID)
+		return nil
+	}
+	return probe
+}
+
+// podReadinessProbe reads the readinessProbe of the container which exposes
+// the port of the endpoint, or of the only container of the pod
+func podReadinessProbe(pod *api.Pod, port int) (*healthCheckProbe, error) {
+	podName := pod.Namespace + "/" + pod.Name
+	var container *api.Container
+	for i := range pod.Spec.Containers {
+		for _, containerPort := range pod.Spec.Containers[i].Ports {
+			if int(containerPort.ContainerPort) == port {
+				container = &pod.Spec.Containers[i]
+			}
+		}
+	}
+	if container == nil && len(pod.Spec.Containers) == 1 {
+		container = &pod.Spec.Containers[0]
+	}
+	if container == nil {
+		return nil, fmt.Errorf("container of port %d not found on pod '%s'", port, podName)
+	}
+	readiness := container.ReadinessProbe
+	if readiness == nil || readiness.HTTPGet == nil {
+		return nil, fmt.Errorf("pod '%s' does not declare an httpGet readiness probe", podName)
+	}
+	httpGet := readiness.HTTPGet
+	probePort := httpGet.Port.IntValue()
+	if probePort == 0 {
+		for _, containerPort := range container.Ports {
+			if containerPort.Name == httpGet.Port.StrVal {
+				probePort = int(containerPort.ContainerPort)
+			}
+		}
+		if probePort == 0 {
+			return nil, fmt.Errorf("port '%s' of the readiness probe not found on pod '%s'", httpGet.Port.StrVal, podName)
+		}
+	}
+	probe := &healthCheckProbe{
+		uri:       httpGet.Path,
+		ssl:       httpGet.Scheme == api.URISchemeHTTPS,
+		interval:  "10s",
+		riseCount: 1,
+		fallCount: 3,
+	}
+	if probe.uri == "" {
+		probe.uri = "/"
+	}
+	if probePort != port {
+		probe.port = probePort
+	}
+	for _, header := range httpGet.HTTPHeaders {
+		if strings.EqualFold(header.Name, "Host") {
+			if !healthCheckHostRegex.MatchString(header.Value) {
+				return nil, fmt.Errorf("invalid Host header on the readiness probe of pod '%s': %s", podName, header.Value)
+			}
+			probe.host = header.Value
+		}
+	}
+	// zero means the kubernetes defaults
+	if readiness.PeriodSeconds > 0 {
+		probe.interval = fmt.Sprintf("%ds", readiness.PeriodSeconds)
+	}
+	if readiness.SuccessThreshold > 0 {
+		probe.riseCount = int(readiness.SuccessThreshold)
+	}
+	if readiness.FailureThreshold > 0 {
+		probe.fallCount = int(readiness.FailureThreshold)
+	}
+	return probe, nil
+}
+
+// healthCheckStatusRegex converts a comma-separated list of status codes, eg
+// `200,3xx`, to a regex used by `http-check expect rstatus`, eg `^(200|3[0-9][0-9])$`
+func (c *updater) healthCheckStatusRegex(status *ConfigValue) string {
+	var codes []string
+	for _, code := range utils.Split(status.Value, ",") {
+		if !healthCheckStatusRegex.MatchString(code) {
+			c.logger.Warn("ignoring invalid health check expected status on %v: %s", status.Source, status.Value)
+			return ""
+		}
+		codes = append(codes, strings.Replace(code, "x", "[0-9]", -1))
+	}
+	return "^(" + strings.Join(codes, "|") + ")$"
+}
+
+var validOnErrorActions = map[string]bool{
+	"fastinter":    true,
+	"fail-check":   true,
+	"sudden-death": true,
+	"mark-down":    true,
+}
+
+func (c *updater) buildBackendPassiveCheck(d *backData) {
+	observe := d.mapper.Get(ingtypes.BackHealthCheckObserve)
+	switch observe.Value {
+	case "", "none":
+		return
+	case "layer4":
+	case "layer7":
+		if d.backend.ModeTCP {
+			c.logger.Warn("using layer4 health check observe mode on %v: backend is in TCP mode", observe.Source)
+			observe.Value = "layer4"
+		}
+	default:
+		c.logger.Warn("ignoring invalid health check observe mode on %v: %s", observe.Source, observe.Value)
+		return
+	}
+	hc := d.backend.HealthCheck
+	if hc.Port == 0 && hc.Addr == "" && hc.Interval == "" && hc.RiseCount == 0 && hc.FallCount == 0 {
+		// HAProxy observes the traffic only if the server has health checks
+		c.logger.Warn("ignoring health check observe mode on %v: health check is disabled", observe.Source)
+		return
+	}
+	errorLimit := d.mapper.Get(ingtypes.BackHealthCheckErrorLimit)
+	if errorLimit.Int() <= 0 {
+		c.logger.Warn("ignoring invalid health check error limit on %v: %s", errorLimit.Source, errorLimit.Value)
+		return
+	}
+	onError := d.mapper.Get(ingtypes.BackHealthCheckOnError)
+	if !validOnErrorActions[onError.Value] {
+		c.logger.Warn("ignoring invalid health check on error action on %v: %s", onError.Source, onError.Value)
+		return
+	}
+	d.backend.Server.Observe = observe.Value
+	d.backend.Server.ErrorLimit = errorLimit.Int()
+	d.backend.Server.OnError = onError.Value
 }
 
 func (c *updater) buildBackendHSTS(d *backData) {
@@ -541,6 +1155,63 @@ var (
 	rewriteURLRegex = regexp.MustCompile(`^[^"' ]*$`)
 )
 
+var validRetryOnConditions = map[string]bool{
+	"none":                 true,
+	"conn-failure":         true,
+	"empty-response":       true,
+	"junk-response":        true,
+	"response-timeout":     true,
+	"0rtt-rejected":        true,
+	"all-retryable-errors": true,
+	"404":                  true,
+	"408":                  true,
+	"425":                  true,
+	"500":                  true,
+	"501":                  true,
+	"502":                  true,
+	"503":                  true,
+	"504":                  true,
+}
+
+func (c *updater) buildBackendRetry(d *backData) {
+	retries := d.mapper.Get(ingtypes.BackRetries)
+	if retries.Value != "" {
+		if count, err := strconv.Atoi(retries.Value); err == nil && count >= 0 {
+			d.backend.Retry.Count = strconv.Itoa(count)
+		} else {
+			c.logger.Warn("ignoring invalid retries on %v: %s", retries.Source, retries.Value)
+		}
+	}
+	// HAProxy 1.8 only retries connection failures, which is the only retry-on
+	// condition it supports. Requests weren't sent on connection failures, so
+	// non idempotent methods are also safe and retry-non-idempotent isn't needed.
+	retryOn := d.mapper.Get(ingtypes.BackRetryOn)
+	for _, cond := range utils.Split(retryOn.Value, ",") {
+		switch {
+		case cond == "", cond == "conn-failure":
+		case cond == "none":
+			if d.backend.Retry.Count != "" && d.backend.Retry.Count != "0" {
+				c.logger.Warn("retry-on 'none' on %v overrides retries '%s' on %v", retryOn.Source, d.backend.Retry.Count, retries.Source)
+			}
+			d.backend.Retry.Count = "0"
+		case validRetryOnConditions[cond]:
+			c.logger.Warn("ignoring retry-on condition on %v: '%s' isn't supported by HAProxy 1.8", retryOn.Source, cond)
+		default:
+			c.logger.Warn("ignoring invalid retry-on condition on %v: %s", retryOn.Source, cond)
+		}
+	}
+	if nonIdempotent := d.mapper.Get(ingtypes.BackRetryNonIdempotent); nonIdempotent.Bool() {
+		c.logger.Warn("ignoring retry-non-idempotent on %v: HAProxy 1.8 only retries requests not sent yet", nonIdempotent.Source)
+	}
+	if redispatch := d.mapper.Get(ingtypes.BackRedispatchInterval); redispatch.Value != "" {
+		if interval, err := strconv.Atoi(redispatch.Value); err == nil {
+			d.backend.Retry.Redispatch = strconv.Itoa(interval)
+		} else {
+			c.logger.Warn("ignoring invalid redispatch interval on %v: %s", redispatch.Source, redispatch.Value)
+		}
+	}
+}
+
 func (c *updater) buildBackendRewriteURL(d *backData) {
 	config := d.mapper.GetBackendConfig(
 		d.backend,
@@ -692,3 +1363,25 @@ func (c *updater) buildBackendWhitelistTCP(d *backData) {
 	}
 	d.backend.WhitelistTCP = c.splitCIDR(wlist)
 }
+
+func (c *updater) buildBackendZoneAware(d *backData) {
+	c.zoneAware(d.backend.ID, d.mapper, &d.backend.Dynamic, d.backend.Endpoints)
+}
+
+// podController returns the kind and name of the controller of a pod. The
+// Deployment is also returned if the controller is a ReplicaSet created by
+// a Deployment, which is named as the Deployment followed by the
+// pod-template-hash label.
+func podController(pod *api.Pod) (kind, name, deployment string) {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		if owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			deployment = strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+		return owner.Kind, owner.Name, deployment
+	}
+	return "", "", ""
+}
@@ -20,12 +20,17 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v1"
 	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
@@ -306,6 +311,80 @@ usr2::clearpwd2`)}},
 	}
 }
 
+func TestAuthTLSPath(t *testing.T) {
+	testCases := []struct {
+		paths    []string
+		source   Source
+		ann      map[string]map[string]string
+		expected []*hatypes.BackendConfigAuthTLS
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: []*hatypes.BackendConfigAuthTLS{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: hatypes.AuthTLS{},
+				},
+			},
+		},
+		// 1
+		{
+			paths: []string{"/", "/admin"},
+			ann: map[string]map[string]string{
+				"/": {},
+				"/admin": {
+					ingtypes.BackAuthTLSCertRequired: "true",
+					ingtypes.BackAuthTLSMatchCN:      "admin, John Doe",
+					ingtypes.BackAuthTLSMatchOU:      "ops",
+				},
+			},
+			expected: []*hatypes.BackendConfigAuthTLS{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: hatypes.AuthTLS{},
+				},
+				{
+					Paths: createBackendPaths("/admin"),
+					Config: hatypes.AuthTLS{
+						CertRequired: true,
+						MatchCN:      []string{"admin", "John Doe"},
+						MatchOU:      []string{"ops"},
+					},
+				},
+			},
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthTLSMatchCN: `admin,,"user"`,
+				},
+			},
+			expected: []*hatypes.BackendConfigAuthTLS{
+				{
+					Paths: createBackendPaths("/"),
+					Config: hatypes.AuthTLS{
+						MatchCN: []string{"admin"},
+					},
+				},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring certificate subject field with quotes on ingress 'default/ing1': "user"`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &test.source, map[string]string{}, test.ann, test.paths)
+		c.createUpdater().buildBackendAuthTLS(d)
+		c.compareObjects("auth-tls path", i, d.backend.AuthTLS, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestBlueGreen(t *testing.T) {
 	buildPod := func(labels string) *api.Pod {
 		l := make(map[string]string)
@@ -586,6 +665,27 @@ INFO-V(3) blue/green balance label 'v=3' on ingress 'default/ing1' does not refe
 			expWeights: []int{100, 100, 200, 0},
 			expLogging: "",
 		},
+		// 30
+		{
+			ann:        buildAnn("v=1=50,v=2=50", "pod"),
+			endpoints:  buildEndpoints("pod0101-01=200,pod0101-02,pod0102-01=50"),
+			expWeights: []int{100, 50, 25},
+			expLogging: "",
+		},
+		// 31
+		{
+			ann:        buildAnn("v=1=50,v=2=50", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01=200,pod0101-02,pod0102-01"),
+			expWeights: []int{133, 66, 200},
+			expLogging: "",
+		},
+		// 32
+		{
+			ann:        buildAnn("v=1=255,v=2=2", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01=200,pod0101-02,pod0102-01"),
+			expWeights: []int{256, 128, 3},
+			expLogging: "",
+		},
 	}
 
 	source := &Source{
@@ -610,6 +710,284 @@ INFO-V(3) blue/green balance label 'v=3' on ingress 'default/ing1' does not refe
 	}
 }
 
+func buildReplicaSetPod(name, rs, hash string, created time.Time) *api.Pod {
+	isController := true
+	return &api.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: meta.NewTime(created),
+			Labels:            map[string]string{"pod-template-hash": hash},
+			OwnerReferences: []meta.OwnerReference{
+				{Kind: "ReplicaSet", Name: rs, Controller: &isController},
+			},
+		},
+	}
+}
+
+func buildReplicaSet(name, revision string) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: meta.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{"deployment.kubernetes.io/revision": revision},
+		},
+	}
+}
+
+func TestBlueGreenRevision(t *testing.T) {
+	var t0 time.Time
+	pods := map[string]*api.Pod{
+		"default/app-aaa-1": buildReplicaSetPod("app-aaa-1", "app-aaa", "aaa", t0),
+		"default/app-aaa-2": buildReplicaSetPod("app-aaa-2", "app-aaa", "aaa", t0),
+		"default/app-bbb-1": buildReplicaSetPod("app-bbb-1", "app-bbb", "bbb", t0),
+		"default/app-ddd-1": buildReplicaSetPod("app-ddd-1", "app-ddd", "ddd", t0),
+		"default/web-ccc-1": buildReplicaSetPod("web-ccc-1", "web-ccc", "ccc", t0),
+	}
+	replicaSets := map[string]*appsv1.ReplicaSet{
+		"default/app-aaa": buildReplicaSet("app-aaa", "1"),
+		"default/app-bbb": buildReplicaSet("app-bbb", "2"),
+		"default/web-ccc": buildReplicaSet("web-ccc", "5"),
+	}
+	testCases := []struct {
+		balance    string
+		targets    []string
+		expWeights []int
+		logging    string
+	}{
+		// 0
+		{
+			balance:    "pod-template-hash=current=10,pod-template-hash=previous=90",
+			targets:    []string{"default/app-aaa-1", "default/app-aaa-2", "default/app-bbb-1"},
+			expWeights: []int{90, 90, 10},
+		},
+		// 1
+		{
+			balance:    "@deployment=web=30,@deployment=app=70",
+			targets:    []string{"default/app-aaa-1", "default/web-ccc-1"},
+			expWeights: []int{70, 30},
+		},
+		// 2
+		{
+			balance:    "pod-template-hash=current=10,pod-template-hash=previous=90",
+			targets:    []string{"default/app-aaa-1", "default/app-ddd-1"},
+			expWeights: []int{10, 0},
+			logging: `
+WARN ignoring replicaset of backend 'default_app_8080' on blue/green revision: replicaset not found: 'default/app-ddd'
+INFO-V(3) blue/green balance label 'pod-template-hash=previous' on ingress 'default/ing1' does not reference any endpoint`,
+		},
+		// 3
+		{
+			balance:    "pod-template-hash=bbb=10,pod-template-hash=aaa=90",
+			targets:    []string{"default/app-aaa-1", "default/app-bbb-1"},
+			expWeights: []int{90, 10},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		c.cache.RSList = replicaSets
+		ann := map[string]string{
+			ingtypes.BackBlueGreenBalance: test.balance,
+			ingtypes.BackBlueGreenMode:    "pod",
+		}
+		d := c.createBackendData("default/app", source, ann, map[string]string{ingtypes.BackInitialWeight: "100"})
+		for j, target := range test.targets {
+			d.backend.AcquireEndpoint("172.17.0."+strconv.Itoa(11+j), 8080, target).Weight = 100
+		}
+		c.createUpdater().buildBackendBlueGreen(d)
+		weights := make([]int, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			weights[j] = ep.Weight
+		}
+		c.compareObjects("blue/green weight", i, weights, test.expWeights)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestBlueGreenSchedule(t *testing.T) {
+	t0 := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return t0.Add(time.Duration(minutes) * time.Minute)
+	}
+	pods := map[string]*api.Pod{
+		"default/app-aaa-1": buildReplicaSetPod("app-aaa-1", "app-aaa", "aaa", at(-60)),
+		"default/app-aaa-2": buildReplicaSetPod("app-aaa-2", "app-aaa", "aaa", at(-60)),
+		"default/app-bbb-1": buildReplicaSetPod("app-bbb-1", "app-bbb", "bbb", t0),
+	}
+	replicaSets := map[string]*appsv1.ReplicaSet{
+		"default/app-aaa": buildReplicaSet("app-aaa", "1"),
+		"default/app-bbb": buildReplicaSet("app-bbb", "2"),
+	}
+	group := "pod-template-hash=current:app-bbb"
+	testCases := []struct {
+		ann        map[string]string
+		now        time.Time
+		state      *convtypes.RolloutState
+		counters   []int64
+		expWeights []int
+		expState   *convtypes.RolloutState
+		expSyncs   []time.Time
+		logging    string
+	}{
+		// 0
+		{
+			now:        at(1),
+			expWeights: []int{256, 256, 56},
+			expState:   &convtypes.RolloutState{Group: group, Step: 0, StepStart: at(1)},
+			expSyncs:   []time.Time{at(11)},
+			logging:    `INFO-V(2) starting blue/green schedule of backend 'default_app_8080' on 10%`,
+		},
+		// 1
+		{
+			now:        at(25),
+			expWeights: []int{256, 256, 56},
+			expState:   &convtypes.RolloutState{Group: group, Step: 0, StepStart: at(25)},
+			expSyncs:   []time.Time{at(35)},
+			logging:    `INFO-V(2) starting blue/green schedule of backend 'default_app_8080' on 10%`,
+		},
+		// 2
+		{
+			now:        at(10),
+			state:      &convtypes.RolloutState{Group: group, Step: 0, StepStart: t0},
+			expWeights: []int{100, 100, 200},
+			expState:   &convtypes.RolloutState{Group: group, Step: 1, StepStart: at(10)},
+			expSyncs:   []time.Time{at(20)},
+			logging:    `INFO-V(2) blue/green schedule of backend 'default_app_8080' advanced to 50%`,
+		},
+		// 3
+		{
+			now:        at(5),
+			state:      &convtypes.RolloutState{Group: group, Step: 0, StepStart: t0},
+			expWeights: []int{256, 256, 56},
+			expState:   &convtypes.RolloutState{Group: group, Step: 0, StepStart: t0},
+			expSyncs:   []time.Time{at(10)},
+		},
+		// 4
+		{
+			now:        at(1),
+			state:      &convtypes.RolloutState{Group: "pod-template-hash=current:app-zzz", Step: 2, StepStart: at(-10)},
+			expWeights: []int{256, 256, 56},
+			expState:   &convtypes.RolloutState{Group: group, Step: 0, StepStart: at(1)},
+			expSyncs:   []time.Time{at(11)},
+			logging:    `INFO-V(2) starting blue/green schedule of backend 'default_app_8080' on 10%`,
+		},
+		// 5
+		{
+			ann:        map[string]string{ingtypes.BackBlueGreenErrorRate: "5"},
+			now:        at(10),
+			state:      &convtypes.RolloutState{Group: group, Step: 0, StepStart: t0, Counters: []int64{10, 1000}},
+			counters:   []int64{20, 1100},
+			expWeights: []int{256, 256, 56},
+			expState:   &convtypes.RolloutState{Group: group, Step: 0, StepStart: at(10), Paused: true, Counters: []int64{20, 1100}},
+			expSyncs:   []time.Time{at(20)},
+			logging:    `WARN pausing blue/green schedule of backend 'default_app_8080': error rate 10.00% is above 5%`,
+		},
+		// 6
+		{
+			ann:        map[string]string{ingtypes.BackBlueGreenErrorRate: "5%"},
+			now:        at(20),
+			state:      &convtypes.RolloutState{Group: group, Step: 0, StepStart: at(10), Paused: true, Counters: []int64{10, 1000}},
+			counters:   []int64{11, 1100},
+			expWeights: []int{100, 100, 200},
+			expState:   &convtypes.RolloutState{Group: group, Step: 1, StepStart: at(20), Counters: []int64{11, 1100}},
+			expSyncs:   []time.Time{at(30)},
+			logging: `
+INFO resuming blue/green schedule of backend 'default_app_8080': error rate 1.00%
+INFO-V(2) blue/green schedule of backend 'default_app_8080' advanced to 50%`,
+		},
+		// 7
+		{
+			ann:        map[string]string{ingtypes.BackBlueGreenErrorRate: "5"},
+			now:        at(10),
+			state:      &convtypes.RolloutState{Group: group, Step: 0, StepStart: t0, Counters: []int64{10, 1000}},
+			counters:   []int64{1, 10},
+			expWeights: []int{256, 256, 56},
+			expState:   &convtypes.RolloutState{Group: group, Step: 0, StepStart: at(10), Counters: []int64{1, 10}},
+			expSyncs:   []time.Time{at(20)},
+		},
+		// 8
+		{
+			ann:        map[string]string{ingtypes.BackBlueGreenErrorRate: "5"},
+			now:        at(10),
+			state:      &convtypes.RolloutState{Group: group, Step: 0, StepStart: t0, Counters: []int64{10, 1000}},
+			expWeights: []int{256, 256, 56},
+			expState:   &convtypes.RolloutState{Group: group, Step: 0, StepStart: at(10)},
+			expSyncs:   []time.Time{at(20)},
+			logging:    `WARN error reading counters of blue/green schedule of backend 'default_app_8080': backend not found: 'default_app_8080'`,
+		},
+		// 9
+		{
+			ann:        map[string]string{ingtypes.BackBlueGreenSchedule: "10,abc"},
+			now:        at(1),
+			expWeights: []int{100, 100, 0},
+			logging:    `WARN ignoring blue/green schedule on ingress 'default/ing1': invalid percentage 'abc'`,
+		},
+		// 10
+		{
+			ann:        map[string]string{ingtypes.BackBlueGreenInterval: "1d"},
+			now:        at(1),
+			expWeights: []int{100, 100, 0},
+			logging:    `WARN ignoring blue/green schedule on ingress 'default/ing1': invalid interval '1d'`,
+		},
+		// 11
+		{
+			ann:        map[string]string{ingtypes.BackBlueGreenErrorRate: "5"},
+			now:        at(35),
+			state:      &convtypes.RolloutState{Group: group, Step: 0, StepStart: t0, Counters: []int64{10, 1000}},
+			counters:   []int64{11, 1100},
+			expWeights: []int{100, 100, 200},
+			expState:   &convtypes.RolloutState{Group: group, Step: 1, StepStart: at(35), Counters: []int64{11, 1100}},
+			expSyncs:   []time.Time{at(45)},
+			logging:    `INFO-V(2) blue/green schedule of backend 'default_app_8080' advanced to 50%`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackBlueGreenErrorMetric: "hrsp_5xx",
+		ingtypes.BackBlueGreenInterval:    "10m",
+		ingtypes.BackInitialWeight:        "100",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		c.cache.RSList = replicaSets
+		rollouts := conv_helper.NewRolloutsMock()
+		rollouts.NowTime = test.now
+		if test.state != nil {
+			rollouts.States["default_app_8080"] = test.state
+		}
+		if test.counters != nil {
+			rollouts.Counters["default_app_8080"] = test.counters
+		}
+		ann := map[string]string{
+			ingtypes.BackBlueGreenBalance:  "pod-template-hash=current=0,pod-template-hash=previous=100",
+			ingtypes.BackBlueGreenSchedule: "10,50,100",
+		}
+		for name, value := range test.ann {
+			ann[name] = value
+		}
+		d := c.createBackendData("default/app", source, ann, annDefault)
+		for j, target := range []string{"default/app-aaa-1", "default/app-aaa-2", "default/app-bbb-1"} {
+			d.backend.AcquireEndpoint("172.17.0."+strconv.Itoa(11+j), 8080, target).Weight = 100
+		}
+		u := c.createUpdater()
+		u.rollouts = rollouts
+		u.buildBackendBlueGreen(d)
+		weights := make([]int, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			weights[j] = ep.Weight
+		}
+		c.compareObjects("blue/green weight", i, weights, test.expWeights)
+		c.compareObjects("rollout state", i, rollouts.States["default_app_8080"], test.expState)
+		c.compareObjects("rollout syncs", i, rollouts.Syncs, test.expSyncs)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestBodySize(t *testing.T) {
 	testCases := []struct {
 		source     Source
@@ -621,194 +999,879 @@ func TestBodySize(t *testing.T) {
 	}{
 		// 0
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10",
-				},
-			},
-			expected: []*hatypes.BackendConfigInt{
-				{
-					Paths:  createBackendPaths("/"),
-					Config: 10,
-				},
-			},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10",
+				},
+			},
+			expected: []*hatypes.BackendConfigInt{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: 10,
+				},
+			},
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10k",
+				},
+				"/app": {
+					ingtypes.BackProxyBodySize: "10m",
+				},
+				"/sub": {
+					ingtypes.BackProxyBodySize: "10g",
+				},
+			},
+			expected: []*hatypes.BackendConfigInt{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: 10240,
+				},
+				{
+					Paths:  createBackendPaths("/app"),
+					Config: 10485760,
+				},
+				{
+					Paths:  createBackendPaths("/sub"),
+					Config: 10737418240,
+				},
+			},
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "unlimited",
+				},
+			},
+			expected: []*hatypes.BackendConfigInt{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: 0,
+				},
+			},
+		},
+		// 3
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10e",
+				},
+			},
+			expected: []*hatypes.BackendConfigInt{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: 0,
+				},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid body size on ingress 'default/ing1': 10e`,
+		},
+		// 4
+		{
+			ann: map[string]map[string]string{
+				"/app": {
+					ingtypes.BackProxyBodySize: "1m",
+				},
+			},
+			expected: []*hatypes.BackendConfigInt{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: 0,
+				},
+				{
+					Paths:  createBackendPaths("/app"),
+					Config: 1048576,
+				},
+			},
+			paths:  []string{"/"},
+			source: Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
+		c.createUpdater().buildBackendBodySize(d)
+		c.compareObjects("proxy body size", i, d.backend.MaxBodySize, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+const (
+	corsDefaultHeaders = "DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization"
+	corsDefaultMethods = "GET, PUT, POST, DELETE, PATCH, OPTIONS"
+	corsDefaultOrigin  = "*"
+	corsDefaultMaxAge  = 86400
+)
+
+func TestCors(t *testing.T) {
+	testCases := []struct {
+		paths    []string
+		ann      map[string]map[string]string
+		expected []*hatypes.BackendConfigCors
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: []*hatypes.BackendConfigCors{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: hatypes.Cors{},
+				},
+			},
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable: "true",
+				},
+			},
+			expected: []*hatypes.BackendConfigCors{
+				{
+					Paths: createBackendPaths("/"),
+					Config: hatypes.Cors{
+						Enabled:          true,
+						AllowCredentials: false,
+						AllowHeaders:     corsDefaultHeaders,
+						AllowMethods:     corsDefaultMethods,
+						AllowOrigin:      corsDefaultOrigin,
+						ExposeHeaders:    "",
+						MaxAge:           corsDefaultMaxAge,
+					},
+				},
+			},
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable: "false",
+				},
+				"/sub": {
+					ingtypes.BackCorsEnable: "true",
+				},
+			},
+			expected: []*hatypes.BackendConfigCors{
+				{
+					Paths:  createBackendPaths("/"),
+					Config: hatypes.Cors{},
+				},
+				{
+					Paths: createBackendPaths("/sub"),
+					Config: hatypes.Cors{
+						Enabled:          true,
+						AllowCredentials: false,
+						AllowHeaders:     corsDefaultHeaders,
+						AllowMethods:     corsDefaultMethods,
+						AllowOrigin:      corsDefaultOrigin,
+						ExposeHeaders:    "",
+						MaxAge:           corsDefaultMaxAge,
+					},
+				},
+			},
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackCorsAllowHeaders: corsDefaultHeaders,
+		ingtypes.BackCorsAllowMethods: corsDefaultMethods,
+		ingtypes.BackCorsAllowOrigin:  corsDefaultOrigin,
+		ingtypes.BackCorsMaxAge:       strconv.Itoa(corsDefaultMaxAge),
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &Source{}, annDefault, test.ann, test.paths)
+		c.createUpdater().buildBackendCors(d)
+		c.compareObjects("cors", i, d.backend.Cors, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestDynamicSlotsFromHPA(t *testing.T) {
+	isController := true
+	buildPod := func(name, kind, owner, hash string) *api.Pod {
+		pod := &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{},
+			},
+		}
+		if hash != "" {
+			pod.Labels["pod-template-hash"] = hash
+		}
+		if owner != "" {
+			pod.OwnerReferences = []meta.OwnerReference{
+				{Kind: kind, Name: owner, Controller: &isController},
+			}
+		}
+		return pod
+	}
+	buildHPA := func(namespace, kind, name string, maxReplicas int32) *autoscaling.HorizontalPodAutoscaler {
+		return &autoscaling.HorizontalPodAutoscaler{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: autoscaling.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscaling.CrossVersionObjectReference{
+					Kind: kind,
+					Name: name,
+				},
+				MaxReplicas: maxReplicas,
+			},
+		}
+	}
+	pods := map[string]*api.Pod{
+		"app1": buildPod("app1", "ReplicaSet", "app-7d5f8b6c9", "7d5f8b6c9"),
+		"app2": buildPod("app2", "ReplicaSet", "app-55f9cc4b7", "55f9cc4b7"),
+		"db1":  buildPod("db1", "StatefulSet", "db", ""),
+		"raw1": buildPod("raw1", "", "", ""),
+	}
+	hpas := []*autoscaling.HorizontalPodAutoscaler{
+		buildHPA("default", "Deployment", "app", 12),
+		buildHPA("default", "StatefulSet", "db", 5),
+		buildHPA("other", "Deployment", "app", 30),
+	}
+	testCases := []struct {
+		ann      map[string]string
+		targets  []string
+		expected int
+		logging  string
+	}{
+		// 0
+		{
+			targets:  []string{"app1"},
+			expected: 0,
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackSlotsFromHPA: "true",
+			},
+			targets:  []string{"app1"},
+			expected: 12,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackSlotsFromHPA: "true",
+			},
+			targets:  []string{"app1", "app2", "db1"},
+			expected: 12,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackSlotsFromHPA: "true",
+			},
+			targets:  []string{"db1"},
+			expected: 5,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackSlotsFromHPA:   "true",
+				ingtypes.BackDynamicScaling: "false",
+			},
+			targets:  []string{"app1"},
+			expected: 0,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackSlotsFromHPA: "true",
+			},
+			targets:  []string{"raw1"},
+			expected: 0,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackSlotsFromHPA: "true",
+			},
+			targets:  []string{"app3"},
+			expected: 0,
+			logging:  `WARN ignoring pod of backend 'default_app_8080' on HPA lookup: pod not found: 'app3'`,
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackDynamicScaling: "true",
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		c.cache.HPAList = hpas
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		for j, target := range test.targets {
+			d.backend.AcquireEndpoint("172.17.0."+strconv.Itoa(11+j), 8080, target)
+		}
+		c.createUpdater().buildBackendDynamic(d)
+		c.compareObjects("min slots", i, d.backend.Dynamic.MinSlots, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected hatypes.HealthCheck
+		logging  string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckInterval: "2s",
+			},
+			expected: hatypes.HealthCheck{
+				Interval: "2s",
+			},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackBackendCheckInterval: "5s",
+				ingtypes.BackHealthCheckURI:       "/check",
+				ingtypes.BackHealthCheckPort:      "4000",
+			},
+			expected: hatypes.HealthCheck{
+				Interval: "5s",
+				Port:     4000,
+				URI:      "/check",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckMethod:    "GET",
+				ingtypes.BackHealthCheckHost:      "app.local",
+				ingtypes.BackHealthCheckURI:       "/health",
+				ingtypes.BackHealthCheckExpStatus: "200, 3xx",
+			},
+			expected: hatypes.HealthCheck{
+				ExpectStatus: "^(200|3[0-9][0-9])$",
+				Host:         "app.local",
+				Method:       "GET",
+				SNI:          "app.local",
+				URI:          "/health",
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckHost: "app.local",
+			},
+			expected: hatypes.HealthCheck{
+				Host:   "app.local",
+				Method: "OPTIONS",
+				SNI:    "app.local",
+				URI:    "/",
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckExpBody: "status: ok",
+			},
+			expected: hatypes.HealthCheck{
+				ExpectBody: "status:\\ ok",
+				URI:        "/",
+			},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckExpBody: `{"status": "ok"} # 'up'\`,
+			},
+			expected: hatypes.HealthCheck{
+				ExpectBody: `{\"status\":\ \"ok\"}\ \#\ \'up\'\\`,
+				URI:        "/",
+			},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckExpStatus: "200",
+				ingtypes.BackHealthCheckExpBody:   "ok",
+			},
+			expected: hatypes.HealthCheck{
+				ExpectStatus: "^(200)$",
+				URI:          "/",
+			},
+			logging: `WARN ignoring health check expected body on ingress 'default/ing1': expected status is also configured`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckMethod:    "get /",
+				ingtypes.BackHealthCheckHost:      "app.local\\r\\nX-Header: 1",
+				ingtypes.BackHealthCheckExpStatus: "200,OK",
+			},
+			expected: hatypes.HealthCheck{},
+			logging: `
+WARN ignoring invalid health check method on ingress 'default/ing1': get /
+WARN ignoring invalid health check host on ingress 'default/ing1': app.local\r\nX-Header: 1
+WARN ignoring invalid health check expected status on ingress 'default/ing1': 200,OK`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckHost: "app.local",
+				ingtypes.BackHealthCheckSNI:  "check.app.local",
+				ingtypes.BackHealthCheckSSL:  "true",
+			},
+			expected: hatypes.HealthCheck{
+				Host:   "app.local",
+				Method: "OPTIONS",
+				SNI:    "check.app.local",
+				SSL:    true,
+				URI:    "/",
+			},
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckInterval:  "2s",
+				ingtypes.BackHealthCheckDownInter: "30s",
+			},
+			expected: hatypes.HealthCheck{
+				DownInterval: "30s",
+				Interval:     "2s",
+			},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendHealthCheck(d)
+		c.compareObjects("health check", i, d.backend.HealthCheck, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHealthCheckProbe(t *testing.T) {
+	buildPod := func(name, path, port string, period int32) *api.Pod {
+		container := api.Container{
+			Ports: []api.ContainerPort{
+				{Name: "http", ContainerPort: 8080},
+				{Name: "mgmt", ContainerPort: 9090},
+			},
+		}
+		if path != "" {
+			container.ReadinessProbe = &api.Probe{PeriodSeconds: period}
+			container.ReadinessProbe.HTTPGet = &api.HTTPGetAction{
+				Path: path,
+				Port: intstr.Parse(port),
+			}
+		}
+		return &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: api.PodSpec{
+				Containers: []api.Container{container},
+			},
+		}
+	}
+	buildEndpoints := func(targets ...string) []*hatypes.Endpoint {
+		ep := []*hatypes.Endpoint{}
+		for _, target := range targets {
+			ep = append(ep, &hatypes.Endpoint{
+				IP:        "172.17.0.11",
+				Port:      8080,
+				TargetRef: target,
+			})
+		}
+		return ep
+	}
+	pods := map[string]*api.Pod{
+		"pod1":  buildPod("pod1", "/ready", "http", 0),
+		"pod2":  buildPod("pod2", "/ready", "8080", 0),
+		"pod3":  buildPod("pod3", "/ready", "mgmt", 5),
+		"pod4":  buildPod("pod4", "/ready", "9090", 5),
+		"pod5":  buildPod("pod5", "/health", "8080", 0),
+		"pod6":  buildPod("pod6", "", "", 0),
+		"pod7":  buildPod("pod7", "/ready", "admin", 0),
+		"https": buildPod("https", "/ready", "http", 0),
+	}
+	pods["https"].Spec.Containers[0].ReadinessProbe.HTTPGet.Scheme = api.URISchemeHTTPS
+	pods["pod5"].CreationTimestamp = meta.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	testCases := []struct {
+		ann       map[string]string
+		global    map[string]string
+		endpoints []*hatypes.Endpoint
+		expected  hatypes.HealthCheck
+		logging   string
+	}{
+		// 0
+		{
+			endpoints: buildEndpoints("pod1", "pod2"),
+			expected:  hatypes.HealthCheck{Interval: "2s"},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("pod1", "pod2"),
+			expected: hatypes.HealthCheck{
+				FallCount: 3,
+				Interval:  "10s",
+				Method:    "GET",
+				RiseCount: 1,
+				URI:       "/ready",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("pod3", "pod4"),
+			expected: hatypes.HealthCheck{
+				FallCount: 3,
+				Interval:  "5s",
+				Method:    "GET",
+				Port:      9090,
+				RiseCount: 1,
+				URI:       "/ready",
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+				ingtypes.BackHealthCheckInterval:  "1s",
+				ingtypes.BackHealthCheckURI:       "/check",
+				ingtypes.BackHealthCheckFallCount: "2",
+			},
+			endpoints: buildEndpoints("pod1"),
+			expected: hatypes.HealthCheck{
+				FallCount: 2,
+				Interval:  "1s",
+				Method:    "GET",
+				RiseCount: 1,
+				URI:       "/check",
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("pod5", "pod1"),
+			expected: hatypes.HealthCheck{
+				FallCount: 3,
+				Interval:  "10s",
+				Method:    "GET",
+				RiseCount: 1,
+				URI:       "/ready",
+			},
+			logging: `INFO-V(2) pods of backend 'default_app_8080' declare distinct readiness probes, using the probe of the oldest one`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("pod1", "pod6"),
+			expected:  hatypes.HealthCheck{Interval: "2s"},
+			logging:   `WARN ignoring readiness probe of backend 'default_app_8080': pod 'default/pod6' does not declare an httpGet readiness probe`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("pod7"),
+			expected:  hatypes.HealthCheck{Interval: "2s"},
+			logging:   `WARN ignoring readiness probe of backend 'default_app_8080': port 'admin' of the readiness probe not found on pod 'default/pod7'`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("https"),
+			expected:  hatypes.HealthCheck{Interval: "2s"},
+			logging:   `WARN ignoring readiness probe of backend 'default_app_8080': HTTPS probes need secure-backends`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+				ingtypes.BackSecureBackends:       "true",
+			},
+			endpoints: buildEndpoints("https"),
+			expected: hatypes.HealthCheck{
+				FallCount: 3,
+				Interval:  "10s",
+				Method:    "GET",
+				RiseCount: 1,
+				SSL:       true,
+				URI:       "/ready",
+			},
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("pod8"),
+			expected:  hatypes.HealthCheck{Interval: "2s"},
+			logging:   `INFO-V(2) skipping pod on readiness probe of backend 'default_app_8080': pod not found: 'pod8'`,
+		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			endpoints: buildEndpoints("pod8", "pod1"),
+			expected: hatypes.HealthCheck{
+				FallCount: 3,
+				Interval:  "10s",
+				Method:    "GET",
+				RiseCount: 1,
+				URI:       "/ready",
+			},
+			logging: `INFO-V(2) skipping pod on readiness probe of backend 'default_app_8080': pod not found: 'pod8'`,
+		},
+		// 11
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckFromProbe: "true",
+			},
+			global: map[string]string{
+				ingtypes.BackHealthCheckInterval: "2s",
+			},
+			endpoints: buildEndpoints("pod1"),
+			expected: hatypes.HealthCheck{
+				FallCount: 3,
+				Interval:  "2s",
+				Method:    "GET",
+				RiseCount: 1,
+				URI:       "/ready",
+			},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{ingtypes.BackHealthCheckInterval: "2s"})
+		d.backend.Endpoints = test.endpoints
+		u := c.createUpdater()
+		u.globalConfig = test.global
+		u.buildBackendHealthCheck(d)
+		c.compareObjects("health check", i, d.backend.HealthCheck, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestPassiveCheck(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		noCheck  bool
+		expected hatypes.ServerConfig
+		logging  string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.ServerConfig{},
 		},
 		// 1
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10k",
-				},
-				"/app": {
-					ingtypes.BackProxyBodySize: "10m",
-				},
-				"/sub": {
-					ingtypes.BackProxyBodySize: "10g",
-				},
+			ann: map[string]string{
+				ingtypes.BackHealthCheckObserve: "layer7",
 			},
-			expected: []*hatypes.BackendConfigInt{
-				{
-					Paths:  createBackendPaths("/"),
-					Config: 10240,
-				},
-				{
-					Paths:  createBackendPaths("/app"),
-					Config: 10485760,
-				},
-				{
-					Paths:  createBackendPaths("/sub"),
-					Config: 10737418240,
-				},
+			expected: hatypes.ServerConfig{
+				ErrorLimit: 10,
+				Observe:    "layer7",
+				OnError:    "fail-check",
 			},
 		},
 		// 2
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "unlimited",
-				},
+			ann: map[string]string{
+				ingtypes.BackHealthCheckObserve:    "layer4",
+				ingtypes.BackHealthCheckErrorLimit: "5",
+				ingtypes.BackHealthCheckOnError:    "mark-down",
 			},
-			expected: []*hatypes.BackendConfigInt{
-				{
-					Paths:  createBackendPaths("/"),
-					Config: 0,
-				},
+			expected: hatypes.ServerConfig{
+				ErrorLimit: 5,
+				Observe:    "layer4",
+				OnError:    "mark-down",
 			},
 		},
 		// 3
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10e",
-				},
+			ann: map[string]string{
+				ingtypes.BackHealthCheckObserve: "layer7",
 			},
-			expected: []*hatypes.BackendConfigInt{
-				{
-					Paths:  createBackendPaths("/"),
-					Config: 0,
-				},
+			modeTCP: true,
+			expected: hatypes.ServerConfig{
+				ErrorLimit: 10,
+				Observe:    "layer4",
+				OnError:    "fail-check",
 			},
-			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
-			logging: `WARN ignoring invalid body size on ingress 'default/ing1': 10e`,
+			logging: `WARN using layer4 health check observe mode on ingress 'default/ing1': backend is in TCP mode`,
 		},
 		// 4
 		{
-			ann: map[string]map[string]string{
-				"/app": {
-					ingtypes.BackProxyBodySize: "1m",
-				},
+			ann: map[string]string{
+				ingtypes.BackHealthCheckObserve: "layer5",
 			},
-			expected: []*hatypes.BackendConfigInt{
-				{
-					Paths:  createBackendPaths("/"),
-					Config: 0,
-				},
-				{
-					Paths:  createBackendPaths("/app"),
-					Config: 1048576,
-				},
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring invalid health check observe mode on ingress 'default/ing1': layer5`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckObserve: "layer7",
 			},
-			paths:  []string{"/"},
-			source: Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			noCheck:  true,
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring health check observe mode on ingress 'default/ing1': health check is disabled`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckObserve:    "layer7",
+				ingtypes.BackHealthCheckErrorLimit: "0",
+			},
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring invalid health check error limit on ingress 'default/ing1': 0`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckObserve: "layer7",
+				ingtypes.BackHealthCheckOnError: "eject",
+			},
+			expected: hatypes.ServerConfig{},
+			logging:  `WARN ignoring invalid health check on error action on ingress 'default/ing1': eject`,
 		},
 	}
+	annDefault := map[string]string{
+		ingtypes.BackHealthCheckErrorLimit: "10",
+		ingtypes.BackHealthCheckObserve:    "none",
+		ingtypes.BackHealthCheckOnError:    "fail-check",
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
 		c := setup(t)
-		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
-		c.createUpdater().buildBackendBodySize(d)
-		c.compareObjects("proxy body size", i, d.backend.MaxBodySize, test.expected)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		if !test.noCheck {
+			d.backend.HealthCheck.Interval = "2s"
+		}
+		c.createUpdater().buildBackendPassiveCheck(d)
+		c.compareObjects("server config", i, d.backend.Server, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-const (
-	corsDefaultHeaders = "DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization"
-	corsDefaultMethods = "GET, PUT, POST, DELETE, PATCH, OPTIONS"
-	corsDefaultOrigin  = "*"
-	corsDefaultMaxAge  = 86400
-)
-
-func TestCors(t *testing.T) {
+func TestRetry(t *testing.T) {
 	testCases := []struct {
-		paths    []string
-		ann      map[string]map[string]string
-		expected []*hatypes.BackendConfigCors
+		ann      map[string]string
+		expected hatypes.BackendRetry
 		logging  string
 	}{
 		// 0
 		{
-			paths: []string{"/"},
-			expected: []*hatypes.BackendConfigCors{
-				{
-					Paths:  createBackendPaths("/"),
-					Config: hatypes.Cors{},
-				},
-			},
+			ann:      map[string]string{},
+			expected: hatypes.BackendRetry{},
 		},
 		// 1
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable: "true",
-				},
+			ann: map[string]string{
+				ingtypes.BackRetries:            "5",
+				ingtypes.BackRetryOn:            "conn-failure",
+				ingtypes.BackRedispatchInterval: "-2",
 			},
-			expected: []*hatypes.BackendConfigCors{
-				{
-					Paths: createBackendPaths("/"),
-					Config: hatypes.Cors{
-						Enabled:          true,
-						AllowCredentials: false,
-						AllowHeaders:     corsDefaultHeaders,
-						AllowMethods:     corsDefaultMethods,
-						AllowOrigin:      corsDefaultOrigin,
-						ExposeHeaders:    "",
-						MaxAge:           corsDefaultMaxAge,
-					},
-				},
+			expected: hatypes.BackendRetry{
+				Count:      "5",
+				Redispatch: "-2",
 			},
 		},
 		// 2
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable: "false",
-				},
-				"/sub": {
-					ingtypes.BackCorsEnable: "true",
-				},
+			ann: map[string]string{
+				ingtypes.BackRetries: "3",
+				ingtypes.BackRetryOn: "none",
 			},
-			expected: []*hatypes.BackendConfigCors{
-				{
-					Paths:  createBackendPaths("/"),
-					Config: hatypes.Cors{},
-				},
-				{
-					Paths: createBackendPaths("/sub"),
-					Config: hatypes.Cors{
-						Enabled:          true,
-						AllowCredentials: false,
-						AllowHeaders:     corsDefaultHeaders,
-						AllowMethods:     corsDefaultMethods,
-						AllowOrigin:      corsDefaultOrigin,
-						ExposeHeaders:    "",
-						MaxAge:           corsDefaultMaxAge,
-					},
-				},
+			expected: hatypes.BackendRetry{
+				Count: "0",
 			},
+			logging: `WARN retry-on 'none' on ingress 'default/ing1' overrides retries '3' on ingress 'default/ing1'`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackRedispatchInterval: "0",
+			},
+			expected: hatypes.BackendRetry{
+				Redispatch: "0",
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackRetries: "2",
+				ingtypes.BackRetryOn: "conn-failure, 503, empty-response",
+			},
+			expected: hatypes.BackendRetry{
+				Count: "2",
+			},
+			logging: `
+WARN ignoring retry-on condition on ingress 'default/ing1': '503' isn't supported by HAProxy 1.8
+WARN ignoring retry-on condition on ingress 'default/ing1': 'empty-response' isn't supported by HAProxy 1.8`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackRetryOn:            "503",
+				ingtypes.BackRetryNonIdempotent: "true",
+			},
+			expected: hatypes.BackendRetry{},
+			logging: `
+WARN ignoring retry-on condition on ingress 'default/ing1': '503' isn't supported by HAProxy 1.8
+WARN ignoring retry-non-idempotent on ingress 'default/ing1': HAProxy 1.8 only retries requests not sent yet`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackRetries:            "-1",
+				ingtypes.BackRetryOn:            "always",
+				ingtypes.BackRedispatchInterval: "last",
+			},
+			expected: hatypes.BackendRetry{},
+			logging: `
+WARN ignoring invalid retries on ingress 'default/ing1': -1
+WARN ignoring invalid retry-on condition on ingress 'default/ing1': always
+WARN ignoring invalid redispatch interval on ingress 'default/ing1': last`,
 		},
 	}
-	annDefault := map[string]string{
-		ingtypes.BackCorsAllowHeaders: corsDefaultHeaders,
-		ingtypes.BackCorsAllowMethods: corsDefaultMethods,
-		ingtypes.BackCorsAllowOrigin:  corsDefaultOrigin,
-		ingtypes.BackCorsMaxAge:       strconv.Itoa(corsDefaultMaxAge),
-	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
 		c := setup(t)
-		d := c.createBackendMappingData("default/app", &Source{}, annDefault, test.ann, test.paths)
-		c.createUpdater().buildBackendCors(d)
-		c.compareObjects("cors", i, d.backend.Cors, test.expected)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendRetry(d)
+		c.compareObjects("retry", i, d.backend.Retry, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -1609,6 +2672,141 @@ WARN skipping invalid IP or cidr on ingress 'default/ing1': 192.168.0/16`,
 	}
 }
 
+func TestZoneAware(t *testing.T) {
+	controllerPod := &api.Pod{
+		ObjectMeta: meta.ObjectMeta{Namespace: "ingress", Name: "haproxy-ingress-xxxxx"},
+		Spec:       api.PodSpec{NodeName: "node1"},
+	}
+	nodes := map[string]*api.Node{
+		"node1": {ObjectMeta: meta.ObjectMeta{Name: "node1", Labels: map[string]string{api.LabelTopologyZone: "zone-a"}}},
+		"node2": {ObjectMeta: meta.ObjectMeta{Name: "node2"}},
+	}
+	// ip=zone1+zone2[=weight]
+	buildEndpoints := func(endpoints string) []*hatypes.Endpoint {
+		eps := []*hatypes.Endpoint{}
+		for _, e := range strings.Split(endpoints, ",") {
+			ep := &hatypes.Endpoint{Weight: 1}
+			epweight := strings.Split(e, "=")
+			if len(epweight) > 2 {
+				w, _ := strconv.ParseInt(epweight[2], 10, 0)
+				ep.Weight = int(w)
+			}
+			ep.IP = epweight[0]
+			if len(epweight) > 1 && epweight[1] != "" {
+				ep.Zones = strings.Split(epweight[1], "+")
+			}
+			eps = append(eps, ep)
+		}
+		return eps
+	}
+	testCases := []struct {
+		ann       map[string]string
+		nodeName  string
+		noPod     bool
+		endpoints string
+		expBackup []bool
+		expSlots  bool
+		logging   string
+	}{
+		// 0
+		{
+			endpoints: "172.17.0.11=zone-a,172.17.0.12=zone-b",
+			expBackup: []bool{false, false},
+		},
+		// 1
+		{
+			ann:       map[string]string{ingtypes.BackZoneAwareRouting: "true"},
+			endpoints: "172.17.0.11=zone-a,172.17.0.12=zone-b,172.17.0.13=zone-b+zone-a,172.17.0.14",
+			expBackup: []bool{false, true, false, false},
+			expSlots:  true,
+		},
+		// 2
+		{
+			ann:       map[string]string{ingtypes.BackZoneAwareRouting: "true"},
+			endpoints: "172.17.0.11=zone-a=0,172.17.0.12=zone-b",
+			expBackup: []bool{false, false},
+			expSlots:  true,
+			logging:   `INFO-V(2) using endpoints of all zones on backend 'default_app_8080': found 0 of 1 active endpoint(s) on zone 'zone-a'`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackZoneAwareRouting:      "true",
+				ingtypes.BackZoneAwareMinEndpoints: "2",
+			},
+			endpoints: "172.17.0.11=zone-a,172.17.0.12=zone-b,172.17.0.13=zone-b",
+			expBackup: []bool{false, false, false},
+			expSlots:  true,
+			logging:   `INFO-V(2) using endpoints of all zones on backend 'default_app_8080': found 1 of 2 active endpoint(s) on zone 'zone-a'`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackZoneAwareRouting:      "true",
+				ingtypes.BackZoneAwareMinEndpoints: "2",
+			},
+			endpoints: "172.17.0.11=zone-a,172.17.0.12=zone-a,172.17.0.13=zone-b",
+			expBackup: []bool{false, false, true},
+			expSlots:  true,
+		},
+		// 5
+		{
+			ann:       map[string]string{ingtypes.BackZoneAwareRouting: "true"},
+			noPod:     true,
+			endpoints: "172.17.0.11=zone-a,172.17.0.12=zone-b",
+			expBackup: []bool{false, false},
+			logging:   `WARN ignoring zone aware routing, error reading the controller pod: controller pod not found`,
+		},
+		// 6
+		{
+			ann:       map[string]string{ingtypes.BackZoneAwareRouting: "true"},
+			nodeName:  "node2",
+			endpoints: "172.17.0.11=zone-a,172.17.0.12=zone-b",
+			expBackup: []bool{false, false},
+			logging:   `WARN ignoring zone aware routing, node 'node2' doesn't have a zone label`,
+		},
+		// 7
+		{
+			ann:       map[string]string{ingtypes.BackZoneAwareRouting: "true"},
+			nodeName:  "node3",
+			endpoints: "172.17.0.11=zone-a,172.17.0.12=zone-b",
+			expBackup: []bool{false, false},
+			logging:   `WARN ignoring zone aware routing, error reading the controller node: node not found: 'node3'`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	annDefault := map[string]string{
+		ingtypes.BackZoneAwareMinEndpoints: "1",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		if !test.noPod {
+			pod := controllerPod.DeepCopy()
+			if test.nodeName != "" {
+				pod.Spec.NodeName = test.nodeName
+			}
+			c.cache.ControllerPod = pod
+		}
+		c.cache.NodeList = nodes
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.Endpoints = buildEndpoints(test.endpoints)
+		u := c.createUpdater()
+		u.buildBackendZoneAware(d)
+		backup := make([]bool, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			backup[j] = ep.Backup
+		}
+		c.compareObjects("backup", i, backup, test.expBackup)
+		c.compareObjects("backup slots", i, d.backend.Dynamic.BackupSlots, test.expSlots)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func createBackendPaths(paths ...string) hatypes.BackendPaths {
 	backendPaths := make([]*hatypes.BackendPath, 0, len(paths))
 	for _, path := range paths {
@@ -20,12 +20,43 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
+func (c *updater) buildGlobalAcme(d *globalData) {
+	endpoint := d.mapper.Get(ingtypes.GlobalAcmeEndpoint).Value
+	if endpoint == "" {
+		return
+	}
+	if c.acmeSocket == "" {
+		c.logger.Warn("skipping acme config, the acme server isn't running, use --acme-server command-line option")
+		return
+	}
+	emails := d.mapper.Get(ingtypes.GlobalAcmeEmails).Value
+	if emails == "" {
+		c.logger.Warn("skipping acme config, missing email account")
+		return
+	}
+	termsAgreed := d.mapper.Get(ingtypes.GlobalAcmeTermsAgreed).Bool()
+	if !termsAgreed {
+		c.logger.Warn("acme terms was not agreed, configure acme-terms-agreed with true to use acme")
+		return
+	}
+	acmeData := c.haproxy.AcmeData()
+	acmeData.Emails = emails
+	acmeData.Endpoint = endpoint
+	acmeData.Expiring = time.Duration(d.mapper.Get(ingtypes.GlobalAcmeExpiring).Int()) * 24 * time.Hour
+	acmeData.TermsAgreed = termsAgreed
+	d.global.Acme.Prefix = "/.well-known/acme-challenge/"
+	d.global.Acme.Socket = c.acmeSocket
+	d.global.Acme.Enabled = true
+	d.global.Acme.Shared = d.mapper.Get(ingtypes.GlobalAcmeShared).Bool()
+}
+
 func (c *updater) buildGlobalBind(d *globalData) {
 	d.global.Bind.AcceptProxy = d.mapper.Get(ingtypes.GlobalUseProxyProtocol).Bool()
 	d.global.Bind.TCPBindIP = d.mapper.Get(ingtypes.GlobalBindIPAddrTCP).Value
@@ -101,6 +132,11 @@ func (c *updater) buildGlobalProc(d *globalData) {
 	d.global.Procs.CPUMap = cpumap
 }
 
+func (c *updater) buildGlobalRequestID(d *globalData) {
+	d.global.RequestID.Format = d.mapper.Get(ingtypes.GlobalRequestIDFormat).Value
+	d.global.RequestID.TrustedNetworks = c.splitCIDR(d.mapper.Get(ingtypes.GlobalRequestIDTrustedNetworks))
+}
+
 func (c *updater) buildGlobalStats(d *globalData) {
 	d.global.Stats.AcceptProxy = d.mapper.Get(ingtypes.GlobalStatsProxyProtocol).Bool()
 	d.global.Stats.Auth = d.mapper.Get(ingtypes.GlobalStatsAuth).Value
@@ -116,13 +152,44 @@ func (c *updater) buildGlobalStats(d *globalData) {
 	}
 }
 
+// JSON presets of the access logs. HTTP requests have the namespace of the
+// host if var-namespace is enabled, the ingress if syslog-endpoint is configured
+// and the request id if request-id is enabled. The service is read from the
+// backend name and the pod is the server name if backend-server-naming is pod.
+const (
+	httpLogFormatJSON = `{\"time\":\"%t\",\"client\":\"%ci:%cp\",\"frontend\":\"%ft\",` +
+		`\"namespace\":\"%[var(txn.namespace)]\",\"ingress\":\"%[var(txn.ingress)]\",` +
+		`\"service\":\"%[be_name,field(1,_)]/%[be_name,field(2,_)]\",` +
+		`\"request_id\":\"%[var(txn.request_id),json(utf8s)]\",` +
+		`\"backend\":\"%b\",\"pod\":\"%s\",` +
+		`\"method\":\"%HM\",\"uri\":\"%[capture.req.uri,json(utf8s)]\",\"version\":\"%HV\",` +
+		`\"status\":%ST,\"bytes\":%B,\"time_request\":%TR,\"time_queue\":%Tw,` +
+		`\"time_connect\":%Tc,\"time_response\":%Tr,\"time_total\":%Ta,\"termination\":\"%ts\"}`
+	tcpLogFormatJSON = `{\"time\":\"%t\",\"client\":\"%ci:%cp\",\"frontend\":\"%ft\",` +
+		`\"backend\":\"%b\",\"server\":\"%s\",\"bytes\":%B,\"time_queue\":%Tw,` +
+		`\"time_connect\":%Tc,\"time_total\":%Tt,\"termination\":\"%ts\"}`
+)
+
 func (c *updater) buildGlobalSyslog(d *globalData) {
 	d.global.Syslog.Endpoint = d.mapper.Get(ingtypes.GlobalSyslogEndpoint).Value
 	d.global.Syslog.Format = d.mapper.Get(ingtypes.GlobalSyslogFormat).Value
-	d.global.Syslog.HTTPLogFormat = d.mapper.Get(ingtypes.GlobalHTTPLogFormat).Value
-	d.global.Syslog.HTTPSLogFormat = d.mapper.Get(ingtypes.GlobalHTTPSLogFormat).Value
+	d.global.Syslog.HTTPLogFormat = logFormat(d.mapper.Get(ingtypes.HostHTTPLogFormat).Value, httpLogFormatJSON)
+	d.global.Syslog.HTTPSLogFormat = logFormat(d.mapper.Get(ingtypes.GlobalHTTPSLogFormat).Value, tcpLogFormatJSON)
 	d.global.Syslog.Tag = d.mapper.Get(ingtypes.GlobalSyslogTag).Value
-	d.global.Syslog.TCPLogFormat = d.mapper.Get(ingtypes.GlobalTCPLogFormat).Value
+	d.global.Syslog.TCPLogFormat = logFormat(d.mapper.Get(ingtypes.GlobalTCPLogFormat).Value, tcpLogFormatJSON)
+	if d.global.Syslog.Endpoint == "stdout" {
+		// HAProxy logs to a local socket, the controller reads
+		// the rfc5424 messages and forwards them to its stdout
+		d.global.Syslog.Endpoint = hatypes.SyslogStdoutSocket
+		d.global.Syslog.Format = "rfc5424"
+	}
+}
+
+func logFormat(format, jsonFormat string) string {
+	if format == "json" {
+		return jsonFormat
+	}
+	return format
 }
 
 func (c *updater) buildGlobalTimeout(d *globalData) {
@@ -140,7 +207,10 @@ func (c *updater) buildGlobalTimeout(d *globalData) {
 
 func (c *updater) buildGlobalSSL(d *globalData) {
 	d.global.SSL.Ciphers = d.mapper.Get(ingtypes.GlobalSSLCiphers).Value
+	d.global.SSL.CipherSuites = d.mapper.Get(ingtypes.GlobalSSLCipherSuites).Value
 	d.global.SSL.Options = d.mapper.Get(ingtypes.GlobalSSLOptions).Value
+	d.global.SSL.MinVersion = c.validateTLSVersion(d.mapper.Get(ingtypes.GlobalSSLMinVersion))
+	d.global.SSL.MaxVersion = c.validateTLSVersion(d.mapper.Get(ingtypes.GlobalSSLMaxVersion))
 	if sslDHParam := d.mapper.Get(ingtypes.GlobalSSLDHParam).Value; sslDHParam != "" {
 		if dhFile, err := c.cache.GetDHSecretPath("", sslDHParam); err == nil {
 			d.global.SSL.DHParam.Filename = dhFile.Filename
@@ -18,11 +18,111 @@ package annotations
 
 import (
 	"testing"
+	"time"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
+func TestAcmeData(t *testing.T) {
+	testCases := []struct {
+		ann          map[string]string
+		noServer     bool
+		expected     hatypes.AcmeData
+		expectedAcme hatypes.AcmeConfig
+		logging      string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.AcmeData{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEndpoint: "v2-staging",
+			},
+			expected: hatypes.AcmeData{},
+			logging:  `WARN skipping acme config, missing email account`,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEndpoint: "v2-staging",
+				ingtypes.GlobalAcmeEmails:   "admin@hostname.local",
+			},
+			expected: hatypes.AcmeData{},
+			logging:  `WARN acme terms was not agreed, configure acme-terms-agreed with true to use acme`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEndpoint:    "v2-staging",
+				ingtypes.GlobalAcmeEmails:      "admin@hostname.local",
+				ingtypes.GlobalAcmeTermsAgreed: "true",
+				ingtypes.GlobalAcmeExpiring:    "10",
+			},
+			expected: hatypes.AcmeData{
+				Emails:      "admin@hostname.local",
+				Endpoint:    "v2-staging",
+				Expiring:    240 * time.Hour,
+				TermsAgreed: true,
+			},
+			expectedAcme: hatypes.AcmeConfig{
+				Enabled: true,
+				Prefix:  "/.well-known/acme-challenge/",
+				Socket:  "/var/run/acme.sock",
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEndpoint:    "https://acme.local:14000/dir",
+				ingtypes.GlobalAcmeEmails:      "admin@hostname.local",
+				ingtypes.GlobalAcmeTermsAgreed: "true",
+				ingtypes.GlobalAcmeExpiring:    "30",
+				ingtypes.GlobalAcmeShared:      "true",
+			},
+			expected: hatypes.AcmeData{
+				Emails:      "admin@hostname.local",
+				Endpoint:    "https://acme.local:14000/dir",
+				Expiring:    720 * time.Hour,
+				TermsAgreed: true,
+			},
+			expectedAcme: hatypes.AcmeConfig{
+				Enabled: true,
+				Prefix:  "/.well-known/acme-challenge/",
+				Shared:  true,
+				Socket:  "/var/run/acme.sock",
+			},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEndpoint:    "v2-staging",
+				ingtypes.GlobalAcmeEmails:      "admin@hostname.local",
+				ingtypes.GlobalAcmeTermsAgreed: "true",
+			},
+			noServer: true,
+			expected: hatypes.AcmeData{},
+			logging:  `WARN skipping acme config, the acme server isn't running, use --acme-server command-line option`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.ann)
+		u := c.createUpdater()
+		if test.noServer {
+			u.acmeSocket = ""
+		}
+		u.buildGlobalAcme(d)
+		c.compareObjects("acme data", i, *c.haproxy.AcmeData(), test.expected)
+		c.compareObjects("acme", i, d.global.Acme, test.expectedAcme)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestBind(t *testing.T) {
 	testCases := []struct {
 		ann      map[string]string
@@ -324,3 +424,66 @@ func TestFrontingProxy(t *testing.T) {
 		c.teardown()
 	}
 }
+
+func TestSyslog(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected hatypes.SyslogConfig
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.SyslogConfig{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSyslogEndpoint: "127.0.0.1:514",
+				ingtypes.GlobalSyslogFormat:   "rfc3164",
+				ingtypes.HostHTTPLogFormat:    "%ci:%cp\\ %ft",
+				ingtypes.GlobalTCPLogFormat:   "default",
+			},
+			expected: hatypes.SyslogConfig{
+				Endpoint:      "127.0.0.1:514",
+				Format:        "rfc3164",
+				HTTPLogFormat: "%ci:%cp\\ %ft",
+				TCPLogFormat:  "default",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSyslogEndpoint: "127.0.0.1:514",
+				ingtypes.HostHTTPLogFormat:    "json",
+				ingtypes.GlobalHTTPSLogFormat: "json",
+				ingtypes.GlobalTCPLogFormat:   "json",
+			},
+			expected: hatypes.SyslogConfig{
+				Endpoint:       "127.0.0.1:514",
+				HTTPLogFormat:  httpLogFormatJSON,
+				HTTPSLogFormat: tcpLogFormatJSON,
+				TCPLogFormat:   tcpLogFormatJSON,
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSyslogEndpoint: "stdout",
+				ingtypes.GlobalSyslogFormat:   "rfc3164",
+				ingtypes.GlobalSyslogTag:      "ingress",
+			},
+			expected: hatypes.SyslogConfig{
+				Endpoint: "/var/run/haproxy-log.sock",
+				Format:   "rfc5424",
+				Tag:      "ingress",
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.ann)
+		c.createUpdater().buildGlobalSyslog(d)
+		c.compareObjects("syslog", i, d.global.Syslog, test.expected)
+		c.teardown()
+	}
+}
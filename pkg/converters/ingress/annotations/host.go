@@ -36,6 +36,14 @@ func (c *updater) buildHostAuthTLS(d *hostData) {
 	} else {
 		c.logger.Error("error building TLS auth config on %s: %v", tlsSecret.Source, err)
 	}
+	if crlSecret := d.mapper.Get(ingtypes.HostAuthTLSCRLSecret); tls.CAFilename != "" && crlSecret.Source != nil && crlSecret.Value != "" {
+		if crlFile, err := c.cache.GetCRLSecretPath(crlSecret.Source.Namespace, crlSecret.Value); err == nil {
+			tls.CRLFilename = crlFile.Filename
+			tls.CRLHash = crlFile.SHA1Hash
+		} else {
+			c.logger.Error("error building TLS auth CRL config on %s: %v", crlSecret.Source, err)
+		}
+	}
 	if tls.CAFilename == "" && d.mapper.Get(ingtypes.HostAuthTLSStrict).Bool() {
 		// Here we have a misconfigured auth-tls and auth-tls-strict as `true`.
 		// Using a fake and self-generated CA so any connection attempt will fail with
@@ -47,6 +55,29 @@ func (c *updater) buildHostAuthTLS(d *hostData) {
 	tls.CAErrorPage = d.mapper.Get(ingtypes.HostAuthTLSErrorPage).Value
 }
 
+func (c *updater) buildHostLogFormat(d *hostData) {
+	// only the annotation is used, global config is already
+	// used by the frontends that don't declare its own format
+	if cfg := d.mapper.Get(ingtypes.HostHTTPLogFormat); cfg.Source != nil {
+		d.host.LogFormat = logFormat(cfg.Value, httpLogFormatJSON)
+	}
+}
+
+func (c *updater) buildHostRequestID(d *hostData) {
+	requestID := d.mapper.Get(ingtypes.HostRequestID)
+	switch requestID.Value {
+	case "", "none":
+	case "x-request-id", "traceparent":
+		d.host.RequestID = requestID.Value
+	default:
+		if requestID.Source != nil {
+			c.logger.Warn("ignoring invalid request-id on %v: %s", requestID.Source, requestID.Value)
+		} else {
+			c.logger.Warn("ignoring invalid request-id on global/default config: %s", requestID.Value)
+		}
+	}
+}
+
 func (c *updater) buildHostSSLPassthrough(d *hostData) {
 	sslpassthrough := d.mapper.Get(ingtypes.HostSSLPassthrough)
 	if !sslpassthrough.Bool() {
@@ -74,6 +105,27 @@ func (c *updater) buildHostSSLPassthrough(d *hostData) {
 	d.host.SSLPassthrough = true
 }
 
+func (c *updater) buildHostTLSPolicy(d *hostData) {
+	// only options declared as annotations are used,
+	// global config is already used by the binds
+	tls := &d.host.TLS
+	if cfg := d.mapper.Get(ingtypes.HostTLSALPN); cfg.Source != nil {
+		tls.ALPN = cfg.Value
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLCiphers); cfg.Source != nil {
+		tls.Ciphers = cfg.Value
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLCipherSuites); cfg.Source != nil {
+		tls.CipherSuites = cfg.Value
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLMinVersion); cfg.Source != nil {
+		tls.MinVersion = c.validateTLSVersion(cfg)
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLMaxVersion); cfg.Source != nil {
+		tls.MaxVersion = c.validateTLSVersion(cfg)
+	}
+}
+
 func (c *updater) buildHostTimeout(d *hostData) {
 	if cfg := d.mapper.Get(ingtypes.HostTimeoutClient); cfg.Source != nil {
 		d.host.Timeout.Client = c.validateTime(cfg)
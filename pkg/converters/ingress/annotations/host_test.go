@@ -120,6 +120,37 @@ func TestAuthTLS(t *testing.T) {
 				CAVerifyOptional: true,
 			},
 		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:    "cafile",
+				ingtypes.HostAuthTLSCRLSecret: "crlfile",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename:  "/path/ca.crt",
+				CAHash:      "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+				CRLFilename: "/path/ca.crl",
+				CRLHash:     "fda2f454cccea3568c67232a614844fb6ae58f6c",
+			},
+		},
+		// 11
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:    "cafile",
+				ingtypes.HostAuthTLSCRLSecret: "crlerr",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename: "/path/ca.crt",
+				CAHash:     "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+			},
+			logging: "ERROR error building TLS auth CRL config on ingress 'system/ing1': secret not found: 'system/crlerr'",
+		},
+		// 12
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSCRLSecret: "crlfile",
+			},
+		},
 	}
 	source := &Source{Namespace: "system", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
@@ -127,6 +158,9 @@ func TestAuthTLS(t *testing.T) {
 		c.cache.SecretCAPath = map[string]string{
 			"system/cafile": "/path/ca.crt",
 		}
+		c.cache.SecretCRLPath = map[string]string{
+			"system/crlfile": "/path/ca.crl",
+		}
 		d := c.createHostData(source, test.ann, test.annDefault)
 		c.createUpdater().buildHostAuthTLS(d)
 		c.compareObjects("auth-tls", i, d.host.TLS, test.expected)
@@ -134,3 +168,179 @@ func TestAuthTLS(t *testing.T) {
 		c.teardown()
 	}
 }
+
+func TestLogFormat(t *testing.T) {
+	testCases := []struct {
+		annDefault map[string]string
+		ann        map[string]string
+		expected   string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			annDefault: map[string]string{
+				ingtypes.HostHTTPLogFormat: "json",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.HostHTTPLogFormat: "%ci:%cp\\ %b",
+			},
+			expected: "%ci:%cp\\ %b",
+		},
+		// 3
+		{
+			annDefault: map[string]string{
+				ingtypes.HostHTTPLogFormat: "%ci:%cp\\ %b",
+			},
+			ann: map[string]string{
+				ingtypes.HostHTTPLogFormat: "json",
+			},
+			expected: httpLogFormatJSON,
+		},
+	}
+	source := &Source{Namespace: "system", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createHostData(source, test.ann, test.annDefault)
+		c.createUpdater().buildHostLogFormat(d)
+		c.compareObjects("log format", i, d.host.LogFormat, test.expected)
+		c.logger.CompareLogging("")
+		c.teardown()
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		annDefault map[string]string
+		ann        map[string]string
+		expected   string
+		logging    string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			annDefault: map[string]string{
+				ingtypes.HostRequestID: "none",
+			},
+		},
+		// 2
+		{
+			annDefault: map[string]string{
+				ingtypes.HostRequestID: "x-request-id",
+			},
+			expected: "x-request-id",
+		},
+		// 3
+		{
+			annDefault: map[string]string{
+				ingtypes.HostRequestID: "x-request-id",
+			},
+			ann: map[string]string{
+				ingtypes.HostRequestID: "traceparent",
+			},
+			expected: "traceparent",
+		},
+		// 4
+		{
+			annDefault: map[string]string{
+				ingtypes.HostRequestID: "x-request-id",
+			},
+			ann: map[string]string{
+				ingtypes.HostRequestID: "none",
+			},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.HostRequestID: "uuid",
+			},
+			logging: "WARN ignoring invalid request-id on ingress 'system/ing1': uuid",
+		},
+		// 6
+		{
+			annDefault: map[string]string{
+				ingtypes.HostRequestID: "uuid",
+			},
+			logging: "WARN ignoring invalid request-id on global/default config: uuid",
+		},
+	}
+	source := &Source{Namespace: "system", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createHostData(source, test.ann, test.annDefault)
+		c.createUpdater().buildHostRequestID(d)
+		c.compareObjects("request id", i, d.host.RequestID, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestTLSPolicy(t *testing.T) {
+	testCases := []struct {
+		annDefault map[string]string
+		ann        map[string]string
+		expected   hatypes.HostTLSConfig
+		logging    string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			annDefault: map[string]string{
+				ingtypes.HostSSLCiphers:    "ECDHE-RSA-AES128-GCM-SHA256",
+				ingtypes.HostSSLMinVersion: "TLSv1.2",
+				ingtypes.HostTLSALPN:       "h2,http/1.1",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.HostSSLCiphers:      "ECDHE-RSA-AES128-SHA",
+				ingtypes.HostSSLCipherSuites: "TLS_AES_128_GCM_SHA256",
+				ingtypes.HostSSLMaxVersion:   "TLSv1.2",
+				ingtypes.HostSSLMinVersion:   "TLSv1.0",
+				ingtypes.HostTLSALPN:         "http/1.1",
+			},
+			expected: hatypes.HostTLSConfig{
+				ALPN:         "http/1.1",
+				Ciphers:      "ECDHE-RSA-AES128-SHA",
+				CipherSuites: "TLS_AES_128_GCM_SHA256",
+				MaxVersion:   "TLSv1.2",
+				MinVersion:   "TLSv1.0",
+			},
+		},
+		// 3
+		{
+			annDefault: map[string]string{
+				ingtypes.HostSSLMinVersion: "TLSv1.2",
+			},
+			ann: map[string]string{
+				ingtypes.HostSSLMinVersion: "TLSv1.0",
+			},
+			expected: hatypes.HostTLSConfig{
+				MinVersion: "TLSv1.0",
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.HostSSLMinVersion: "TLS1.0",
+			},
+			expected: hatypes.HostTLSConfig{},
+			logging:  "WARN ignoring invalid TLS version on ingress 'system/ing1': TLS1.0",
+		},
+	}
+	source := &Source{Namespace: "system", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createHostData(source, test.ann, test.annDefault)
+		c.createUpdater().buildHostTLSPolicy(d)
+		c.compareObjects("tls policy", i, d.host.TLS, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
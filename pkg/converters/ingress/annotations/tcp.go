@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func (c *updater) buildTCPAuthTLS(d *tcpData) {
+	tlsSecret := d.mapper.Get(ingtypes.HostAuthTLSSecret)
+	if tlsSecret.Source == nil || tlsSecret.Value == "" {
+		return
+	}
+	verify := d.mapper.Get(ingtypes.HostAuthTLSVerifyClient)
+	if verify.Value == "off" {
+		return
+	}
+	ssl := &d.backend.SSL
+	if ssl.Filename == "" {
+		c.logger.Warn("ignoring client certificate authentication on %v: TCP service doesn't offload SSL", tlsSecret.Source)
+		return
+	}
+	if cafile, err := c.cache.GetCASecretPath(tlsSecret.Source.Namespace, tlsSecret.Value); err == nil {
+		ssl.CAFilename = cafile.Filename
+		ssl.CAHash = cafile.SHA1Hash
+	} else {
+		c.logger.Error("error building TLS auth config on %s: %v", tlsSecret.Source, err)
+	}
+	if crlSecret := d.mapper.Get(ingtypes.HostAuthTLSCRLSecret); ssl.CAFilename != "" && crlSecret.Source != nil && crlSecret.Value != "" {
+		if crlFile, err := c.cache.GetCRLSecretPath(crlSecret.Source.Namespace, crlSecret.Value); err == nil {
+			ssl.CRLFilename = crlFile.Filename
+			ssl.CRLHash = crlFile.SHA1Hash
+		} else {
+			c.logger.Error("error building TLS auth CRL config on %s: %v", crlSecret.Source, err)
+		}
+	}
+	if ssl.CAFilename == "" && d.mapper.Get(ingtypes.HostAuthTLSStrict).Bool() {
+		// misconfigured auth-tls and auth-tls-strict as `true`, using
+		// a fake CA so any connection attempt will fail
+		ssl.CAFilename = c.fakeCA.Filename
+		ssl.CAHash = c.fakeCA.SHA1Hash
+	}
+	ssl.CAVerifyOptional = verify.Value == "optional" || verify.Value == "optional_no_ca"
+}
+
+func (c *updater) buildTCPDynamic(d *tcpData) {
+	d.backend.Dynamic = hatypes.DynBackendConfig{
+		DynUpdate:    d.mapper.Get(ingtypes.BackDynamicScaling).Bool(),
+		BlockSize:    d.mapper.Get(ingtypes.BackBackendServerSlotsInc).Int(),
+		MinFreeSlots: d.mapper.Get(ingtypes.BackSlotsMinFree).Int(),
+		DrainTimeout: c.validateTime(d.mapper.Get(ingtypes.BackDrainTimeout)),
+	}
+}
+
+func (c *updater) buildTCPProxyProtocol(d *tcpData) {
+	cfg := d.mapper.Get(ingtypes.BackProxyProtocol)
+	if cfg.Source == nil {
+		return
+	}
+	switch cfg.Value {
+	case "v1", "v2":
+		d.backend.ProxyProt.EncodeVersion = cfg.Value
+	default:
+		c.logger.Warn("ignoring invalid proxy protocol version on %v: %s", cfg.Source, cfg.Value)
+	}
+}
+
+func (c *updater) buildTCPTimeout(d *tcpData) {
+	if cfg := d.mapper.Get(ingtypes.HostTimeoutClient); cfg.Source != nil {
+		d.backend.Timeout.Client = c.validateTime(cfg)
+	}
+	if cfg := d.mapper.Get(ingtypes.BackTimeoutConnect); cfg.Source != nil {
+		d.backend.Timeout.Connect = c.validateTime(cfg)
+	}
+	if cfg := d.mapper.Get(ingtypes.BackTimeoutServer); cfg.Source != nil {
+		d.backend.Timeout.Server = c.validateTime(cfg)
+	}
+}
+
+func (c *updater) buildTCPWhitelist(d *tcpData) {
+	wlist := d.mapper.Get(ingtypes.BackWhitelistSourceRange)
+	if wlist.Source == nil {
+		return
+	}
+	d.backend.Whitelist = c.splitCIDR(wlist)
+}
+
+func (c *updater) buildTCPSecure(d *tcpData) {
+	if !d.mapper.Get(ingtypes.BackSecureBackends).Bool() {
+		return
+	}
+	srvssl := &d.backend.ServerSSL
+	srvssl.Enabled = true
+	if crt := d.mapper.Get(ingtypes.BackSecureCrtSecret); crt.Value != "" {
+		if crtFile, err := c.cache.GetTLSSecretPath(crt.Source.Namespace, crt.Value); err == nil {
+			srvssl.CrtFilename = crtFile.Filename
+			srvssl.CrtHash = crtFile.SHA1Hash
+		} else {
+			c.logger.Warn("skipping client certificate on %v: %v", crt.Source, err)
+		}
+	}
+	if ca := d.mapper.Get(ingtypes.BackSecureVerifyCASecret); ca.Value != "" {
+		if caFile, err := c.cache.GetCASecretPath(ca.Source.Namespace, ca.Value); err == nil {
+			srvssl.CAFilename = caFile.Filename
+			srvssl.CAHash = caFile.SHA1Hash
+		} else {
+			c.logger.Warn("skipping CA on %v: %v", ca.Source, err)
+		}
+	}
+	srvssl.SNI = d.mapper.Get(ingtypes.TCPServiceSecureSNI).Value
+}
+
+func (c *updater) buildTCPTLSPolicy(d *tcpData) {
+	// only options declared as annotations are used,
+	// global config is already used as the default of the binds
+	ssl := &d.backend.SSL
+	if ssl.Filename == "" {
+		return
+	}
+	if cfg := d.mapper.Get(ingtypes.HostTLSALPN); cfg.Source != nil {
+		ssl.ALPN = cfg.Value
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLCiphers); cfg.Source != nil {
+		ssl.Ciphers = cfg.Value
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLCipherSuites); cfg.Source != nil {
+		ssl.CipherSuites = cfg.Value
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLMinVersion); cfg.Source != nil {
+		ssl.MinVersion = c.validateTLSVersion(cfg)
+	}
+	if cfg := d.mapper.Get(ingtypes.HostSSLMaxVersion); cfg.Source != nil {
+		ssl.MaxVersion = c.validateTLSVersion(cfg)
+	}
+}
+
+func (c *updater) buildTCPZoneAware(d *tcpData) {
+	c.zoneAware(d.backend.BackendName(), d.mapper, &d.backend.Dynamic, d.backend.Endpoints)
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"testing"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestTCPBackend(t *testing.T) {
+	testCases := []struct {
+		annDefault map[string]string
+		ann        map[string]string
+		expected   hatypes.TCPBackend
+		logging    string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			annDefault: map[string]string{
+				ingtypes.BackBalanceAlgorithm:      "roundrobin",
+				ingtypes.BackBackendCheckInterval:  "2s",
+				ingtypes.BackBackendServerSlotsInc: "4",
+				ingtypes.BackDynamicScaling:        "true",
+				ingtypes.BackSlotsMinFree:          "1",
+				ingtypes.HostTimeoutClient:         "50s",
+			},
+			expected: hatypes.TCPBackend{
+				BalanceAlgorithm: "roundrobin",
+				CheckInterval:    "2s",
+				Dynamic:          hatypes.DynBackendConfig{DynUpdate: true, BlockSize: 4, MinFreeSlots: 1},
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackBalanceAlgorithm:     "leastconn",
+				ingtypes.BackMaxconnServer:        "100",
+				ingtypes.TCPServiceProxyProtocol:  "true",
+				ingtypes.BackProxyProtocol:        "v2",
+				ingtypes.HostTimeoutClient:        "1m",
+				ingtypes.BackTimeoutConnect:       "5s",
+				ingtypes.BackTimeoutServer:        "1m",
+				ingtypes.BackWhitelistSourceRange: "10.0.0.0/8,192.168.0.1",
+				ingtypes.BackBackendCheckInterval: "5s",
+			},
+			expected: hatypes.TCPBackend{
+				BalanceAlgorithm: "leastconn",
+				CheckInterval:    "5s",
+				MaxConnServer:    100,
+				ProxyProt:        hatypes.TCPProxyProt{Decode: true, EncodeVersion: "v2"},
+				Timeout:          hatypes.TCPTimeoutConfig{Client: "1m", Connect: "5s", Server: "1m"},
+				Whitelist:        []string{"10.0.0.0/8", "192.168.0.1"},
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackProxyProtocol:        "v2-ssl",
+				ingtypes.BackTimeoutConnect:       "5",
+				ingtypes.BackWhitelistSourceRange: "10.0.0.0/8,10.0.0.0/40",
+			},
+			expected: hatypes.TCPBackend{
+				Whitelist: []string{"10.0.0.0/8"},
+			},
+			logging: `
+WARN ignoring invalid proxy protocol version on service 'default/echo': v2-ssl
+WARN ignoring invalid time format on service 'default/echo': 5
+WARN skipping invalid IP or cidr on service 'default/echo': 10.0.0.0/40`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "echo", Type: "service"}
+	for i, test := range testCases {
+		c := setup(t)
+		mapper := NewMapBuilder(c.logger, "", test.annDefault).NewMapper()
+		mapper.AddAnnotations(source, "default/echo", test.ann)
+		backend := &hatypes.TCPBackend{}
+		c.createUpdater().UpdateTCPBackendConfig(backend, mapper)
+		c.compareObjects("tcp backend", i, *backend, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestTCPSSL(t *testing.T) {
+	testCases := []struct {
+		crtFilename string
+		ann         map[string]string
+		expSSL      hatypes.TCPSSL
+		expServer   hatypes.TCPServerSSL
+		logging     string
+	}{
+		// 0
+		{
+			crtFilename: "/path/crt.pem",
+			expSSL:      hatypes.TCPSSL{Filename: "/path/crt.pem"},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret: "cafile",
+				ingtypes.HostTLSALPN:       "mqtt",
+			},
+			logging: `WARN ignoring client certificate authentication on service 'default/echo': TCP service doesn't offload SSL`,
+		},
+		// 2
+		{
+			crtFilename: "/path/crt.pem",
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:    "cafile",
+				ingtypes.HostAuthTLSCRLSecret: "crlfile",
+			},
+			expSSL: hatypes.TCPSSL{
+				Filename:    "/path/crt.pem",
+				CAFilename:  "/path/ca.crt",
+				CAHash:      "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+				CRLFilename: "/path/ca.crl",
+				CRLHash:     "fda2f454cccea3568c67232a614844fb6ae58f6c",
+			},
+		},
+		// 3
+		{
+			crtFilename: "/path/crt.pem",
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:       "cafile",
+				ingtypes.HostAuthTLSVerifyClient: "optional",
+				ingtypes.HostSSLCiphers:          "ECDHE-RSA-AES128-GCM-SHA256",
+				ingtypes.HostSSLMinVersion:       "TLSv1.2",
+				ingtypes.HostTLSALPN:             "mqtt",
+			},
+			expSSL: hatypes.TCPSSL{
+				Filename:         "/path/crt.pem",
+				ALPN:             "mqtt",
+				CAFilename:       "/path/ca.crt",
+				CAHash:           "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+				CAVerifyOptional: true,
+				Ciphers:          "ECDHE-RSA-AES128-GCM-SHA256",
+				MinVersion:       "TLSv1.2",
+			},
+		},
+		// 4
+		{
+			crtFilename: "/path/crt.pem",
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret: "notfound",
+				ingtypes.HostAuthTLSStrict: "true",
+			},
+			expSSL: hatypes.TCPSSL{
+				Filename:   "/path/crt.pem",
+				CAFilename: fakeCAFilename,
+				CAHash:     fakeCAHash,
+			},
+			logging: `ERROR error building TLS auth config on service 'default/echo': secret not found: 'default/notfound'`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackSecureBackends:       "true",
+				ingtypes.BackSecureCrtSecret:      "crtfile",
+				ingtypes.BackSecureVerifyCASecret: "upstreamca",
+				ingtypes.TCPServiceSecureSNI:      "db.internal",
+			},
+			expServer: hatypes.TCPServerSSL{
+				Enabled:     true,
+				CAFilename:  "/path/upstream-ca.crt",
+				CAHash:      "13726e1ae0c69f2405f053713578f817179e1dee",
+				CrtFilename: "/path/crt.pem",
+				CrtHash:     "1d83b0209d43a2be9ee1c90f7e6a76199f307c83",
+				SNI:         "db.internal",
+			},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackSecureBackends: "true",
+			},
+			expServer: hatypes.TCPServerSSL{
+				Enabled: true,
+			},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "echo", Type: "service"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.SecretCAPath = map[string]string{
+			"default/cafile":     "/path/ca.crt",
+			"default/upstreamca": "/path/upstream-ca.crt",
+		}
+		c.cache.SecretCRLPath = map[string]string{
+			"default/crlfile": "/path/ca.crl",
+		}
+		c.cache.SecretTLSPath = map[string]string{
+			"default/crtfile": "/path/crt.pem",
+		}
+		mapper := NewMapBuilder(c.logger, "", map[string]string{}).NewMapper()
+		mapper.AddAnnotations(source, "default/echo", test.ann)
+		d := &tcpData{
+			backend: &hatypes.TCPBackend{SSL: hatypes.TCPSSL{Filename: test.crtFilename}},
+			mapper:  mapper,
+		}
+		u := c.createUpdater()
+		u.buildTCPAuthTLS(d)
+		u.buildTCPSecure(d)
+		u.buildTCPTLSPolicy(d)
+		c.compareObjects("ssl", i, d.backend.SSL, test.expSSL)
+		c.compareObjects("server ssl", i, d.backend.ServerSSL, test.expServer)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
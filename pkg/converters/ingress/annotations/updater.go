@@ -22,6 +22,7 @@ import (
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
@@ -33,23 +34,32 @@ type Updater interface {
 	UpdateGlobalConfig(global *hatypes.Global, mapper *Mapper)
 	UpdateHostConfig(host *hatypes.Host, mapper *Mapper)
 	UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
+	UpdateTCPBackendConfig(backend *hatypes.TCPBackend, mapper *Mapper)
 }
 
 // NewUpdater ...
-func NewUpdater(haproxy haproxy.Config, options *ingtypes.ConverterOptions) Updater {
+func NewUpdater(haproxy haproxy.Config, options *ingtypes.ConverterOptions, globalConfig map[string]string) Updater {
 	return &updater{
-		haproxy: haproxy,
-		cache:   options.Cache,
-		logger:  options.Logger,
-		fakeCA:  options.FakeCAFile,
+		haproxy:      haproxy,
+		cache:        options.Cache,
+		logger:       options.Logger,
+		fakeCA:       options.FakeCAFile,
+		rollouts:     options.Rollouts,
+		acmeSocket:   options.AcmeSocket,
+		globalConfig: globalConfig,
 	}
 }
 
 type updater struct {
-	haproxy haproxy.Config
-	cache   convtypes.Cache
-	logger  types.Logger
-	fakeCA  convtypes.File
+	haproxy      haproxy.Config
+	cache        convtypes.Cache
+	logger       types.Logger
+	fakeCA       convtypes.File
+	rollouts     convtypes.Rollouts
+	acmeSocket   string
+	globalConfig map[string]string
+	zone         string
+	zoneRead     bool
 }
 
 type globalData struct {
@@ -67,6 +77,21 @@ type backData struct {
 	mapper  *Mapper
 }
 
+type tcpData struct {
+	backend *hatypes.TCPBackend
+	mapper  *Mapper
+}
+
+// isExplicit returns true if the option was declared as an annotation
+// or in the global ConfigMap, instead of using its default value
+func (c *updater) isExplicit(mapper *Mapper, key string) bool {
+	if mapper.Get(key).Source != nil {
+		return true
+	}
+	_, found := c.globalConfig[key]
+	return found
+}
+
 var regexValidTime = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
 
 func (c *updater) validateTime(cfg *ConfigValue) string {
@@ -81,6 +106,26 @@ func (c *updater) validateTime(cfg *ConfigValue) string {
 	return cfg.Value
 }
 
+var validTLSVersions = map[string]bool{
+	"SSLv3":   true,
+	"TLSv1.0": true,
+	"TLSv1.1": true,
+	"TLSv1.2": true,
+	"TLSv1.3": true,
+}
+
+func (c *updater) validateTLSVersion(cfg *ConfigValue) string {
+	if cfg.Value == "" || validTLSVersions[cfg.Value] {
+		return cfg.Value
+	}
+	if cfg.Source != nil {
+		c.logger.Warn("ignoring invalid TLS version on %v: %s", cfg.Source, cfg.Value)
+	} else {
+		c.logger.Warn("ignoring invalid TLS version on global/default config: %s", cfg.Value)
+	}
+	return ""
+}
+
 func (c *updater) splitCIDR(cidrlist *ConfigValue) []string {
 	var cidrslice []string
 	for _, cidr := range utils.Split(cidrlist.Value, ",") {
@@ -97,6 +142,64 @@ func (c *updater) splitCIDR(cidrlist *ConfigValue) []string {
 	return cidrslice
 }
 
+// localZone returns the zone of the node where the controller is running,
+// an empty string means that the zone couldn't be read.
+func (c *updater) localZone() string {
+	if c.zoneRead {
+		return c.zone
+	}
+	c.zoneRead = true
+	pod, err := c.cache.GetControllerPod()
+	if err != nil {
+		c.logger.Warn("ignoring zone aware routing, error reading the controller pod: %v", err)
+		return ""
+	}
+	node, err := c.cache.GetNode(pod.Spec.NodeName)
+	if err != nil {
+		c.logger.Warn("ignoring zone aware routing, error reading the controller node: %v", err)
+		return ""
+	}
+	c.zone = convutils.NodeZone(node)
+	if c.zone == "" {
+		c.logger.Warn("ignoring zone aware routing, node '%s' doesn't have a zone label", node.Name)
+	}
+	return c.zone
+}
+
+// zoneAware configures endpoints outside the local zone as backup servers,
+// unless the local zone has less than zone-aware-min-endpoints active
+// endpoints. Endpoints without zone information are never a backup server.
+// Empty slots of backup servers are kept whenever the local zone is known,
+// so endpoints can change zones without reloading haproxy.
+func (c *updater) zoneAware(backname string, mapper *Mapper, dynamic *hatypes.DynBackendConfig, endpoints []*hatypes.Endpoint) {
+	if !mapper.Get(ingtypes.BackZoneAwareRouting).Bool() {
+		return
+	}
+	zone := c.localZone()
+	if zone == "" {
+		return
+	}
+	dynamic.BackupSlots = true
+	minEndpoints := mapper.Get(ingtypes.BackZoneAwareMinEndpoints).Int()
+	if minEndpoints < 1 {
+		minEndpoints = 1
+	}
+	local := 0
+	for _, ep := range endpoints {
+		if ep.Weight > 0 && ep.InZone(zone) {
+			local++
+		}
+	}
+	if local < minEndpoints {
+		c.logger.InfoV(2, "using endpoints of all zones on backend '%s': found %d of %d active endpoint(s) on zone '%s'",
+			backname, local, minEndpoints, zone)
+		return
+	}
+	for _, ep := range endpoints {
+		ep.Backup = len(ep.Zones) > 0 && !ep.InZone(zone)
+	}
+}
+
 func (c *updater) UpdateGlobalConfig(global *hatypes.Global, mapper *Mapper) {
 	data := &globalData{
 		global: global,
@@ -110,6 +213,7 @@ func (c *updater) UpdateGlobalConfig(global *hatypes.Global, mapper *Mapper) {
 	global.LoadServerState = mapper.Get(ingtypes.GlobalLoadServerState).Bool()
 	global.SSL.ALPN = mapper.Get(ingtypes.GlobalTLSALPN).Value
 	global.StrictHost = mapper.Get(ingtypes.GlobalStrictHost).Bool()
+	c.buildGlobalAcme(data)
 	c.buildGlobalBind(data)
 	c.buildGlobalCustomConfig(data)
 	c.buildGlobalDNS(data)
@@ -118,6 +222,7 @@ func (c *updater) UpdateGlobalConfig(global *hatypes.Global, mapper *Mapper) {
 	c.buildGlobalHTTPStoHTTP(data)
 	c.buildGlobalModSecurity(data)
 	c.buildGlobalProc(data)
+	c.buildGlobalRequestID(data)
 	c.buildGlobalSSL(data)
 	c.buildGlobalStats(data)
 	c.buildGlobalSyslog(data)
@@ -133,9 +238,13 @@ func (c *updater) UpdateHostConfig(host *hatypes.Host, mapper *Mapper) {
 	host.Alias.AliasName = mapper.Get(ingtypes.HostServerAlias).Value
 	host.Alias.AliasRegex = mapper.Get(ingtypes.HostServerAliasRegex).Value
 	host.VarNamespace = mapper.Get(ingtypes.HostVarNamespace).Bool()
+	host.NoAccessLog = !mapper.Get(ingtypes.HostAccessLog).Bool()
 	c.buildHostAuthTLS(data)
+	c.buildHostLogFormat(data)
+	c.buildHostRequestID(data)
 	c.buildHostSSLPassthrough(data)
 	c.buildHostTimeout(data)
+	c.buildHostTLSPolicy(data)
 }
 
 func (c *updater) UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper) {
@@ -148,9 +257,11 @@ func (c *updater) UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
 	backend.CustomConfig = utils.LineToSlice(mapper.Get(ingtypes.BackConfigBackend).Value)
 	backend.Server.MaxConn = mapper.Get(ingtypes.BackMaxconnServer).Int()
 	backend.Server.MaxQueue = mapper.Get(ingtypes.BackMaxQueueServer).Int()
+	backend.Server.SlowStart = c.validateTime(mapper.Get(ingtypes.BackSlowStart))
 	backend.TLS.AddCertHeader = mapper.Get(ingtypes.BackAuthTLSCertHeader).Bool()
 	c.buildBackendAffinity(data)
 	c.buildBackendAuthHTTP(data)
+	c.buildBackendAuthTLS(data)
 	c.buildBackendBlueGreen(data)
 	c.buildBackendBodySize(data)
 	c.buildBackendCors(data)
@@ -161,7 +272,9 @@ func (c *updater) UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
 	c.buildBackendHSTS(data)
 	c.buildBackendLimit(data)
 	c.buildBackendOAuth(data)
+	c.buildBackendPassiveCheck(data)
 	c.buildBackendProxyProtocol(data)
+	c.buildBackendRetry(data)
 	c.buildBackendRewriteURL(data)
 	c.buildBackendSecure(data)
 	c.buildBackendServerNaming(data)
@@ -170,4 +283,24 @@ func (c *updater) UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
 	c.buildBackendWAF(data)
 	c.buildBackendWhitelistHTTP(data)
 	c.buildBackendWhitelistTCP(data)
+	c.buildBackendZoneAware(data)
+}
+
+func (c *updater) UpdateTCPBackendConfig(backend *hatypes.TCPBackend, mapper *Mapper) {
+	data := &tcpData{
+		backend: backend,
+		mapper:  mapper,
+	}
+	backend.BalanceAlgorithm = mapper.Get(ingtypes.BackBalanceAlgorithm).Value
+	backend.CheckInterval = c.validateTime(mapper.Get(ingtypes.BackBackendCheckInterval))
+	backend.MaxConnServer = mapper.Get(ingtypes.BackMaxconnServer).Int()
+	backend.ProxyProt.Decode = mapper.Get(ingtypes.TCPServiceProxyProtocol).Bool()
+	c.buildTCPAuthTLS(data)
+	c.buildTCPDynamic(data)
+	c.buildTCPProxyProtocol(data)
+	c.buildTCPSecure(data)
+	c.buildTCPTimeout(data)
+	c.buildTCPTLSPolicy(data)
+	c.buildTCPWhitelist(data)
+	c.buildTCPZoneAware(data)
 }
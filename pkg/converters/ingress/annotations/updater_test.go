@@ -25,7 +25,6 @@ import (
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
-	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 )
@@ -52,7 +51,7 @@ func setup(t *testing.T) *testConfig {
 	logger := &types_helper.LoggerMock{T: t}
 	return &testConfig{
 		t:       t,
-		haproxy: haproxy.CreateInstance(logger, &ha_helper.BindUtilsMock{}, haproxy.InstanceOptions{}).Config(),
+		haproxy: haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config(),
 		cache:   &conv_helper.CacheMock{},
 		logger:  logger,
 	}
@@ -71,6 +70,7 @@ func (c *testConfig) createUpdater() *updater {
 			Filename: fakeCAFilename,
 			SHA1Hash: fakeCAHash,
 		},
+		acmeSocket: "/var/run/acme.sock",
 	}
 }
 
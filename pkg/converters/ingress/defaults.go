@@ -26,19 +26,28 @@ const (
 
 func createDefaults() map[string]string {
 	return map[string]string{
+		types.HostAccessLog:        "true",
+		types.HostRequestID:        "none",
 		types.HostTimeoutClient:    "50s",
 		types.HostTimeoutClientFin: "50s",
 		//
 		types.BackBackendServerNaming:   "sequence",
 		types.BackBackendServerSlotsInc: "1",
+		types.BackSlotsFromHPA:          "false",
 		types.BackSlotsMinFree:          "6",
 		types.BackBalanceAlgorithm:      "roundrobin",
+		types.BackBlueGreenErrorMetric:  "hrsp_5xx",
+		types.BackBlueGreenInterval:     "10m",
 		types.BackCorsAllowHeaders:      "DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization",
 		types.BackCorsAllowMethods:      "GET, PUT, POST, DELETE, PATCH, OPTIONS",
 		types.BackCorsAllowOrigin:       "*",
 		types.BackCorsMaxAge:            "86400",
 		types.BackDynamicScaling:        "true",
+		types.BackHealthCheckErrorLimit: "10",
+		types.BackHealthCheckFromProbe:  "false",
 		types.BackHealthCheckInterval:   "2s",
+		types.BackHealthCheckObserve:    "none",
+		types.BackHealthCheckOnError:    "fail-check",
 		types.BackHSTS:                  "true",
 		types.BackHSTSIncludeSubdomains: "false",
 		types.BackHSTSMaxAge:            "15768000",
@@ -54,7 +63,12 @@ func createDefaults() map[string]string {
 		types.BackTimeoutServer:         "50s",
 		types.BackTimeoutServerFin:      "50s",
 		types.BackTimeoutTunnel:         "1h",
+		types.BackZoneAwareMinEndpoints: "1",
+		types.BackZoneAwareRouting:      "false",
 		//
+		types.GlobalAcmeExpiring:                 "30",
+		types.GlobalAcmeShared:                   "false",
+		types.GlobalAcmeTermsAgreed:              "false",
 		types.GlobalBindIPAddrHealthz:            "*",
 		types.GlobalBindIPAddrHTTP:               "*",
 		types.GlobalBindIPAddrStats:              "*",
@@ -77,6 +91,7 @@ func createDefaults() map[string]string {
 		types.GlobalNbprocBalance:                "1",
 		types.GlobalNbthread:                     "2",
 		types.GlobalNoTLSRedirectLocations:       "/.well-known/acme-challenge",
+		types.GlobalRequestIDFormat:              "%{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid",
 		types.GlobalSSLCiphers:                   defaultSSLCiphers,
 		types.GlobalSSLDHDefaultMaxSize:          "2048",
 		types.GlobalSSLHeadersPrefix:             "X-SSL",
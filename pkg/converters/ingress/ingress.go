@@ -18,6 +18,7 @@ package ingress
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -52,11 +53,13 @@ func NewIngressConverter(options *ingtypes.ConverterOptions, haproxy haproxy.Con
 		options:            options,
 		logger:             options.Logger,
 		cache:              options.Cache,
+		metrics:            options.Metrics,
 		mapBuilder:         annotations.NewMapBuilder(options.Logger, options.AnnotationPrefix+"/", defaultConfig),
-		updater:            annotations.NewUpdater(haproxy, options),
+		updater:            annotations.NewUpdater(haproxy, options, globalConfig),
 		globalConfig:       annotations.NewMapBuilder(options.Logger, "", defaultConfig).NewMapper(),
 		hostAnnotations:    map[*hatypes.Host]*annotations.Mapper{},
 		backendAnnotations: map[*hatypes.Backend]*annotations.Mapper{},
+		tcpAnnotations:     map[*hatypes.TCPBackend]*annotations.Mapper{},
 	}
 	if options.DefaultBackend != "" {
 		if backend, err := c.addBackend(&annotations.Source{}, "*/", options.DefaultBackend, "", map[string]string{}); err == nil {
@@ -73,34 +76,42 @@ type converter struct {
 	options            *ingtypes.ConverterOptions
 	logger             types.Logger
 	cache              convtypes.Cache
+	metrics            convtypes.Metrics
 	defaultCrt         convtypes.File
+	defaultCrtName     string
 	mapBuilder         *annotations.MapBuilder
 	updater            annotations.Updater
 	globalConfig       *annotations.Mapper
 	hostAnnotations    map[*hatypes.Host]*annotations.Mapper
 	backendAnnotations map[*hatypes.Backend]*annotations.Mapper
+	tcpAnnotations     map[*hatypes.TCPBackend]*annotations.Mapper
 }
 
 func (c *converter) Sync(ingress []*networking.Ingress) {
+	c.metrics.ClearCertMetrics()
 	c.syncDefaultCrt()
 	for _, ing := range ingress {
 		c.syncIngress(ing)
 	}
+	c.syncTCPServices()
+	c.syncFallbackServices()
 	c.syncAnnotations()
 }
 
 func (c *converter) syncDefaultCrt() {
 	crt := c.options.FakeCrtFile
+	crtName := ""
 	if c.options.DefaultCrtSecret != "" {
-		var err error
-		crt, err = c.cache.GetTLSSecretPath("", c.options.DefaultCrtSecret)
-		if err != nil {
-			crt = c.options.FakeCrtFile
+		if tlsFile, err := c.cache.GetTLSSecretPath("", c.options.DefaultCrtSecret); err == nil {
+			crt = tlsFile
+			crtName = c.options.DefaultCrtSecret
+		} else {
 			c.logger.Warn("using auto generated fake certificate due to an error reading default TLS certificate: %v", err)
 		}
 	}
 	c.haproxy.ConfigDefaultX509Cert(crt.Filename)
 	c.defaultCrt = crt
+	c.defaultCrtName = crtName
 }
 
 func (c *converter) syncIngress(ing *networking.Ingress) {
@@ -151,6 +162,7 @@ func (c *converter) syncIngress(ing *networking.Ingress) {
 				continue
 			}
 			host.AddPath(backend, uri)
+			host.FindPath(uri).Ingress = fullIngName
 			sslpassthrough, _ := strconv.ParseBool(annHost[ingtypes.HostSSLPassthrough])
 			sslpasshttpport := annHost[ingtypes.HostSSLPassthroughHTTPPort]
 			if sslpassthrough && sslpasshttpport != "" {
@@ -162,10 +174,13 @@ func (c *converter) syncIngress(ing *networking.Ingress) {
 		for _, tls := range ing.Spec.TLS {
 			for _, tlshost := range tls.Hosts {
 				if tlshost == hostname {
-					tlsPath := c.addTLS(source, tls.SecretName)
+					tlsPath := c.addTLS(source, hostname, tls.SecretName)
 					if host.TLS.TLSHash == "" {
 						host.TLS.TLSFilename = tlsPath.Filename
 						host.TLS.TLSHash = tlsPath.SHA1Hash
+					} else if host.TLS.TLSHash != c.defaultCrt.SHA1Hash && tlsPath.SHA1Hash != c.defaultCrt.SHA1Hash {
+						// more than one certificate of the same host, eg RSA and ECDSA
+						host.TLS.AddTLSExtraCert(tlsPath.Filename, tlsPath.SHA1Hash)
 					} else if host.TLS.TLSHash != tlsPath.SHA1Hash {
 						msg := fmt.Sprintf("TLS of host '%s' was already assigned", host.Hostname)
 						if tls.SecretName != "" {
@@ -174,12 +189,104 @@ func (c *converter) syncIngress(ing *networking.Ingress) {
 							c.logger.Warn("skipping default TLS secret of ingress '%s': %s", fullIngName, msg)
 						}
 					}
+					if signer := annHost[ingtypes.HostCertSigner]; signer != "" {
+						c.addCertSigner(source, signer, tls.SecretName, hostname)
+					}
 				}
 			}
 		}
 	}
 }
 
+// syncTCPServices adds a TCP backend for every service that declares
+// a public port in its annotations. Services are sorted by age, so the
+// oldest service wins if two or more services declare the same port, or
+// the same SNI hostname on a port shared by TLS passthrough services.
+func (c *converter) syncTCPServices() {
+	services, err := c.cache.GetServiceList()
+	if err != nil {
+		c.logger.Error("error reading TCP services: %v", err)
+		return
+	}
+	var tcpServices []*api.Service
+	prefix := c.options.AnnotationPrefix + "/"
+	for _, svc := range services {
+		if _, found := svc.Annotations[prefix+ingtypes.TCPServicePort]; found {
+			tcpServices = append(tcpServices, svc)
+		}
+	}
+	sort.Slice(tcpServices, func(i, j int) bool {
+		s1 := tcpServices[i]
+		s2 := tcpServices[j]
+		if s1.CreationTimestamp != s2.CreationTimestamp {
+			return s1.CreationTimestamp.Before(&s2.CreationTimestamp)
+		}
+		return s1.Namespace+"/"+s1.Name < s2.Namespace+"/"+s2.Name
+	})
+	for _, svc := range tcpServices {
+		c.syncTCPService(svc)
+	}
+}
+
+func (c *converter) syncTCPService(svc *api.Service) {
+	fullSvcName := svc.Namespace + "/" + svc.Name
+	annHost, ann := c.readAnnotations(svc.Annotations)
+	for name, value := range annHost {
+		ann[name] = value
+	}
+	publicPort, err := strconv.Atoi(ann[ingtypes.TCPServicePort])
+	if err != nil || publicPort <= 0 {
+		c.logger.Warn("skipping TCP service '%s': invalid public port: %s", fullSvcName, ann[ingtypes.TCPServicePort])
+		return
+	}
+	sni := strings.ToLower(ann[ingtypes.TCPServiceSNI])
+	for _, backend := range c.haproxy.TCPBackends() {
+		if backend.Port != publicPort {
+			continue
+		}
+		if sni == "" || backend.SNI == "" {
+			c.logger.Warn("skipping TCP service '%s': public port %d is already in use by '%s'", fullSvcName, publicPort, backend.Name)
+			return
+		}
+		if backend.SNI == sni {
+			c.logger.Warn("skipping TCP service '%s': SNI hostname '%s' on public port %d is already in use by '%s'", fullSvcName, sni, publicPort, backend.Name)
+			return
+		}
+	}
+	svcPort := ann[ingtypes.TCPServiceTargetPort]
+	if svcPort == "" && len(svc.Spec.Ports) > 0 {
+		svcPort = svc.Spec.Ports[0].TargetPort.String()
+	}
+	port := convutils.FindServicePort(svc, svcPort)
+	if port == nil {
+		c.logger.Warn("skipping TCP service '%s': port not found: '%s'", fullSvcName, svcPort)
+		return
+	}
+	var crtFile convtypes.File
+	if secret := ann[ingtypes.TCPServiceCrtSecret]; secret != "" && sni != "" {
+		c.logger.Warn("ignoring crt secret of TCP service '%s': services with SNI hostname use TLS passthrough", fullSvcName)
+	} else if secret != "" {
+		crtFile, err = c.cache.GetTLSSecretPath(svc.Namespace, secret)
+		if err != nil {
+			c.logger.Warn("skipping TCP service '%s': %v", fullSvcName, err)
+			return
+		}
+	}
+	backend := c.haproxy.AcquireTCPBackend(svc.Namespace+"_"+svc.Name, publicPort)
+	if err := c.addEndpoints(svc, port, backend); err != nil {
+		c.logger.Error("error adding endpoints of TCP service '%s': %v", fullSvcName, err)
+	}
+	backend.SNI = sni
+	backend.SSL.Filename = crtFile.Filename
+	mapper := c.mapBuilder.NewMapper()
+	mapper.AddAnnotations(&annotations.Source{
+		Namespace: svc.Namespace,
+		Name:      svc.Name,
+		Type:      "service",
+	}, fullSvcName, ann)
+	c.tcpAnnotations[backend] = mapper
+}
+
 func (c *converter) syncAnnotations() {
 	c.updater.UpdateGlobalConfig(c.haproxy.Global(), c.globalConfig)
 	if ann, found := c.hostAnnotations[c.haproxy.DefaultHost()]; found {
@@ -195,6 +302,94 @@ func (c *converter) syncAnnotations() {
 			c.updater.UpdateBackendConfig(backend, ann)
 		}
 	}
+	for _, backend := range c.haproxy.TCPBackends() {
+		if ann, found := c.tcpAnnotations[backend]; found {
+			c.updater.UpdateTCPBackendConfig(backend, ann)
+		}
+	}
+}
+
+// syncFallbackServices configures the backend of the fallback service of
+// HTTP backends and TCP services. The fallback backend is a distinct one,
+// used only when the primary backend doesn't have any usable server.
+// This should run before the annotations update, so the fallback backend
+// is also configured by the annotations of its service.
+func (c *converter) syncFallbackServices() {
+	globalFallback := c.globalConfig.Get(ingtypes.BackFallbackService).Value
+	if globalFallback != "" && strings.Index(globalFallback, "/") < 0 {
+		c.logger.Warn("ignoring global fallback service '%s': a namespace is required on global config", globalFallback)
+		globalFallback = ""
+	}
+	// backends might be added and sorted while adding fallback backends
+	backends := append([]*hatypes.Backend{}, c.haproxy.Backends()...)
+	httpFallbacks := map[*hatypes.Backend]bool{}
+	for _, backend := range backends {
+		fallback := c.readFallbackService(c.backendAnnotations[backend], globalFallback)
+		if fallback == nil {
+			continue
+		}
+		fbBackend, err := c.addFallbackBackend(fallback)
+		if err != nil {
+			c.logger.Warn("ignoring fallback service '%s' of backend '%s': %v", fallback.Value, backend.ID, err)
+			continue
+		}
+		if fbBackend == backend {
+			// a global fallback service is also the fallback of its own backend
+			if fallback.Source != nil {
+				c.logger.Warn("ignoring fallback service '%s' of backend '%s': fallback and backend are the same", fallback.Value, backend.ID)
+			}
+			continue
+		}
+		backend.Fallback = fbBackend.ID
+		httpFallbacks[fbBackend] = true
+	}
+	for _, backend := range c.haproxy.TCPBackends() {
+		fallback := c.readFallbackService(c.tcpAnnotations[backend], globalFallback)
+		if fallback == nil {
+			continue
+		}
+		fbBackend, err := c.addFallbackBackend(fallback)
+		if err == nil && (httpFallbacks[fbBackend] || len(fbBackend.Paths) > 0) {
+			err = fmt.Errorf("service is already used by HTTP backends")
+		}
+		if err != nil {
+			c.logger.Warn("ignoring fallback service '%s' of TCP service '%s': %v", fallback.Value, backend.BackendName(), err)
+			continue
+		}
+		fbBackend.ModeTCP = true
+		backend.Fallback = fbBackend.ID
+	}
+}
+
+// readFallbackService returns the fallback service of a backend, or nil
+// if not declared. A global fallback service is only used if valid.
+func (c *converter) readFallbackService(mapper *annotations.Mapper, globalFallback string) *annotations.ConfigValue {
+	if mapper == nil {
+		return nil
+	}
+	fallback := mapper.Get(ingtypes.BackFallbackService)
+	if fallback.Value == "" || (fallback.Source == nil && fallback.Value != globalFallback) {
+		return nil
+	}
+	return fallback
+}
+
+// addFallbackBackend adds the backend of a `[namespace/]name[:port]` service.
+// The namespace defaults to the namespace of the annotation source.
+func (c *converter) addFallbackBackend(fallback *annotations.ConfigValue) (*hatypes.Backend, error) {
+	fullSvcName := fallback.Value
+	svcPort := ""
+	if i := strings.LastIndex(fullSvcName, ":"); i >= 0 {
+		fullSvcName, svcPort = fullSvcName[:i], fullSvcName[i+1:]
+	}
+	source := fallback.Source
+	if source == nil {
+		source = &annotations.Source{}
+	}
+	if strings.Index(fullSvcName, "/") < 0 {
+		fullSvcName = source.Namespace + "/" + fullSvcName
+	}
+	return c.addBackend(source, "", fullSvcName, svcPort, map[string]string{})
 }
 
 func (c *converter) addDefaultHostBackend(source *annotations.Source, fullSvcName, svcPort string, annHost, annBack map[string]string) error {
@@ -290,48 +485,108 @@ func (c *converter) addBackend(source *annotations.Source, hostpath, fullSvcName
 	return backend, nil
 }
 
-func (c *converter) addTLS(source *annotations.Source, secretName string) convtypes.File {
+func (c *converter) addTLS(source *annotations.Source, hostname, secretName string) convtypes.File {
 	if secretName != "" {
 		tlsFile, err := c.cache.GetTLSSecretPath(source.Namespace, secretName)
 		if err == nil {
+			if strings.Index(secretName, "/") < 0 {
+				secretName = source.Namespace + "/" + secretName
+			}
+			c.metrics.SetCertMetrics(source.Namespace, source.Name, hostname, secretName, tlsFile.Certificate)
 			return tlsFile
 		}
 		c.logger.Warn("using default certificate due to an error reading secret '%s' on %s: %v", secretName, source, err)
 	}
+	// the default certificate, or the fake one if the default
+	// certificate couldn't be read, is used as a fallback
+	crtName, fallback := c.defaultCrtName, "default"
+	if crtName == "" {
+		crtName, fallback = "fake", "fake"
+	}
+	c.metrics.SetCertFallback(source.Namespace, source.Name, hostname, fallback)
+	c.metrics.SetCertMetrics(source.Namespace, source.Name, hostname, crtName, c.defaultCrt.Certificate)
 	return c.defaultCrt
 }
 
-func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, backend *hatypes.Backend) error {
+func (c *converter) addCertSigner(source *annotations.Source, signer, secretName, hostname string) {
+	if signer != "acme" {
+		c.logger.Warn("ignoring unsupported cert signer '%s' on %s", signer, source)
+		return
+	}
+	if secretName == "" {
+		c.logger.Warn("skipping cert signer of host '%s' on %s: missing secret name", hostname, source)
+		return
+	}
+	if strings.HasPrefix(hostname, "*.") {
+		c.logger.Warn("skipping cert signer of host '%s' on %s: wildcard hostnames are not supported", hostname, source)
+		return
+	}
+	if strings.Index(secretName, "/") < 0 {
+		secretName = source.Namespace + "/" + secretName
+	}
+	c.haproxy.AcmeData().Storages().Acquire(secretName).AddDomains([]string{hostname})
+}
+
+// endpointAcquirer is implemented by HTTP and TCP backends
+type endpointAcquirer interface {
+	AcquireEndpoint(ip string, port int, targetRef string) *hatypes.Endpoint
+}
+
+func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, backend endpointAcquirer) error {
 	ready, notReady, err := convutils.CreateEndpoints(c.cache, svc, svcPort)
 	if err != nil {
 		return err
 	}
 	for _, addr := range ready {
-		backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+		ep := backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+		ep.Zones = addr.Zones
+		if weight, found := c.readPodWeight(addr.TargetRef); found {
+			ep.Weight = weight
+		}
 	}
 	if c.globalConfig.Get(ingtypes.GlobalDrainSupport).Bool() {
+		// not ready and terminating endpoints
 		for _, addr := range notReady {
 			ep := backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
 			ep.Weight = 0
-		}
-		pods, err := c.cache.GetTerminatingPods(svc)
-		if err != nil {
-			return err
-		}
-		for _, pod := range pods {
-			targetPort := convutils.FindContainerPort(pod, svcPort)
-			if targetPort > 0 {
-				ep := backend.AcquireEndpoint(pod.Status.PodIP, targetPort, pod.Namespace+"/"+pod.Name)
-				ep.Weight = 0
-			} else {
-				c.logger.Warn("skipping endpoint %s of service %s/%s: port '%s' was not found",
-					pod.Status.PodIP, svc.Namespace, svc.Name, svcPort.TargetPort.String())
-			}
+			ep.Zones = addr.Zones
 		}
 	}
 	return nil
 }
 
+// readPodWeight reads the weight annotation of the pod of an endpoint,
+// used to send more requests to pods with more resources. The weight is
+// relative to `initial-weight`, which is the weight of pods without the
+// annotation, so it's used as is while blue/green isn't configured.
+func (c *converter) readPodWeight(targetRef string) (int, bool) {
+	if targetRef == "" {
+		return 0, false
+	}
+	pod, err := c.cache.GetPod(targetRef)
+	if err != nil {
+		return 0, false
+	}
+	value, found := pod.Annotations[c.options.AnnotationPrefix+"/"+ingtypes.PodWeight]
+	if !found {
+		return 0, false
+	}
+	weight, err := strconv.Atoi(value)
+	if err != nil {
+		c.logger.Warn("ignoring weight of pod '%s': %v", targetRef, err)
+		return 0, false
+	}
+	if weight < 0 {
+		c.logger.Warn("invalid weight '%d' on pod '%s', using '0' instead", weight, targetRef)
+		weight = 0
+	}
+	if weight > 256 {
+		c.logger.Warn("invalid weight '%d' on pod '%s', using '256' instead", weight, targetRef)
+		weight = 256
+	}
+	return weight, true
+}
+
 func (c *converter) readAnnotations(annotations map[string]string) (annHost, annBack map[string]string) {
 	annHost = make(map[string]string, len(annotations))
 	annBack = make(map[string]string, len(annotations))
@@ -17,13 +17,17 @@ limitations under the License.
 package ingress
 
 import (
+	"crypto/x509"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/diff"
 	yaml "gopkg.in/yaml.v2"
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -34,7 +38,7 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
-	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 )
@@ -276,15 +280,19 @@ func TestSyncDrainSupport(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	svc, ep := c.createSvc1("default/echo", "http:8080:http", "172.17.1.101,172.17.1.102")
-	svcName := svc.Namespace + "/" + svc.Name
-	ss := &ep.Subsets[0]
-	addr := ss.Addresses
-	ss.Addresses = []api.EndpointAddress{addr[0]}
-	ss.NotReadyAddresses = []api.EndpointAddress{addr[1]}
-	pod1 := c.createPod1("default/echo-xxxxx", "172.17.1.103", "http:8080")
-	pod2 := c.createPod1("default/echo-yyyyy", "172.17.1.104", "none:8080")
-	c.cache.TermPodList[svcName] = []*api.Pod{pod1, pod2}
+	_, ep := c.createSvc1("default/echo", "http:8080:http", "172.17.1.101")
+	target := ep.Endpoints[0].TargetRef
+	ep.Endpoints = append(ep.Endpoints,
+		conv_helper.CreateEndpoint("172.17.1.102", target, false, false),
+		conv_helper.CreateEndpoint("172.17.1.103", target, false, true),
+	)
+	ep2 := ep.DeepCopy()
+	ep2.Name = "echo-yyyyy"
+	ep2.Endpoints = []discovery.Endpoint{
+		conv_helper.CreateEndpoint("172.17.1.103", target, false, true),
+		conv_helper.CreateEndpoint("172.17.1.104", target, true, false),
+	}
+	c.cache.EpList["default/echo"] = append(c.cache.EpList["default/echo"], ep2)
 
 	c.SyncDef(
 		map[string]string{"drain-support": "true"},
@@ -302,6 +310,8 @@ func TestSyncDrainSupport(t *testing.T) {
   endpoints:
   - ip: 172.17.1.101
     port: 8080
+  - ip: 172.17.1.104
+    port: 8080
   - ip: 172.17.1.102
     port: 8080
     drain: true
@@ -313,8 +323,209 @@ func TestSyncDrainSupport(t *testing.T) {
   - ip: 172.17.0.99
     port: 8080
 `)
+}
+
+func TestSyncPodWeight(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	_, ep := c.createSvc1("default/echo", "http:8080:http", "172.17.1.101,172.17.1.102,172.17.1.103,172.17.1.104")
+	for i, name := range []string{"echo-1", "echo-2", "echo-3", "echo-4"} {
+		ep.Endpoints[i].TargetRef = &api.ObjectReference{Kind: "Pod", Namespace: "default", Name: name}
+	}
+	buildPod := func(name, weight string) *api.Pod {
+		pod := c.createObject(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: ` + name + `
+  namespace: default`).(*api.Pod)
+		if weight != "" {
+			pod.Annotations = map[string]string{"ingress.kubernetes.io/weight": weight}
+		}
+		return pod
+	}
+	c.cache.PodList = map[string]*api.Pod{
+		"default/echo-1": buildPod("echo-1", "200"),
+		"default/echo-2": buildPod("echo-2", ""),
+		"default/echo-3": buildPod("echo-3", "300"),
+		"default/echo-4": buildPod("echo-4", "high"),
+	}
+
+	c.Sync(c.createIng1("default/echo", "echo.example.com", "/", "echo:8080"))
+
+	var weights []int
+	for _, ep := range c.hconfig.AcquireBackend("default", "echo", "http").Endpoints {
+		weights = append(weights, ep.Weight)
+	}
+	if expected := []int{200, 100, 256, 100}; !reflect.DeepEqual(weights, expected) {
+		t.Errorf("weights differ - expected: %v - actual: %v", expected, weights)
+	}
+
+	c.logger.CompareLogging(`
+WARN invalid weight '300' on pod 'default/echo-3', using '256' instead
+WARN ignoring weight of pod 'default/echo-4': strconv.Atoi: parsing "high": invalid syntax`)
+}
+
+func TestSyncFallbackService(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo", "8080", "172.17.1.101,172.17.1.102")
+	c.createSvc1("default/static", "8080", "172.17.1.102,172.17.1.201")
+	c.createSvc1("default/other", "8080", "172.17.1.151")
+	c.createSvc1("system/static", "http:8000", "172.17.2.201")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/fallback-service": "static",
+		}),
+		c.createIng1Ann("default/echo2", "echo2.example.com", "/", "static:8080", map[string]string{
+			"ingress.kubernetes.io/fallback-service": "system/static:http",
+		}),
+		c.createIng1Ann("default/echo3", "echo3.example.com", "/", "other:8080", map[string]string{
+			"ingress.kubernetes.io/fallback-service": "notfound",
+		}),
+	)
+
+	c.compareConfigBack(`
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+  - ip: 172.17.1.102
+    port: 8080
+  fallback: default_static_8080
+- id: default_other_8080
+  endpoints:
+  - ip: 172.17.1.151
+    port: 8080
+- id: default_static_8080
+  endpoints:
+  - ip: 172.17.1.102
+    port: 8080
+  - ip: 172.17.1.201
+    port: 8080
+  fallback: system_static_8000
+- id: system_static_8000
+  endpoints:
+  - ip: 172.17.2.201
+    port: 8000
+- id: _default_backend
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`)
+
+	c.logger.CompareLogging(`
+WARN ignoring fallback service 'notfound' of backend 'default_other_8080': service not found: 'default/notfound'
+`)
+}
+
+func TestSyncFallbackServiceGlobal(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo", "8080", "172.17.1.101")
+	c.createSvc1("system/static", "8080", "172.17.2.201")
+	c.SyncDef(
+		map[string]string{"fallback-service": "static"},
+		c.createIng1("default/echo", "echo.example.com", "/", "echo:8080"),
+	)
+
+	c.compareConfigBack(`
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+- id: _default_backend
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`)
+
+	c.logger.CompareLogging(`
+WARN ignoring global fallback service 'static': a namespace is required on global config
+`)
+}
+
+func TestSyncFallbackServiceGlobalNamespace(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo", "8080", "172.17.1.101")
+	c.createSvc1("default/static", "8080", "172.17.1.201")
+	c.createSvc1("system/static", "8080", "172.17.2.201")
+	c.SyncDef(
+		map[string]string{"fallback-service": "system/static"},
+		c.createIng1("default/echo1", "echo1.example.com", "/", "echo:8080"),
+		c.createIng1Ann("default/echo2", "echo2.example.com", "/", "static:8080", map[string]string{
+			"ingress.kubernetes.io/fallback-service": "echo",
+		}),
+		c.createIng1Ann("default/echo3", "echo3.example.com", "/", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/fallback-service": "default/echo",
+		}),
+		c.createIng1("system/static", "static.example.com", "/", "static:8080"),
+	)
+
+	c.compareConfigBack(`
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+- id: default_static_8080
+  endpoints:
+  - ip: 172.17.1.201
+    port: 8080
+  fallback: default_echo_8080
+- id: system_static_8080
+  endpoints:
+  - ip: 172.17.2.201
+    port: 8080
+- id: _default_backend
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+  fallback: system_static_8080
+`)
 
-	c.logger.CompareLogging("WARN skipping endpoint 172.17.1.104 of service default/echo: port 'http' was not found")
+	c.logger.CompareLogging(`
+WARN ignoring fallback service 'default/echo' of backend 'default_echo_8080': fallback and backend are the same
+`)
+}
+
+func TestSyncTCPServicesFallback(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1Ann("default/db", "5432", "172.17.1.101", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port":  "5432",
+		"ingress.kubernetes.io/fallback-service": "db-replica",
+	})
+	c.createSvc1("default/db-replica", "5432", "172.17.1.201")
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: default_db
+  port: 5432
+  endpoints:
+  - ip: 172.17.1.101
+    port: 5432
+  fallback: default_db-replica_5432
+`)
+
+	c.compareConfigBack(`
+- id: default_db-replica_5432
+  endpoints:
+  - ip: 172.17.1.201
+    port: 5432
+  modetcp: true
+- id: _default_backend
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`)
+
+	c.logger.CompareLogging(``)
 }
 
 func TestSyncRootPathLast(t *testing.T) {
@@ -478,7 +689,7 @@ func TestSyncRedeclareTLS(t *testing.T) {
 	c.createSvc1Auto()
 	c.createSecretTLS1("default/tls-echo1")
 	c.createSecretTLS1("default/tls-echo2")
-	c.Sync(c.createIngTLS1("default/echo1", "echo.example.com", "/", "echo:8080", "tls-echo1:echo.example.com;tls-echo2:echo.example.com"))
+	c.Sync(c.createIngTLS1("default/echo1", "echo.example.com", "/", "echo:8080", "tls-echo1:echo.example.com;tls-echo2:echo.example.com;tls-echo1:echo.example.com"))
 
 	c.compareConfigFront(`
 - hostname: echo.example.com
@@ -486,10 +697,9 @@ func TestSyncRedeclareTLS(t *testing.T) {
   - path: /
     backend: default_echo_8080
   tls:
-    tlsfilename: /tls/default/tls-echo1.pem`)
-
-	c.logger.CompareLogging(`
-WARN skipping TLS secret 'tls-echo2' of ingress 'default/echo1': TLS of host 'echo.example.com' was already assigned`)
+    tlsfilename: /tls/default/tls-echo1.pem
+    tlsextrafilenames:
+    - /tls/default/tls-echo2.pem`)
 }
 
 func TestSyncRedeclareSameTLS(t *testing.T) {
@@ -584,6 +794,121 @@ func TestSyncInvalidTLS(t *testing.T) {
 WARN using default certificate due to an error reading secret 'tls-invalid' on ingress 'default/echo': secret not found: 'default/tls-invalid'`)
 }
 
+func TestSyncTLSCertSigner(t *testing.T) {
+	testCases := []struct {
+		secret   string
+		signer   string
+		expected []string
+		logging  string
+	}{
+		// 0
+		{
+			secret:   "tls-echo",
+			expected: []string{},
+		},
+		// 1
+		{
+			secret:   "tls-echo",
+			signer:   "acme",
+			expected: []string{"default/tls-echo,echo.example.com"},
+		},
+		// 2
+		{
+			secret:   "tls-echo",
+			signer:   "other",
+			expected: []string{},
+			logging:  `WARN ignoring unsupported cert signer 'other' on ingress 'default/echo'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1Auto()
+		c.createSecretTLS1("default/tls-echo")
+		ing := c.createIngTLS1("default/echo", "echo.example.com", "/", "echo:8080", test.secret)
+		if test.signer != "" {
+			ing.SetAnnotations(map[string]string{
+				"ingress.kubernetes.io/cert-signer": test.signer,
+			})
+		}
+		c.Sync(ing)
+		storages := c.hconfig.AcmeData().Storages().BuildAcmeStorages()
+		if !reflect.DeepEqual(storages, test.expected) {
+			t.Errorf("acme storages differ on %d: expected %v but was %v", i, test.expected, storages)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSyncTLSMetrics(t *testing.T) {
+	notAfter := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		secret     string
+		defaultCrt string
+		metrics    []string
+		fallback   map[string]int
+		logging    string
+	}{
+		// 0
+		{
+			secret:   "tls-echo",
+			metrics:  []string{"default/echo echo.example.com secret=default/tls-echo notAfter=1577836800"},
+			fallback: map[string]int{},
+		},
+		// 1
+		{
+			secret:   "",
+			metrics:  []string{"default/echo echo.example.com secret=system/default notAfter=1577836800"},
+			fallback: map[string]int{"default/echo echo.example.com default": 1},
+		},
+		// 2
+		{
+			secret:   "tls-invalid",
+			metrics:  []string{"default/echo echo.example.com secret=system/default notAfter=1577836800"},
+			fallback: map[string]int{"default/echo echo.example.com default": 1},
+			logging:  `WARN using default certificate due to an error reading secret 'tls-invalid' on ingress 'default/echo': secret not found: 'default/tls-invalid'`,
+		},
+		// 3
+		{
+			secret:     "",
+			defaultCrt: "system/invalid",
+			metrics:    []string{"default/echo echo.example.com secret=fake notAfter=1577836800"},
+			fallback:   map[string]int{"default/echo echo.example.com fake": 1},
+			logging:    `WARN using auto generated fake certificate due to an error reading default TLS certificate: secret not found: 'system/invalid'`,
+		},
+		// 4
+		{
+			secret:     "tls-invalid",
+			defaultCrt: "system/invalid",
+			metrics:    []string{"default/echo echo.example.com secret=fake notAfter=1577836800"},
+			fallback:   map[string]int{"default/echo echo.example.com fake": 1},
+			logging: `
+WARN using auto generated fake certificate due to an error reading default TLS certificate: secret not found: 'system/invalid'
+WARN using default certificate due to an error reading secret 'tls-invalid' on ingress 'default/echo': secret not found: 'default/tls-invalid'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1Auto()
+		c.createSecretTLS1("default/tls-echo")
+		c.cache.SecretTLSCrt["default/tls-echo"] = &x509.Certificate{NotAfter: notAfter}
+		c.cache.SecretTLSCrt["system/default"] = &x509.Certificate{NotAfter: notAfter}
+		if test.defaultCrt != "" {
+			c.defaultCrt = test.defaultCrt
+		}
+		c.fakeCrt = convtypes.File{Filename: "/tls/fake.pem", Certificate: &x509.Certificate{NotAfter: notAfter}}
+		c.Sync(c.createIngTLS1("default/echo", "echo.example.com", "/", "echo:8080", test.secret))
+		if !reflect.DeepEqual(c.metrics.CertMetrics, test.metrics) {
+			t.Errorf("cert metrics differ on %d: expected %v but was %v", i, test.metrics, c.metrics.CertMetrics)
+		}
+		if !reflect.DeepEqual(c.metrics.CertFallback, test.fallback) {
+			t.Errorf("cert fallback differ on %d: expected %v but was %v", i, test.fallback, c.metrics.CertFallback)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestSyncRootPathDefault(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1059,13 +1384,16 @@ func TestSyncAnnPassthrough(t *testing.T) {
 		Port:       8443,
 		TargetPort: intstr.FromInt(8443),
 	}
-	epPort := api.EndpointPort{
-		Name:     "https",
-		Port:     8443,
-		Protocol: api.ProtocolTCP,
+	epPortName := "https"
+	epPortNumber := int32(8443)
+	epPortProto := api.ProtocolTCP
+	epPort := discovery.EndpointPort{
+		Name:     &epPortName,
+		Port:     &epPortNumber,
+		Protocol: &epPortProto,
 	}
 	svc.Spec.Ports = append(svc.Spec.Ports, svcPort)
-	ep.Subsets[0].Ports = append(ep.Subsets[0].Ports, epPort)
+	ep.Ports = append(ep.Ports, epPort)
 	c.Sync(
 		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo:8443",
 			map[string]string{
@@ -1124,6 +1452,121 @@ WARN skipping http port config of ssl-passthrough on ingress 'default/echo2': po
 `)
 }
 
+func TestSyncTCPServices(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1Ann("default/echo1", "8080", "172.17.1.101", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port":  "7001",
+		"ingress.kubernetes.io/balance-algorithm": "leastconn",
+	})
+	c.createSvc1Ann("default/echo2", "8080", "172.17.1.102", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "7001",
+	})
+	c.createSvc1Ann("default/echo3", "http:8080", "172.17.1.103", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port":        "7003",
+		"ingress.kubernetes.io/tcp-service-target-port": "http",
+	})
+	c.createSvc1Ann("default/echo4", "8080", "172.17.1.104", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "invalid",
+	})
+	c.createSvc1Ann("default/echo5", "8080", "172.17.1.105", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port":        "7005",
+		"ingress.kubernetes.io/tcp-service-target-port": "9000",
+	})
+	c.createSvc1Ann("default/echo6", "8080", "172.17.1.106", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port":       "7006",
+		"ingress.kubernetes.io/tcp-service-crt-secret": "crt",
+	})
+	c.createSvc1("default/echo7", "8080", "172.17.1.107")
+	c.cache.SecretTLSPath["default/crt"] = "/tls/default/crt.pem"
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: default_echo1
+  port: 7001
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+  balancealgorithm: leastconn
+- name: default_echo3
+  port: 7003
+  endpoints:
+  - ip: 172.17.1.103
+    port: 8080
+- name: default_echo6
+  port: 7006
+  endpoints:
+  - ip: 172.17.1.106
+    port: 8080
+  crtfilename: /tls/default/crt.pem
+`)
+
+	c.logger.CompareLogging(`
+WARN skipping TCP service 'default/echo2': public port 7001 is already in use by 'default_echo1'
+WARN skipping TCP service 'default/echo4': invalid public port: invalid
+WARN skipping TCP service 'default/echo5': port not found: '9000'
+`)
+}
+
+func TestSyncTCPServicesSNI(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1Ann("default/db1", "5432", "172.17.1.101", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "5432",
+		"ingress.kubernetes.io/tcp-service-sni":  "DB1.example.com",
+	})
+	c.createSvc1Ann("default/db2", "5432", "172.17.1.102", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port":       "5432",
+		"ingress.kubernetes.io/tcp-service-sni":        "db2.example.com",
+		"ingress.kubernetes.io/tcp-service-crt-secret": "crt",
+	})
+	c.createSvc1Ann("default/db3", "5432", "172.17.1.103", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "5432",
+		"ingress.kubernetes.io/tcp-service-sni":  "db1.example.com",
+	})
+	c.createSvc1Ann("default/db4", "5432", "172.17.1.104", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "5432",
+	})
+	c.createSvc1Ann("default/db5", "5432", "172.17.1.105", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "5433",
+	})
+	c.createSvc1Ann("default/db6", "5432", "172.17.1.106", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "5433",
+		"ingress.kubernetes.io/tcp-service-sni":  "db6.example.com",
+	})
+	c.cache.SecretTLSPath["default/crt"] = "/tls/default/crt.pem"
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: default_db1
+  port: 5432
+  sni: db1.example.com
+  endpoints:
+  - ip: 172.17.1.101
+    port: 5432
+- name: default_db2
+  port: 5432
+  sni: db2.example.com
+  endpoints:
+  - ip: 172.17.1.102
+    port: 5432
+- name: default_db5
+  port: 5433
+  endpoints:
+  - ip: 172.17.1.105
+    port: 5432
+`)
+
+	c.logger.CompareLogging(`
+WARN ignoring crt secret of TCP service 'default/db2': services with SNI hostname use TLS passthrough
+WARN skipping TCP service 'default/db3': SNI hostname 'db1.example.com' on public port 5432 is already in use by 'default_db1'
+WARN skipping TCP service 'default/db4': public port 5432 is already in use by 'default_db1'
+WARN skipping TCP service 'default/db6': public port 5433 is already in use by 'default_db5'
+`)
+}
+
 /* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
  *
  *  BUILDERS
@@ -1131,22 +1574,27 @@ WARN skipping http port config of ssl-passthrough on ingress 'default/echo2': po
  * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
 
 type testConfig struct {
-	t       *testing.T
-	decode  func(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error)
-	hconfig haproxy.Config
-	logger  *types_helper.LoggerMock
-	cache   *conv_helper.CacheMock
-	updater *updaterMock
+	t          *testing.T
+	decode     func(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error)
+	hconfig    haproxy.Config
+	logger     *types_helper.LoggerMock
+	cache      *conv_helper.CacheMock
+	metrics    *conv_helper.MetricsMock
+	updater    *updaterMock
+	defaultCrt string
+	fakeCrt    convtypes.File
 }
 
 func setup(t *testing.T) *testConfig {
 	logger := types_helper.NewLoggerMock(t)
 	c := &testConfig{
-		t:       t,
-		decode:  scheme.Codecs.UniversalDeserializer().Decode,
-		hconfig: haproxy.CreateInstance(logger, &ha_helper.BindUtilsMock{}, haproxy.InstanceOptions{}).Config(),
-		cache:   conv_helper.NewCacheMock(),
-		logger:  logger,
+		t:          t,
+		decode:     scheme.Codecs.UniversalDeserializer().Decode,
+		hconfig:    haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config(),
+		cache:      conv_helper.NewCacheMock(),
+		metrics:    conv_helper.NewMetricsMock(),
+		logger:     logger,
+		defaultCrt: "system/default",
 	}
 	c.createSvc1("system/default", "8080", "172.17.0.99")
 	return c
@@ -1176,10 +1624,12 @@ func (c *testConfig) SyncDef(config map[string]string, ing ...*networking.Ingres
 	conv := NewIngressConverter(
 		&ingtypes.ConverterOptions{
 			Cache:            c.cache,
+			Metrics:          c.metrics,
 			Logger:           c.logger,
 			DefaultConfig:    defaultConfig,
 			DefaultBackend:   "system/default",
-			DefaultCrtSecret: "system/default",
+			DefaultCrtSecret: c.defaultCrt,
+			FakeCrtFile:      c.fakeCrt,
 			AnnotationPrefix: "ingress.kubernetes.io",
 		},
 		c.hconfig,
@@ -1189,23 +1639,23 @@ func (c *testConfig) SyncDef(config map[string]string, ing ...*networking.Ingres
 	conv.Sync(ing)
 }
 
-func (c *testConfig) createSvc1Auto() (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1Auto() (*api.Service, *discovery.EndpointSlice) {
 	return c.createSvc1("default/echo", "8080", "172.17.0.11")
 }
 
-func (c *testConfig) createSvc1AutoAnn(ann map[string]string) (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1AutoAnn(ann map[string]string) (*api.Service, *discovery.EndpointSlice) {
 	svc, ep := c.createSvc1Auto()
 	svc.SetAnnotations(ann)
 	return svc, ep
 }
 
-func (c *testConfig) createSvc1Ann(name, port, endpoints string, ann map[string]string) (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1Ann(name, port, endpoints string, ann map[string]string) (*api.Service, *discovery.EndpointSlice) {
 	svc, ep := c.createSvc1(name, port, endpoints)
 	svc.SetAnnotations(ann)
 	return svc, ep
 }
 
-func (c *testConfig) createSvc1(name, port, endpoints string) (*api.Service, *api.Endpoints) {
+func (c *testConfig) createSvc1(name, port, endpoints string) (*api.Service, *discovery.EndpointSlice) {
     	svc, ep := conv_helper.CreateService(name, port, endpoints)
 	// TODO change SvcList to map
 	var has bool
@@ -1219,31 +1669,10 @@ func (c *testConfig) createSvc1(name, port, endpoints string) (*api.Service, *ap
 	if !has {
 		c.cache.SvcList = append(c.cache.SvcList, svc)
 	}
-	c.cache.EpList[name] = ep
+	c.cache.EpList[name] = []*discovery.EndpointSlice{ep}
 	return svc, ep
 }	
 
-func (c *testConfig) createPod1(name, ip, port string) *api.Pod {
-	pname := strings.Split(name, "/")
-	pport := strings.Split(port, ":")
-
-	pod := c.createObject(`
-apiVersion: v1
-kind: Pod
-metadata:
-  name: ` + pname[1] + `
-  namespace: ` + pname[0] + `
-spec:
-  containers:
-  - ports:
-    - name: ` + pport[0] + `
-      containerPort: ` + pport[1] + `
-status:
-  podIP: ` + ip).(*api.Pod)
-
-	return pod
-}
-
 func (c *testConfig) createSecretTLS1(secretName string) {
 	c.cache.SecretTLSPath[secretName] = "/tls/" + secretName + ".pem"
 }
@@ -1385,6 +1814,10 @@ func (u *updaterMock) UpdateBackendConfig(backend *hatypes.Backend, mapper *anno
 	backend.BalanceAlgorithm = mapper.Get(ingtypes.BackBalanceAlgorithm).Value
 }
 
+func (u *updaterMock) UpdateTCPBackendConfig(backend *hatypes.TCPBackend, mapper *annotations.Mapper) {
+	backend.BalanceAlgorithm = mapper.Get(ingtypes.BackBalanceAlgorithm).Value
+}
+
 type (
 	pathMock struct {
 		Path      string
@@ -1394,7 +1827,8 @@ type (
 		Client string `yaml:",omitempty"`
 	}
 	tlsMock struct {
-		TLSFilename string `yaml:",omitempty"`
+		TLSFilename       string   `yaml:",omitempty"`
+		TLSExtraFilenames []string `yaml:",omitempty"`
 	}
 	hostMock struct {
 		Hostname     string
@@ -1417,12 +1851,20 @@ func convertHost(hafronts ...*hatypes.Host) []hostMock {
 			Paths:        paths,
 			RootRedirect: f.RootRedirect,
 			Timeout:      timeoutMock{Client: f.Timeout.Client},
-			TLS:          tlsMock{TLSFilename: f.TLS.TLSFilename},
+			TLS:          convertTLS(f.TLS),
 		})
 	}
 	return hosts
 }
 
+func convertTLS(tls hatypes.HostTLSConfig) tlsMock {
+	mock := tlsMock{TLSFilename: tls.TLSFilename}
+	for _, crt := range tls.TLSExtraCerts {
+		mock.TLSExtraFilenames = append(mock.TLSExtraFilenames, crt.Filename)
+	}
+	return mock
+}
+
 func (c *testConfig) compareConfigFront(expected string) {
 	c.compareText(_yamlMarshal(convertHost(c.hconfig.Hosts()...)), expected)
 }
@@ -1438,15 +1880,18 @@ func (c *testConfig) compareConfigDefaultFront(expected string) {
 
 type (
 	endpointMock struct {
-		IP    string
-		Port  int
-		Drain bool `yaml:",omitempty"`
+		IP     string
+		Port   int
+		Drain  bool `yaml:",omitempty"`
+		Backup bool `yaml:",omitempty"`
 	}
 	backendMock struct {
 		ID               string
 		Endpoints        []endpointMock `yaml:",omitempty"`
 		BalanceAlgorithm string         `yaml:",omitempty"`
 		MaxConnServer    int            `yaml:",omitempty"`
+		Fallback         string         `yaml:",omitempty"`
+		ModeTCP          bool           `yaml:",omitempty"`
 	}
 )
 
@@ -1455,13 +1900,15 @@ func convertBackend(habackends ...*hatypes.Backend) []backendMock {
 	for _, b := range habackends {
 		endpoints := []endpointMock{}
 		for _, e := range b.Endpoints {
-			endpoints = append(endpoints, endpointMock{IP: e.IP, Port: e.Port, Drain: e.Weight == 0})
+			endpoints = append(endpoints, endpointMock{IP: e.IP, Port: e.Port, Drain: e.Weight == 0, Backup: e.Backup})
 		}
 		backends = append(backends, backendMock{
 			ID:               b.ID,
 			Endpoints:        endpoints,
 			BalanceAlgorithm: b.BalanceAlgorithm,
 			MaxConnServer:    b.Server.MaxConn,
+			Fallback:         b.Fallback,
+			ModeTCP:          b.ModeTCP,
 		})
 	}
 	return backends
@@ -1470,3 +1917,37 @@ func convertBackend(habackends ...*hatypes.Backend) []backendMock {
 func (c *testConfig) compareConfigBack(expected string) {
 	c.compareText(_yamlMarshal(convertBackend(c.hconfig.Backends()...)), expected)
 }
+
+type tcpBackendMock struct {
+	Name             string
+	Port             int
+	SNI              string         `yaml:",omitempty"`
+	Endpoints        []endpointMock `yaml:",omitempty"`
+	BalanceAlgorithm string         `yaml:",omitempty"`
+	CrtFilename      string         `yaml:",omitempty"`
+	Fallback         string         `yaml:",omitempty"`
+}
+
+func convertTCPBackend(habackends ...*hatypes.TCPBackend) []tcpBackendMock {
+	backends := []tcpBackendMock{}
+	for _, b := range habackends {
+		endpoints := []endpointMock{}
+		for _, e := range b.Endpoints {
+			endpoints = append(endpoints, endpointMock{IP: e.IP, Port: e.Port, Backup: e.Backup})
+		}
+		backends = append(backends, tcpBackendMock{
+			Name:             b.Name,
+			Port:             b.Port,
+			SNI:              b.SNI,
+			Endpoints:        endpoints,
+			BalanceAlgorithm: b.BalanceAlgorithm,
+			CrtFilename:      b.SSL.Filename,
+			Fallback:         b.Fallback,
+		})
+	}
+	return backends
+}
+
+func (c *testConfig) compareConfigTCP(expected string) {
+	c.compareText(_yamlMarshal(convertTCPBackend(c.hconfig.TCPBackends()...)), expected)
+}
@@ -18,34 +18,54 @@ package types
 
 // Host Annotations
 const (
+	HostAccessLog              = "access-log"
 	HostAppRoot                = "app-root"
+	HostAuthTLSCRLSecret       = "auth-tls-crl-secret"
 	HostAuthTLSErrorPage       = "auth-tls-error-page"
 	HostAuthTLSVerifyClient    = "auth-tls-verify-client"
 	HostAuthTLSSecret          = "auth-tls-secret"
 	HostAuthTLSStrict          = "auth-tls-strict"
+	HostCertSigner             = "cert-signer"
+	HostHTTPLogFormat          = "http-log-format"
+	HostRequestID              = "request-id"
 	HostServerAlias            = "server-alias"
 	HostServerAliasRegex       = "server-alias-regex"
 	HostSSLPassthrough         = "ssl-passthrough"
+	HostSSLCipherSuites        = "ssl-cipher-suites"
+	HostSSLCiphers             = "ssl-ciphers"
+	HostSSLMaxVersion          = "ssl-max-version"
+	HostSSLMinVersion          = "ssl-min-version"
 	HostSSLPassthroughHTTPPort = "ssl-passthrough-http-port"
 	HostTimeoutClient          = "timeout-client"
 	HostTimeoutClientFin       = "timeout-client-fin"
+	HostTLSALPN                = "tls-alpn"
 	HostVarNamespace           = "var-namespace"
 )
 
 var (
 	// AnnHost ...
 	AnnHost = map[string]struct{}{
+		HostAccessLog:              {},
 		HostAppRoot:                {},
+		HostAuthTLSCRLSecret:       {},
 		HostAuthTLSErrorPage:       {},
 		HostAuthTLSVerifyClient:    {},
 		HostAuthTLSSecret:          {},
 		HostAuthTLSStrict:          {},
+		HostCertSigner:             {},
+		HostHTTPLogFormat:          {},
+		HostRequestID:              {},
 		HostServerAlias:            {},
 		HostServerAliasRegex:       {},
 		HostSSLPassthrough:         {},
+		HostSSLCipherSuites:        {},
+		HostSSLCiphers:             {},
+		HostSSLMaxVersion:          {},
+		HostSSLMinVersion:          {},
 		HostSSLPassthroughHTTPPort: {},
 		HostTimeoutClient:          {},
 		HostTimeoutClientFin:       {},
+		HostTLSALPN:                {},
 		HostVarNamespace:           {},
 	}
 )
@@ -60,6 +80,9 @@ const (
 	BackAuthRealm              = "auth-realm"
 	BackAuthSecret             = "auth-secret"
 	BackAuthTLSCertHeader      = "auth-tls-cert-header"
+	BackAuthTLSCertRequired    = "auth-tls-cert-required"
+	BackAuthTLSMatchCN         = "auth-tls-match-cn"
+	BackAuthTLSMatchOU         = "auth-tls-match-ou"
 	BackAuthType               = "auth-type"
 	BackBackendCheckInterval   = "backend-check-interval"
 	BackBackendProtocol        = "backend-protocol"
@@ -69,8 +92,12 @@ const (
 	BackBlueGreenBalance       = "blue-green-balance"
 	BackBlueGreenCookie        = "blue-green-cookie"
 	BackBlueGreenDeploy        = "blue-green-deploy"
+	BackBlueGreenErrorMetric   = "blue-green-schedule-error-metric"
+	BackBlueGreenErrorRate     = "blue-green-schedule-error-rate"
 	BackBlueGreenHeader        = "blue-green-header"
+	BackBlueGreenInterval      = "blue-green-schedule-interval"
 	BackBlueGreenMode          = "blue-green-mode"
+	BackBlueGreenSchedule      = "blue-green-schedule"
 	BackConfigBackend          = "config-backend"
 	BackCorsAllowCredentials   = "cors-allow-credentials"
 	BackCorsAllowHeaders       = "cors-allow-headers"
@@ -79,12 +106,25 @@ const (
 	BackCorsEnable             = "cors-enable"
 	BackCorsExposeHeaders      = "cors-expose-headers"
 	BackCorsMaxAge             = "cors-max-age"
+	BackDrainTimeout           = "drain-timeout"
 	BackDynamicScaling         = "dynamic-scaling"
+	BackFallbackService        = "fallback-service"
 	BackHealthCheckAddr        = "health-check-addr"
+	BackHealthCheckDownInter   = "health-check-down-interval"
+	BackHealthCheckErrorLimit  = "health-check-error-limit"
+	BackHealthCheckExpBody     = "health-check-expect-body"
+	BackHealthCheckExpStatus   = "health-check-expect-status"
 	BackHealthCheckFallCount   = "health-check-fall-count"
+	BackHealthCheckFromProbe   = "health-check-from-probe"
+	BackHealthCheckHost        = "health-check-host"
 	BackHealthCheckInterval    = "health-check-interval"
+	BackHealthCheckMethod      = "health-check-method"
+	BackHealthCheckObserve     = "health-check-observe"
+	BackHealthCheckOnError     = "health-check-on-error"
 	BackHealthCheckPort        = "health-check-port"
 	BackHealthCheckRiseCount   = "health-check-rise-count"
+	BackHealthCheckSNI         = "health-check-sni"
+	BackHealthCheckSSL         = "health-check-ssl"
 	BackHealthCheckURI         = "health-check-uri"
 	BackHSTS                   = "hsts"
 	BackHSTSIncludeSubdomains  = "hsts-include-subdomains"
@@ -101,8 +141,14 @@ const (
 	BackOAuthURIPrefix         = "oauth-uri-prefix"
 	BackProxyBodySize          = "proxy-body-size"
 	BackProxyProtocol          = "proxy-protocol"
+	BackRedispatchInterval     = "redispatch-interval"
+	BackRetries                = "retries"
+	BackRetryNonIdempotent     = "retry-non-idempotent"
+	BackRetryOn                = "retry-on"
 	BackRewriteTarget          = "rewrite-target"
+	BackSlotsFromHPA           = "slots-from-hpa"
 	BackSlotsMinFree           = "slots-min-free"
+	BackSlowStart              = "slowstart"
 	BackSecureBackends         = "secure-backends"
 	BackSecureCrtSecret        = "secure-crt-secret"
 	BackSecureVerifyCASecret   = "secure-verify-ca-secret"
@@ -125,4 +171,21 @@ const (
 	BackUseResolver            = "use-resolver"
 	BackWAF                    = "waf"
 	BackWhitelistSourceRange   = "whitelist-source-range"
+	BackZoneAwareMinEndpoints  = "zone-aware-min-endpoints"
+	BackZoneAwareRouting       = "zone-aware-routing"
+)
+
+// Pod Annotations
+const (
+	PodWeight = "weight"
+)
+
+// TCP Service Annotations
+const (
+	TCPServiceCrtSecret     = "tcp-service-crt-secret"
+	TCPServicePort          = "tcp-service-port"
+	TCPServiceProxyProtocol = "tcp-service-proxy-protocol"
+	TCPServiceSecureSNI     = "tcp-service-secure-sni"
+	TCPServiceSNI           = "tcp-service-sni"
+	TCPServiceTargetPort    = "tcp-service-target-port"
 )
@@ -18,6 +18,11 @@ package types
 
 // Global config
 const (
+	GlobalAcmeEmails                   = "acme-emails"
+	GlobalAcmeEndpoint                 = "acme-endpoint"
+	GlobalAcmeExpiring                 = "acme-expiring"
+	GlobalAcmeShared                   = "acme-shared"
+	GlobalAcmeTermsAgreed              = "acme-terms-agreed"
 	GlobalBindFrontingProxy            = "bind-fronting-proxy"
 	GlobalBindHTTP                     = "bind-http"
 	GlobalBindHTTPS                    = "bind-https"
@@ -40,7 +45,6 @@ const (
 	GlobalForwardfor                   = "forwardfor"
 	GlobalFrontingProxyPort            = "fronting-proxy-port"
 	GlobalHealthzPort                  = "healthz-port"
-	GlobalHTTPLogFormat                = "http-log-format"
 	GlobalHTTPPort                     = "http-port"
 	GlobalHTTPSLogFormat               = "https-log-format"
 	GlobalHTTPSPort                    = "https-port"
@@ -55,11 +59,16 @@ const (
 	GlobalNbprocSSL                    = "nbproc-ssl"
 	GlobalNbthread                     = "nbthread"
 	GlobalNoTLSRedirectLocations       = "no-tls-redirect-locations"
+	GlobalRequestIDFormat              = "request-id-format"
+	GlobalRequestIDTrustedNetworks     = "request-id-trusted-networks"
+	GlobalSSLCipherSuites              = "ssl-cipher-suites"
 	GlobalSSLCiphers                   = "ssl-ciphers"
 	GlobalSSLDHDefaultMaxSize          = "ssl-dh-default-max-size"
 	GlobalSSLDHParam                   = "ssl-dh-param"
 	GlobalSSLEngine                    = "ssl-engine"
 	GlobalSSLHeadersPrefix             = "ssl-headers-prefix"
+	GlobalSSLMaxVersion                = "ssl-max-version"
+	GlobalSSLMinVersion                = "ssl-min-version"
 	GlobalSSLModeAsync                 = "ssl-mode-async"
 	GlobalSSLOptions                   = "ssl-options"
 	GlobalStatsAuth                    = "stats-auth"
@@ -25,10 +25,14 @@ import (
 type ConverterOptions struct {
 	Logger           types.Logger
 	Cache            convtypes.Cache
+	Metrics          convtypes.Metrics
+	Rollouts         convtypes.Rollouts
 	DefaultConfig    func() map[string]string
 	DefaultBackend   string
 	DefaultCrtSecret string
 	FakeCrtFile      convtypes.File
 	FakeCAFile       convtypes.File
 	AnnotationPrefix string
+	// AcmeSocket is the socket of the acme server, empty if not running
+	AcmeSocket string
 }
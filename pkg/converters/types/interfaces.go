@@ -17,23 +17,67 @@ limitations under the License.
 package types
 
 import (
+	"crypto/x509"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v1"
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 )
 
 // Cache ...
 type Cache interface {
 	GetService(serviceName string) (*api.Service, error)
-	GetEndpoints(service *api.Service) (*api.Endpoints, error)
-	GetTerminatingPods(service *api.Service) ([]*api.Pod, error)
+	GetServiceList() ([]*api.Service, error)
+	GetEndpointSlices(service *api.Service) ([]*discovery.EndpointSlice, error)
 	GetPod(podName string) (*api.Pod, error)
+	GetControllerPod() (*api.Pod, error)
+	GetNode(nodeName string) (*api.Node, error)
+	GetHPAList(namespace string) ([]*autoscaling.HorizontalPodAutoscaler, error)
+	GetReplicaSet(namespace, name string) (*appsv1.ReplicaSet, error)
 	GetTLSSecretPath(defaultNamespace, secretName string) (File, error)
 	GetCASecretPath(defaultNamespace, secretName string) (File, error)
+	GetCRLSecretPath(defaultNamespace, secretName string) (File, error)
 	GetDHSecretPath(defaultNamespace, secretName string) (File, error)
 	GetSecretContent(defaultNamespace, secretName, keyName string) ([]byte, error)
 }
 
+// Metrics ...
+type Metrics interface {
+	ClearCertMetrics()
+	SetCertMetrics(namespace, ingress, hostname, secret string, crt *x509.Certificate)
+	SetCertFallback(namespace, ingress, hostname, fallback string)
+}
+
+// Rollouts keeps the state of the blue/green schedules between syncs,
+// reads the counters of their servers and starts the syncs which
+// advance the schedules.
+type Rollouts interface {
+	Now() time.Time
+	GetRollout(backend string) *RolloutState
+	SetRollout(backend string, state *RolloutState)
+	ReadCounters(backend string, pods []string, fields ...string) ([]int64, error)
+	ScheduleSync(at time.Time)
+}
+
+// RolloutState ...
+type RolloutState struct {
+	// Group identifies the pods of the new deployment, a distinct
+	// group restarts the schedule
+	Group     string
+	Step      int
+	StepStart time.Time
+	Paused    bool
+	// Counters has the errors and sessions of the group when the
+	// current step or error check started
+	Counters []int64
+}
+
 // File ...
 type File struct {
 	Filename string
 	SHA1Hash string
+	// Certificate is the parsed x509 certificate of TLS files, nil otherwise
+	Certificate *x509.Certificate
 }
@@ -22,6 +22,7 @@ import (
 	"strconv"
 
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 )
@@ -46,64 +47,140 @@ func FindServicePort(svc *api.Service, servicePort string) *api.ServicePort {
 	return nil
 }
 
-// FindContainerPort Find the container's port number of a known servicePort
-// Search criteria:
-// 1. svcPort.TargetPort is a number: this is the right container's port
-// 2. svcPort.TargetPort is a named port (not a number): find a container's port with that name and use its ContainerPort
-// If targetPort is neither a valid port number nor a declared named port, return zero which means that the port was not found
-func FindContainerPort(pod *api.Pod, svcPort *api.ServicePort) int {
-	if targetPort := svcPort.TargetPort.IntValue(); targetPort > 0 {
-		return targetPort
-	}
-	portName := svcPort.TargetPort.String()
-	for _, c := range pod.Spec.Containers {
-		for _, port := range c.Ports {
-			if port.Protocol == svcPort.Protocol && port.Name == portName {
-				return int(port.ContainerPort)
-			}
-		}
-	}
-	return 0
-}
-
 // Endpoint ...
 type Endpoint struct {
 	IP        string
 	Port      int
 	TargetRef string
+	Zones     []string
 }
 
-// CreateEndpoints ...
+// CreateEndpoints reads all the endpoint slices of a service and returns the
+// endpoints of svcPort. Ready endpoints are not terminating and declare the
+// ready condition, or the serving one if ready is missing. All the others are
+// returned as notReady, except terminating endpoints that are not serving
+// anymore. Endpoints duplicated among slices are returned only once.
 func CreateEndpoints(cache types.Cache, svc *api.Service, svcPort *api.ServicePort) (ready, notReady []*Endpoint, err error) {
 	if svc.Spec.Type == api.ServiceTypeExternalName {
 		ready, err := createEndpointsExternalName(svc, svcPort)
 		return ready, nil, err
 	}
-	endpoints, err := cache.GetEndpoints(svc)
+	slices, err := cache.GetEndpointSlices(svc)
 	if err != nil {
 		return nil, nil, err
 	}
-	for _, subset := range endpoints.Subsets {
-		for _, epPort := range subset.Ports {
-			if matchPort(svcPort, &epPort) {
-				port := int(epPort.Port)
-				for _, addr := range subset.Addresses {
-					ready = append(ready, newEndpointAddr(&addr, port))
+	added := map[string]bool{}
+	var notReadyAll []*Endpoint
+	for _, slice := range slices {
+		if slice.AddressType != discovery.AddressTypeIPv4 && slice.AddressType != discovery.AddressTypeIPv6 {
+			continue
+		}
+		for _, epPort := range slice.Ports {
+			if !matchPort(svcPort, &epPort) || epPort.Port == nil {
+				continue
+			}
+			port := int(*epPort.Port)
+			for i := range slice.Endpoints {
+				ep := &slice.Endpoints[i]
+				if len(ep.Addresses) == 0 {
+					continue
 				}
-				for _, addr := range subset.NotReadyAddresses {
-					notReady = append(notReady, newEndpointAddr(&addr, port))
+				endpoint := newEndpointSlice(ep, port)
+				endpoint.Zones = endpointZones(cache, ep)
+				if isReady(&ep.Conditions) {
+					if !added[endpoint.target()] {
+						added[endpoint.target()] = true
+						ready = append(ready, endpoint)
+					}
+				} else if isServing(&ep.Conditions) {
+					notReadyAll = append(notReadyAll, endpoint)
 				}
 			}
 		}
 	}
+	// an endpoint moving between slices can be found as ready
+	// in one of them and not ready or terminating in another one
+	for _, endpoint := range notReadyAll {
+		if !added[endpoint.target()] {
+			added[endpoint.target()] = true
+			notReady = append(notReady, endpoint)
+		}
+	}
 	return ready, notReady, nil
 }
 
-func matchPort(svcPort *api.ServicePort, epPort *api.EndpointPort) bool {
-	if epPort.Protocol != api.ProtocolTCP {
+// endpointZones returns the zones an endpoint should be consumed from: its
+// topology hints if declared, otherwise the zone where the endpoint is, read
+// from the slice or from the node of the target pod.
+func endpointZones(cache types.Cache, ep *discovery.Endpoint) []string {
+	if ep.Hints != nil && len(ep.Hints.ForZones) > 0 {
+		zones := make([]string, len(ep.Hints.ForZones))
+		for i, zone := range ep.Hints.ForZones {
+			zones[i] = zone.Name
+		}
+		return zones
+	}
+	if ep.Zone != nil && *ep.Zone != "" {
+		return []string{*ep.Zone}
+	}
+	var nodeName string
+	if ep.NodeName != nil {
+		nodeName = *ep.NodeName
+	} else if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+		if pod, err := cache.GetPod(targetRefToString(ep.TargetRef)); err == nil {
+			nodeName = pod.Spec.NodeName
+		}
+	}
+	if nodeName == "" {
+		return nil
+	}
+	if node, err := cache.GetNode(nodeName); err == nil {
+		if zone := NodeZone(node); zone != "" {
+			return []string{zone}
+		}
+	}
+	return nil
+}
+
+// NodeZone returns the zone of a node, read from its topology labels.
+func NodeZone(node *api.Node) string {
+	if zone := node.Labels[api.LabelTopologyZone]; zone != "" {
+		return zone
+	}
+	return node.Labels[api.LabelFailureDomainBetaZone]
+}
+
+func isReady(conditions *discovery.EndpointConditions) bool {
+	if conditions.Terminating != nil && *conditions.Terminating {
 		return false
 	}
-	return svcPort.Name == "" || svcPort.Name == epPort.Name
+	if conditions.Ready != nil {
+		return *conditions.Ready
+	}
+	// nil means unknown state and should be interpreted as ready
+	return conditions.Serving == nil || *conditions.Serving
+}
+
+// isServing reports if a not ready endpoint can still receive the requests
+// of persistent sessions. Terminating endpoints are serving while they don't
+// start to refuse connections, the ones failing the readiness probe are
+// always considered serving.
+func isServing(conditions *discovery.EndpointConditions) bool {
+	if conditions.Terminating != nil && *conditions.Terminating {
+		return conditions.Serving == nil || *conditions.Serving
+	}
+	return true
+}
+
+func matchPort(svcPort *api.ServicePort, epPort *discovery.EndpointPort) bool {
+	if epPort.Protocol != nil && *epPort.Protocol != api.ProtocolTCP {
+		return false
+	}
+	epPortName := ""
+	if epPort.Name != nil {
+		epPortName = *epPort.Name
+	}
+	return svcPort.Name == "" || svcPort.Name == epPortName
 }
 
 // CreateSvcEndpoint ...
@@ -134,11 +211,11 @@ func createEndpointsExternalName(svc *api.Service, svcPort *api.ServicePort) (en
 	return endpoints, nil
 }
 
-func newEndpointAddr(addr *api.EndpointAddress, port int) *Endpoint {
+func newEndpointSlice(ep *discovery.Endpoint, port int) *Endpoint {
 	return &Endpoint{
-		IP:        addr.IP,
+		IP:        ep.Addresses[0],
 		Port:      port,
-		TargetRef: targetRefToString(addr.TargetRef),
+		TargetRef: targetRefToString(ep.TargetRef),
 	}
 }
 
@@ -156,6 +233,10 @@ func newEndpointIP(ip string, port int) *Endpoint {
 	}
 }
 
+func (e *Endpoint) target() string {
+	return net.JoinHostPort(e.IP, strconv.Itoa(e.Port))
+}
+
 func (e *Endpoint) String() string {
 	return fmt.Sprintf("%+v", *e)
 }
@@ -23,6 +23,8 @@ import (
 	"testing"
 
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 )
@@ -103,17 +105,12 @@ func TestCreateEndpoints(t *testing.T) {
 	for _, test := range testCases {
 		c := setup(t)
 		svc, ep := helper_test.CreateService("default/echo", test.declarePort, test.endpoints)
-		for _, ss := range ep.Subsets {
-			for i := range ss.Addresses {
-				ss.Addresses[i].TargetRef = nil
-			}
-			for i := range ss.NotReadyAddresses {
-				ss.NotReadyAddresses[i].TargetRef = nil
-			}
+		for i := range ep.Endpoints {
+			ep.Endpoints[i].TargetRef = nil
 		}
 		cache := &helper_test.CacheMock{
 			SvcList: []*api.Service{svc},
-			EpList:  map[string]*api.Endpoints{"default/echo": ep},
+			EpList:  map[string][]*discovery.EndpointSlice{"default/echo": {ep}},
 		}
 		port := FindServicePort(svc, test.findPort)
 		var endpoints []*Endpoint
@@ -127,6 +124,111 @@ func TestCreateEndpoints(t *testing.T) {
 	}
 }
 
+func TestCreateEndpointsConditions(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	svc, ep1 := helper_test.CreateService("default/echo", "8080", "172.17.0.11")
+	ep1.Endpoints[0].TargetRef = nil
+	ep1.Endpoints = append(ep1.Endpoints,
+		helper_test.CreateEndpoint("172.17.0.12", nil, false, false),
+		helper_test.CreateEndpoint("172.17.0.13", nil, false, true),
+		discovery.Endpoint{Addresses: []string{"172.17.0.14"}},
+	)
+	ep2 := ep1.DeepCopy()
+	ep2.Name = "echo-yyyyy"
+	notServing := false
+	terminating := true
+	ep2.Endpoints = []discovery.Endpoint{
+		helper_test.CreateEndpoint("172.17.0.12", nil, true, false),
+		helper_test.CreateEndpoint("172.17.0.13", nil, false, true),
+		helper_test.CreateEndpoint("172.17.0.15", nil, true, true),
+		// terminating and not serving anymore
+		{
+			Addresses:  []string{"172.17.0.16"},
+			Conditions: discovery.EndpointConditions{Serving: &notServing, Terminating: &terminating},
+		},
+		// ready condition missing
+		{
+			Addresses:  []string{"172.17.0.17"},
+			Conditions: discovery.EndpointConditions{Serving: &notServing},
+		},
+	}
+	ep3 := ep1.DeepCopy()
+	ep3.Name = "echo-zzzzz"
+	ep3.AddressType = discovery.AddressTypeFQDN
+	ep3.Endpoints = []discovery.Endpoint{
+		helper_test.CreateEndpoint("echo.local", nil, true, false),
+	}
+	cache := &helper_test.CacheMock{
+		SvcList: []*api.Service{svc},
+		EpList:  map[string][]*discovery.EndpointSlice{"default/echo": {ep1, ep2, ep3}},
+	}
+	ready, notReady, err := CreateEndpoints(cache, svc, FindServicePort(svc, "8080"))
+	expReady := []*Endpoint{
+		{IP: "172.17.0.11", Port: 8080},
+		{IP: "172.17.0.14", Port: 8080},
+		{IP: "172.17.0.12", Port: 8080},
+	}
+	expNotReady := []*Endpoint{
+		{IP: "172.17.0.13", Port: 8080},
+		{IP: "172.17.0.15", Port: 8080},
+		{IP: "172.17.0.17", Port: 8080},
+	}
+	if !reflect.DeepEqual(ready, expReady) {
+		t.Errorf("'ready' endpoints differ -- expected: %+v -- actual: %+v", expReady, ready)
+	}
+	if !reflect.DeepEqual(notReady, expNotReady) {
+		t.Errorf("'notReady' endpoints differ -- expected: %+v -- actual: %+v", expNotReady, notReady)
+	}
+	if err != nil {
+		t.Errorf("CreateEndpoints raised an unexpected error: %v", err)
+	}
+}
+
+func TestCreateEndpointsZones(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	zoneA := "zone-a"
+	node2 := "node2"
+	svc, ep := helper_test.CreateService("default/echo", "8080", "")
+	ep.Endpoints = []discovery.Endpoint{
+		{Addresses: []string{"172.17.0.11"}, Zone: &zoneA},
+		{Addresses: []string{"172.17.0.12"}, Zone: &zoneA, Hints: &discovery.EndpointHints{
+			ForZones: []discovery.ForZone{{Name: "zone-b"}, {Name: "zone-c"}},
+		}},
+		{Addresses: []string{"172.17.0.13"}, NodeName: &node2},
+		{Addresses: []string{"172.17.0.14"}, TargetRef: &api.ObjectReference{Kind: "Pod", Namespace: "default", Name: "echo-1"}},
+		{Addresses: []string{"172.17.0.15"}, TargetRef: &api.ObjectReference{Kind: "Pod", Namespace: "default", Name: "echo-2"}},
+	}
+	cache := &helper_test.CacheMock{
+		SvcList: []*api.Service{svc},
+		EpList:  map[string][]*discovery.EndpointSlice{"default/echo": {ep}},
+		PodList: map[string]*api.Pod{
+			"default/echo-1": {Spec: api.PodSpec{NodeName: "node1"}},
+		},
+		NodeList: map[string]*api.Node{
+			"node1": {ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{api.LabelFailureDomainBetaZone: "zone-d"}}},
+			"node2": {ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{api.LabelTopologyZone: "zone-e"}}},
+		},
+	}
+	ready, _, err := CreateEndpoints(cache, svc, FindServicePort(svc, "8080"))
+	expected := []*Endpoint{
+		{IP: "172.17.0.11", Port: 8080, Zones: []string{"zone-a"}},
+		{IP: "172.17.0.12", Port: 8080, Zones: []string{"zone-b", "zone-c"}},
+		{IP: "172.17.0.13", Port: 8080, Zones: []string{"zone-e"}},
+		{IP: "172.17.0.14", Port: 8080, TargetRef: "default/echo-1", Zones: []string{"zone-d"}},
+		{IP: "172.17.0.15", Port: 8080, TargetRef: "default/echo-2"},
+	}
+	if !reflect.DeepEqual(ready, expected) {
+		t.Errorf("endpoints differ -- expected: %+v -- actual: %+v", expected, ready)
+	}
+	if err != nil {
+		t.Errorf("CreateEndpoints raised an unexpected error: %v", err)
+	}
+}
+
 type config struct {
 	t *testing.T
 }
@@ -24,11 +24,13 @@ import (
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/template"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 // Config ...
 type Config interface {
 	AcquireTCPBackend(servicename string, port int) *hatypes.TCPBackend
+	FindTCPBackend(port int) *hatypes.TCPBackend
 	AcquireHost(hostname string) *hatypes.Host
 	FindHost(hostname string) *hatypes.Host
 	AcquireBackend(namespace, name, port string) *hatypes.Backend
@@ -40,10 +42,12 @@ type Config interface {
 	FrontendGroup() *hatypes.FrontendGroup
 	BuildFrontendGroup() error
 	BuildBackendMaps() error
+	AcmeData() *hatypes.AcmeData
 	DefaultHost() *hatypes.Host
 	DefaultBackend() *hatypes.Backend
 	Global() *hatypes.Global
 	TCPBackends() []*hatypes.TCPBackend
+	TCPFrontends() []*hatypes.TCPFrontend
 	Hosts() []*hatypes.Host
 	Backends() []*hatypes.Backend
 	Userlists() []*hatypes.Userlist
@@ -51,12 +55,14 @@ type Config interface {
 }
 
 type config struct {
+	logger          types.Logger
 	fgroup          *hatypes.FrontendGroup
-	bindUtils       hatypes.BindUtils
 	mapsTemplate    *template.Config
 	mapsDir         string
+	acmeData        hatypes.AcmeData
 	global          hatypes.Global
 	tcpbackends     []*hatypes.TCPBackend
+	tcpfrontends    []*hatypes.TCPFrontend
 	hosts           []*hatypes.Host
 	backends        []*hatypes.Backend
 	userlists       []*hatypes.Userlist
@@ -66,17 +72,18 @@ type config struct {
 }
 
 type options struct {
+	logger       types.Logger
 	mapsTemplate *template.Config
 	mapsDir      string
 }
 
-func createConfig(bindUtils hatypes.BindUtils, options options) *config {
+func createConfig(options options) *config {
 	mapsTemplate := options.mapsTemplate
 	if mapsTemplate == nil {
 		mapsTemplate = template.CreateConfig()
 	}
 	return &config{
-		bindUtils:    bindUtils,
+		logger:       options.logger,
 		mapsTemplate: mapsTemplate,
 		mapsDir:      options.mapsDir,
 	}
@@ -104,6 +111,15 @@ func (c *config) AcquireTCPBackend(servicename string, port int) *hatypes.TCPBac
 	return backend
 }
 
+func (c *config) FindTCPBackend(port int) *hatypes.TCPBackend {
+	for _, backend := range c.tcpbackends {
+		if backend.Port == port {
+			return backend
+		}
+	}
+	return nil
+}
+
 func (c *config) AcquireHost(hostname string) *hatypes.Host {
 	if host := c.FindHost(hostname); host != nil {
 		return host
@@ -236,7 +252,10 @@ func (c *config) BuildFrontendGroup() error {
 		HTTPFrontsMap:     fgroupMaps.AddMap(c.mapsDir + "/_global_http_front.map"),
 		HTTPRootRedirMap:  fgroupMaps.AddMap(c.mapsDir + "/_global_http_root_redir.map"),
 		HTTPSRedirMap:     fgroupMaps.AddMap(c.mapsDir + "/_global_https_redir.map"),
+		NoAccessLogMap:    fgroupMaps.AddMap(c.mapsDir + "/_global_no_access_log.map"),
+		RequestIDMap:      fgroupMaps.AddMap(c.mapsDir + "/_global_request_id.map"),
 		SSLPassthroughMap: fgroupMaps.AddMap(c.mapsDir + "/_global_sslpassthrough.map"),
+		VarIngressMap:     fgroupMaps.AddMap(c.mapsDir + "/_global_k8s_ingress.map"),
 		VarNamespaceMap:   fgroupMaps.AddMap(c.mapsDir + "/_global_k8s_ns.map"),
 	}
 	if c.global.Bind.HasFrontingProxy() {
@@ -254,20 +273,7 @@ func (c *config) BuildFrontendGroup() error {
 			for _, bind := range frontend.Binds {
 				i++
 				bindName := fmt.Sprintf("_socket%03d", i)
-				if len(bind.Hosts) == 1 {
-					bind.TLS.TLSCert = c.defaultX509Cert
-					tlsFilename := bind.Hosts[0].TLS.TLSFilename
-					if tlsFilename != c.defaultX509Cert {
-						bind.TLS.TLSCertDir = tlsFilename
-					}
-				} else {
-					x509dir, err := c.createCertsDir(bindName, bind.Hosts)
-					if err != nil {
-						return err
-					}
-					bind.TLS.TLSCert = c.defaultX509Cert
-					bind.TLS.TLSCertDir = x509dir
-				}
+				bind.TLS.TLSCert = c.defaultX509Cert
 				bind.Name = bindName
 				bind.Socket = fmt.Sprintf("unix@/var/run/%s.sock", bindName)
 				bind.TLS.ALPN = c.global.SSL.ALPN
@@ -281,20 +287,7 @@ func (c *config) BuildFrontendGroup() error {
 		bind.Socket = c.global.Bind.HTTPSBind
 		bind.TLS.ALPN = c.global.SSL.ALPN
 		bind.AcceptProxy = c.global.Bind.AcceptProxy
-		if len(bind.Hosts) == 1 {
-			bind.TLS.TLSCert = c.defaultX509Cert
-			tlsFilename := bind.Hosts[0].TLS.TLSFilename
-			if tlsFilename != c.defaultX509Cert {
-				bind.TLS.TLSCertDir = tlsFilename
-			}
-		} else {
-			x509dir, err := c.createCertsDir(bind.Name, bind.Hosts)
-			if err != nil {
-				return err
-			}
-			frontends[0].Binds[0].TLS.TLSCert = c.defaultX509Cert
-			frontends[0].Binds[0].TLS.TLSCertDir = x509dir
-		}
+		bind.TLS.TLSCert = c.defaultX509Cert
 	}
 	for _, frontend := range frontends {
 		mapsPrefix := c.mapsDir + "/" + frontend.Name
@@ -305,11 +298,13 @@ func (c *config) BuildFrontendGroup() error {
 		frontend.SNIBackendsMap = frontend.Maps.AddMap(mapsPrefix + "_sni.map")
 		frontend.TLSInvalidCrtErrorList = frontend.Maps.AddMap(mapsPrefix + "_inv_crt.list")
 		frontend.TLSInvalidCrtErrorPagesMap = frontend.Maps.AddMap(mapsPrefix + "_inv_crt_redir.map")
+		frontend.TLSNeedCrtPathsMap = frontend.Maps.AddMap(mapsPrefix + "_need_crt_paths.map")
 		frontend.TLSNoCrtErrorList = frontend.Maps.AddMap(mapsPrefix + "_no_crt.list")
 		frontend.TLSNoCrtErrorPagesMap = frontend.Maps.AddMap(mapsPrefix + "_no_crt_redir.map")
 		for _, bind := range frontend.Binds {
 			bind.Maps = hatypes.CreateMaps()
 			bind.UseServerList = bind.Maps.AddMap(c.mapsDir + "/" + bind.Name + ".list")
+			c.buildCrtList(bind)
 		}
 	}
 	// Some maps use yes/no answers instead of a list with found/missing keys
@@ -348,6 +343,7 @@ func (c *config) BuildFrontendGroup() error {
 			}
 			// TODO implement deny 413 and move all MaxBodySize stuff to backend
 			maxBodySizes := map[string]int64{}
+			needCrtPaths := map[string]bool{}
 			for _, path := range host.Paths {
 				backend := c.FindBackend(path.Backend.Namespace, path.Backend.Name, path.Backend.Port)
 				base := host.Hostname + path.Path
@@ -382,6 +378,9 @@ func (c *config) BuildFrontendGroup() error {
 					if maxBodySize := backend.MaxBodySizeHostpath(base); maxBodySize > 0 {
 						maxBodySizes[base] = maxBodySize
 					}
+					if backend.TLSCertRequiredHostpath(base) {
+						needCrtPaths[base] = true
+					}
 				}
 				if !hasSSLRedirect || c.global.Bind.HasFrontingProxy() {
 					fgroup.HTTPFrontsMap.AppendHostname(base, back)
@@ -395,6 +394,20 @@ func (c *config) BuildFrontendGroup() error {
 					ns = "-"
 				}
 				fgroup.VarNamespaceMap.AppendHostname(base, ns)
+				if path.Ingress != "" && c.global.Syslog.Endpoint != "" {
+					// only used by the access logs
+					fgroup.VarIngressMap.AppendHostname(base, path.Ingress)
+				}
+				if host.NoAccessLog {
+					fgroup.NoAccessLogMap.AppendHostname(base, "silent")
+					fgroup.NoAccessLogMap.AppendAliasName(aliasName, "silent")
+					fgroup.NoAccessLogMap.AppendAliasRegex(aliasRegex, "silent")
+				}
+				if host.RequestID != "" {
+					fgroup.RequestIDMap.AppendHostname(base, host.RequestID)
+					fgroup.RequestIDMap.AppendAliasName(aliasName, host.RequestID)
+					fgroup.RequestIDMap.AppendAliasRegex(aliasRegex, host.RequestID)
+				}
 			}
 			// TODO implement deny 413 and move all MaxBodySize stuff to backend
 			if len(maxBodySizes) > 0 {
@@ -409,11 +422,18 @@ func (c *config) BuildFrontendGroup() error {
 				f.TLSInvalidCrtErrorList.AppendHostname(host.Hostname, "")
 				if !host.TLS.CAVerifyOptional {
 					f.TLSNoCrtErrorList.AppendHostname(host.Hostname, "")
+				} else if len(needCrtPaths) > 0 {
+					// optional client certificate on the host, but mandatory on some paths;
+					// add all paths of the same host to avoid overlap
+					for _, path := range host.Paths {
+						base := host.Hostname + path.Path
+						f.TLSNeedCrtPathsMap.AppendHostname(base, yesno[needCrtPaths[base]])
+					}
 				}
 				page := host.TLS.CAErrorPage
 				if page != "" {
 					f.TLSInvalidCrtErrorPagesMap.AppendHostname(host.Hostname, page)
-					if !host.TLS.CAVerifyOptional {
+					if !host.TLS.CAVerifyOptional || len(needCrtPaths) > 0 {
 						f.TLSNoCrtErrorPagesMap.AppendHostname(host.Hostname, page)
 					}
 				}
@@ -434,6 +454,12 @@ func (c *config) BuildFrontendGroup() error {
 	if err := writeMaps(fgroup.Maps, c.mapsTemplate); err != nil {
 		return err
 	}
+	tcpfrontends := c.buildTCPFrontends()
+	for _, f := range tcpfrontends {
+		if err := writeMaps(f.Maps, c.mapsTemplate); err != nil {
+			return err
+		}
+	}
 	for _, f := range frontends {
 		if err := writeMaps(f.Maps, c.mapsTemplate); err != nil {
 			return err
@@ -445,9 +471,45 @@ func (c *config) BuildFrontendGroup() error {
 		}
 	}
 	c.fgroup = fgroup
+	c.tcpfrontends = tcpfrontends
 	return nil
 }
 
+// buildTCPFrontends groups TCP backends that declare an SNI hostname
+// by public port. Bind and frontend options, the PROXY protocol and
+// the client timeout, are read from the first backend of the port.
+// Backends with distinct options aren't added to the frontend.
+func (c *config) buildTCPFrontends() []*hatypes.TCPFrontend {
+	var frontends []*hatypes.TCPFrontend
+	ports := map[int]*hatypes.TCPFrontend{}
+	for _, backend := range c.tcpbackends {
+		if backend.SNI == "" {
+			continue
+		}
+		frontend, found := ports[backend.Port]
+		if !found {
+			frontend = &hatypes.TCPFrontend{
+				Port:          backend.Port,
+				AcceptProxy:   backend.ProxyProt.Decode,
+				TimeoutClient: backend.Timeout.Client,
+				Maps:          hatypes.CreateMaps(),
+			}
+			frontend.SNIBackendsMap = frontend.Maps.AddMap(fmt.Sprintf("%s/_front_tcp_%d_sni.map", c.mapsDir, backend.Port))
+			ports[backend.Port] = frontend
+			frontends = append(frontends, frontend)
+		} else if frontend.AcceptProxy != backend.ProxyProt.Decode || frontend.TimeoutClient != backend.Timeout.Client {
+			c.logger.Warn("skipping SNI hostname '%s' of TCP backend '%s': PROXY protocol and client timeout "+
+				"differ from the other backends on public port %d", backend.SNI, backend.Name, backend.Port)
+			continue
+		}
+		frontend.SNIBackendsMap.AppendHostname(backend.SNI, backend.BackendName())
+	}
+	sort.Slice(frontends, func(i, j int) bool {
+		return frontends[i].Port < frontends[j].Port
+	})
+	return frontends
+}
+
 func (c *config) BuildBackendMaps() error {
 	// TODO rename HostMap types to HAProxyMap
 	maps := hatypes.CreateMaps()
@@ -478,20 +540,54 @@ func writeMaps(maps *hatypes.HostsMaps, template *template.Config) error {
 	return nil
 }
 
-func (c *config) createCertsDir(bindName string, hosts []*hatypes.Host) (string, error) {
-	certs := make([]string, 0, len(hosts))
-	added := map[string]bool{}
-	for _, host := range hosts {
-		filename := host.TLS.TLSFilename
-		if filename != "" && !added[filename] && filename != c.defaultX509Cert {
-			certs = append(certs, host.TLS.TLSFilename)
-			added[filename] = true
+// buildCrtList adds a crt-list to the bind with all the certificates of its
+// hosts. The default certificate is the bind's `crt` and isn't added. HAProxy
+// chooses between certificates of the same domain, eg RSA and ECDSA, based on
+// the client capabilities.
+func (c *config) buildCrtList(bind *hatypes.BindConfig) {
+	var crtList *hatypes.HostsMap
+	appendCrt := func(filename, value string) {
+		if crtList == nil {
+			crtList = bind.Maps.AddMap(c.mapsDir + "/" + bind.Name + "_crt.list")
+		}
+		crtList.AppendItem(filename, value)
+	}
+	// hosts with their own TLS policy come first. Their certificates are
+	// filtered by SNI and take precedence over the ones without a filter.
+	for _, host := range bind.Hosts {
+		options := host.TLS.CrtListOptions()
+		if options == "" {
+			continue
+		}
+		filenames := host.TLS.TLSFilenames()
+		if len(filenames) == 0 {
+			filenames = []string{c.defaultX509Cert}
+		}
+		sni := host.Hostname
+		if host.Alias.AliasName != "" {
+			sni += " " + host.Alias.AliasName
+		}
+		for _, filename := range filenames {
+			appendCrt(filename, "["+options+"] "+sni)
 		}
 	}
-	if len(certs) == 0 {
-		return "", nil
+	added := map[string]bool{c.defaultX509Cert: true}
+	for _, host := range bind.Hosts {
+		if host.TLS.CrtListOptions() != "" {
+			continue
+		}
+		for _, filename := range host.TLS.TLSFilenames() {
+			if !added[filename] {
+				appendCrt(filename, "")
+				added[filename] = true
+			}
+		}
 	}
-	return c.bindUtils.CreateX509CertsDir(bindName, certs)
+	bind.TLS.CrtList = crtList
+}
+
+func (c *config) AcmeData() *hatypes.AcmeData {
+	return &c.acmeData
 }
 
 func (c *config) DefaultHost() *hatypes.Host {
@@ -510,6 +606,10 @@ func (c *config) TCPBackends() []*hatypes.TCPBackend {
 	return c.tcpbackends
 }
 
+func (c *config) TCPFrontends() []*hatypes.TCPFrontend {
+	return c.tcpfrontends
+}
+
 func (c *config) Hosts() []*hatypes.Host {
 	return c.hosts
 }
@@ -0,0 +1,192 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// endpointDrain is a removed server in drain state. Its slot is only
+// freed after the current connections finish or the timeout expires.
+type endpointDrain struct {
+	backname string
+	server   string
+	target   string
+	timeout  time.Duration
+	deadline time.Time
+}
+
+// endpointDrainer watches the connections of all the draining servers
+// with a single poller, so only one `show stat` is sent per interval
+// regardless of the number of draining servers.
+type endpointDrainer struct {
+	logger   types.Logger
+	cmd      func(socket string, commands ...string) ([]string, error)
+	stat     func(socket, command string) (string, error)
+	interval time.Duration
+	mutex    sync.Mutex
+	socket   string
+	polling  bool
+	running  map[string]*endpointDrain
+}
+
+func newEndpointDrainer(logger types.Logger) *endpointDrainer {
+	return &endpointDrainer{
+		logger:   logger,
+		cmd:      utils.HAProxyCommand,
+		stat:     utils.HAProxyCommandOutput,
+		interval: time.Second,
+		running:  map[string]*endpointDrain{},
+	}
+}
+
+// start watches the connections of the draining servers in the background
+func (e *endpointDrainer) start(socket string, drains []*endpointDrain) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(drains) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, drain := range drains {
+		drain.deadline = now.Add(drain.timeout)
+		e.running[drain.backname+"/"+drain.server] = drain
+	}
+	e.socket = socket
+	if !e.polling {
+		e.polling = true
+		go e.poll()
+	}
+}
+
+func (e *endpointDrainer) poll() {
+	for {
+		time.Sleep(e.interval)
+		e.mutex.Lock()
+		if len(e.running) == 0 {
+			e.polling = false
+			e.mutex.Unlock()
+			return
+		}
+		socket := e.socket
+		drains := make([]*endpointDrain, 0, len(e.running))
+		for _, drain := range e.running {
+			drains = append(drains, drain)
+		}
+		e.mutex.Unlock()
+		conns, err := e.currentConns(socket)
+		if err != nil {
+			e.logger.Warn("error reading connections of draining servers: %v", err)
+		}
+		now := time.Now()
+		for _, drain := range drains {
+			key := drain.backname + "/" + drain.server
+			if now.After(drain.deadline) {
+				e.logger.InfoV(2, "drain timeout of endpoint '%s' on backend/server '%s'", drain.target, key)
+				e.disable(socket, drain)
+			} else if err != nil {
+				continue
+			} else if conn, found := conns[key]; !found {
+				e.logger.Warn("error reading connections of backend/server '%s': server not found", key)
+			} else if conn == 0 {
+				e.disable(socket, drain)
+			}
+		}
+	}
+}
+
+// currentConns reads the current connections of all the servers,
+// indexed by backend/server
+func (e *endpointDrainer) currentConns(socket string) (map[string]int, error) {
+	// -1 4 -1: servers of all the proxies
+	out, err := e.stat(socket, "show stat -1 4 -1")
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := parseStat(out)
+	if err != nil {
+		return nil, err
+	}
+	conns := make(map[string]int, len(proxies))
+	for _, proxy := range proxies {
+		if proxy.Type == ProxyServer {
+			var conn int
+			if _, err := fmt.Sscan(proxy.Fields["scur"], &conn); err != nil {
+				return nil, err
+			}
+			conns[proxy.Proxy+"/"+proxy.Server] = conn
+		}
+	}
+	return conns, nil
+}
+
+// disable frees the slot of a drained server. The lock ensures that a
+// dynamic update doesn't reuse the slot before the commands are sent.
+func (e *endpointDrainer) disable(socket string, drain *endpointDrain) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	key := drain.backname + "/" + drain.server
+	if e.running[key] != drain {
+		// canceled or restarted
+		return
+	}
+	delete(e.running, key)
+	server := fmt.Sprintf("set server %s ", key)
+	_, err := e.cmd(socket,
+		server+"state maint",
+		server+"addr 127.0.0.1 port 1023",
+		server+"weight 0",
+	)
+	if err != nil {
+		e.logger.Error("error disabling drained endpoint %s: %v", key, err)
+		return
+	}
+	e.logger.InfoV(2, "disabled drained endpoint '%s' on backend/server '%s'", drain.target, key)
+}
+
+// drainingTarget returns the target of a draining server, or an
+// empty string if the server isn't draining
+func (e *endpointDrainer) drainingTarget(backname, server string) string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if drain, found := e.running[backname+"/"+server]; found {
+		return drain.target
+	}
+	return ""
+}
+
+// stop cancels the drain of a server, eg its target was added back.
+// The slot isn't disabled after stop returns.
+func (e *endpointDrainer) stop(backname, server string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.running, backname+"/"+server)
+}
+
+// stopAll cancels all the running drains, used before reloads
+func (e *endpointDrainer) stopAll() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for key := range e.running {
+		delete(e.running, key)
+	}
+}
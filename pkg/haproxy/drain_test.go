@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestEndpointDrainer(t *testing.T) {
+	testCases := []struct {
+		scur     []string
+		timeout  time.Duration
+		expected []string
+		logging  string
+	}{
+		// 0
+		{
+			scur:    []string{"2", "1", "0"},
+			timeout: time.Hour,
+			expected: []string{
+				"set server default_app_8080/srv001 state maint",
+				"set server default_app_8080/srv001 addr 127.0.0.1 port 1023",
+				"set server default_app_8080/srv001 weight 0",
+			},
+			logging: `INFO-V(2) disabled drained endpoint '172.17.0.2:8080' on backend/server 'default_app_8080/srv001'`,
+		},
+		// 1
+		{
+			scur:    []string{"2"},
+			timeout: 20 * time.Millisecond,
+			expected: []string{
+				"set server default_app_8080/srv001 state maint",
+				"set server default_app_8080/srv001 addr 127.0.0.1 port 1023",
+				"set server default_app_8080/srv001 weight 0",
+			},
+			logging: `
+INFO-V(2) drain timeout of endpoint '172.17.0.2:8080' on backend/server 'default_app_8080/srv001'
+INFO-V(2) disabled drained endpoint '172.17.0.2:8080' on backend/server 'default_app_8080/srv001'`,
+		},
+	}
+	for i, test := range testCases {
+		var mutex sync.Mutex
+		var cmds []string
+		logger := &helper_test.LoggerMock{T: t}
+		drainer := newEndpointDrainer(logger)
+		drainer.interval = time.Millisecond
+		drainer.cmd = func(socket string, commands ...string) ([]string, error) {
+			mutex.Lock()
+			cmds = append(cmds, commands...)
+			mutex.Unlock()
+			return nil, nil
+		}
+		polls := 0
+		drainer.stat = func(socket, command string) (string, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			scur := test.scur[len(test.scur)-1]
+			if polls < len(test.scur) {
+				scur = test.scur[polls]
+			}
+			polls++
+			return "# pxname,svname,scur,type,\ndefault_app_8080,srv001," + scur + ",2,\n", nil
+		}
+		drainer.start("/var/run/haproxy.sock", []*endpointDrain{
+			{backname: "default_app_8080", server: "srv001", target: "172.17.0.2:8080", timeout: test.timeout},
+		})
+		for j := 0; j < 100 && drainer.drainingTarget("default_app_8080", "srv001") != ""; j++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		mutex.Lock()
+		if !reflect.DeepEqual(cmds, test.expected) {
+			t.Errorf("commands differ on %d - expected: %v - actual: %v", i, test.expected, cmds)
+		}
+		mutex.Unlock()
+		logger.CompareLogging(test.logging)
+	}
+}
+
+func TestEndpointDrainerSharedPoll(t *testing.T) {
+	var mutex sync.Mutex
+	var cmds, stats []string
+	drainer := newEndpointDrainer(&helper_test.LoggerMock{T: t})
+	drainer.interval = time.Millisecond
+	drainer.cmd = func(socket string, commands ...string) ([]string, error) {
+		mutex.Lock()
+		cmds = append(cmds, commands...)
+		mutex.Unlock()
+		return nil, nil
+	}
+	drainer.stat = func(socket, command string) (string, error) {
+		mutex.Lock()
+		stats = append(stats, command)
+		mutex.Unlock()
+		return "# pxname,svname,scur,type,\ndefault_app_8080,srv001,0,2,\ndefault_app_8080,srv002,0,2,\n", nil
+	}
+	drainer.start("/var/run/haproxy.sock", []*endpointDrain{
+		{backname: "default_app_8080", server: "srv001", target: "172.17.0.2:8080", timeout: time.Hour},
+		{backname: "default_app_8080", server: "srv002", target: "172.17.0.3:8080", timeout: time.Hour},
+	})
+	for j := 0; j < 100; j++ {
+		drainer.mutex.Lock()
+		polling := drainer.polling
+		drainer.mutex.Unlock()
+		if !polling {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if expected := []string{"show stat -1 4 -1"}; !reflect.DeepEqual(stats, expected) {
+		t.Errorf("stat commands differ - expected: %v - actual: %v", expected, stats)
+	}
+	if len(cmds) != 6 {
+		t.Errorf("expected commands disabling srv001 and srv002, actual: %v", cmds)
+	}
+}
+
+func TestEndpointDrainerStop(t *testing.T) {
+	drainer := newEndpointDrainer(&helper_test.LoggerMock{T: t})
+	drainer.cmd = func(socket string, commands ...string) ([]string, error) {
+		t.Errorf("unexpected commands: %v", commands)
+		return nil, nil
+	}
+	drainer.stat = func(socket, command string) (string, error) {
+		return "# pxname,svname,scur,type,\ndefault_app_8080,srv001,1,2,\n", nil
+	}
+	drainer.start("/var/run/haproxy.sock", []*endpointDrain{
+		{backname: "default_app_8080", server: "srv001", target: "172.17.0.2:8080", timeout: time.Hour},
+	})
+	if drainer.drainingTarget("default_app_8080", "srv001") == "" {
+		t.Errorf("expected srv001 draining")
+	}
+	drainer.stopAll()
+	if drainer.drainingTarget("default_app_8080", "srv001") != "" {
+		t.Errorf("expected srv001 not draining")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"time"
 
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
@@ -28,12 +29,17 @@ import (
 )
 
 type dynUpdater struct {
-	logger types.Logger
-	old    *config
-	cur    *config
-	socket string
-	cmd    func(socket string, commands ...string) ([]string, error)
-	cmdCnt int
+	logger  types.Logger
+	old     *config
+	cur     *config
+	socket  string
+	cmd     func(socket string, commands ...string) ([]string, error)
+	cmdCnt  int
+	reason  string
+	ramper  *weightRamper
+	ramps   []*weightRamp
+	drainer *endpointDrainer
+	drains  []*endpointDrain
 }
 
 type backendPair struct {
@@ -41,6 +47,11 @@ type backendPair struct {
 	cur *hatypes.Backend
 }
 
+type tcpBackendPair struct {
+	old *hatypes.TCPBackend
+	cur *hatypes.TCPBackend
+}
+
 type epPair struct {
 	old *hatypes.Endpoint
 	cur *hatypes.Endpoint
@@ -55,11 +66,13 @@ func (i *instance) newDynUpdater() *dynUpdater {
 		cur = i.curConfig.(*config)
 	}
 	return &dynUpdater{
-		logger: i.logger,
-		old:    old,
-		cur:    cur,
-		socket: i.curConfig.Global().AdminSocket,
-		cmd:    utils.HAProxyCommand,
+		logger:  i.logger,
+		old:     old,
+		cur:     cur,
+		socket:  i.curConfig.Global().AdminSocket,
+		cmd:     utils.HAProxyCommand,
+		ramper:  i.ramper,
+		drainer: i.drainer,
 	}
 }
 
@@ -72,23 +85,35 @@ func (d *dynUpdater) update() bool {
 	return updated
 }
 
+// setReason configures the reason of a reload. Only the first reason is
+// kept, the following ones are a consequence of the first failure.
+func (d *dynUpdater) setReason(reason string) {
+	if d.reason == "" {
+		d.reason = reason
+	}
+}
+
 func (d *dynUpdater) checkConfigPair() bool {
 	oldConfig := d.old
 	curConfig := d.cur
 	if oldConfig == nil || curConfig == nil {
+		d.setReason("initial")
 		return false
 	}
 
 	// check equality of everything but backends
+	// acme data doesn't change the haproxy config
 	oldConfigCopy := *oldConfig
+	oldConfigCopy.acmeData = curConfig.acmeData
 	oldConfigCopy.backends = curConfig.backends
 	oldConfigCopy.defaultBackend = curConfig.defaultBackend
+	oldConfigCopy.tcpbackends = curConfig.tcpbackends
 	if !reflect.DeepEqual(&oldConfigCopy, curConfig) {
 		var diff []string
 		if !reflect.DeepEqual(oldConfig.global, curConfig.global) {
 			diff = append(diff, "global")
 		}
-		if !reflect.DeepEqual(oldConfig.tcpbackends, curConfig.tcpbackends) {
+		if !reflect.DeepEqual(oldConfig.tcpfrontends, curConfig.tcpfrontends) {
 			diff = append(diff, "tcp-services")
 		}
 		if !reflect.DeepEqual(oldConfig.hosts, curConfig.hosts) {
@@ -98,6 +123,11 @@ func (d *dynUpdater) checkConfigPair() bool {
 			diff = append(diff, "userlists")
 		}
 		d.logger.InfoV(2, "diff outside backends - %v", diff)
+		reason := "other"
+		if len(diff) > 0 {
+			reason = diff[0]
+		}
+		d.setReason(reason)
 		return false
 	}
 
@@ -105,6 +135,7 @@ func (d *dynUpdater) checkConfigPair() bool {
 	// return false if len or names doesn't match
 	if len(curConfig.backends) != len(curConfig.backends) {
 		d.logger.InfoV(2, "added or removed backend(s)")
+		d.setReason("backends")
 		return false
 	}
 	backends := make(map[string]*backendPair, len(oldConfig.backends))
@@ -115,6 +146,7 @@ func (d *dynUpdater) checkConfigPair() bool {
 		back, found := backends[backend.ID]
 		if !found {
 			d.logger.InfoV(2, "removed backend '%s'", backend.ID)
+			d.setReason("backends")
 			return false
 		}
 		back.cur = backend
@@ -129,6 +161,34 @@ func (d *dynUpdater) checkConfigPair() bool {
 		}
 	}
 
+	// map TCP backends of old and new config together
+	// return false if len or names doesn't match
+	if len(oldConfig.tcpbackends) != len(curConfig.tcpbackends) {
+		d.logger.InfoV(2, "added or removed TCP service(s)")
+		d.setReason("tcp-backends")
+		return false
+	}
+	tcpbackends := make(map[string]*tcpBackendPair, len(oldConfig.tcpbackends))
+	for _, backend := range oldConfig.tcpbackends {
+		tcpbackends[backend.BackendName()] = &tcpBackendPair{old: backend}
+	}
+	for _, backend := range curConfig.tcpbackends {
+		back, found := tcpbackends[backend.BackendName()]
+		if !found {
+			d.logger.InfoV(2, "added TCP service '%s'", backend.BackendName())
+			d.setReason("tcp-backends")
+			return false
+		}
+		back.cur = backend
+	}
+
+	// try to dynamically update every single TCP backend
+	for _, pair := range tcpbackends {
+		if !d.checkTCPBackendPair(pair) {
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -142,6 +202,7 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	oldBackCopy.Endpoints = curBack.Endpoints
 	if !reflect.DeepEqual(&oldBackCopy, curBack) {
 		d.logger.InfoV(2, "diff outside endpoints of backend '%s'", curBack.ID)
+		d.setReason("backend-config")
 		return false
 	}
 
@@ -152,39 +213,85 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 		for i := len(curBack.Endpoints); i < len(oldBack.Endpoints); i++ {
 			curBack.AddEmptyEndpoint()
 		}
-		return len(oldBack.Endpoints) == len(curBack.Endpoints)
+		if len(oldBack.Endpoints) != len(curBack.Endpoints) {
+			d.setReason("endpoints-added")
+			return false
+		}
+		return true
 	}
 
+	slowStart := parseTime(curBack.Server.SlowStart)
+	return d.checkEndpoints(curBack.ID, curBack.Dynamic, slowStart, oldBack.Endpoints, curBack.Endpoints, curBack.AddEmptyEndpoint)
+}
+
+func (d *dynUpdater) checkTCPBackendPair(pair *tcpBackendPair) bool {
+	oldBack := pair.old
+	curBack := pair.cur
+
+	// check equality of everything but endpoints
+	oldBackCopy := *oldBack
+	oldBackCopy.Dynamic = curBack.Dynamic
+	oldBackCopy.Endpoints = curBack.Endpoints
+	if !reflect.DeepEqual(&oldBackCopy, curBack) {
+		d.logger.InfoV(2, "diff outside endpoints of TCP service '%s'", curBack.BackendName())
+		d.setReason("backend-config")
+		return false
+	}
+
+	return d.checkEndpoints(curBack.BackendName(), curBack.Dynamic, 0, oldBack.Endpoints, curBack.Endpoints, curBack.AddEmptyEndpoint)
+}
+
+// checkEndpoints tries to dynamically update the endpoints of a backend, which
+// can be either a HTTP or a TCP one. addEmpty should add a new empty slot to the
+// current backend. slowStart, if not zero, is the time the weight of endpoints
+// changing from zero take to reach its configured value.
+func (d *dynUpdater) checkEndpoints(backname string, dynamic hatypes.DynBackendConfig, slowStart time.Duration, oldEndpoints, curEndpoints []*hatypes.Endpoint, addEmpty func() *hatypes.Endpoint) bool {
 	// can decrease endpoints, cannot increase
-	if len(oldBack.Endpoints) < len(curBack.Endpoints) {
-		d.logger.InfoV(2, "added endpoints on backend '%s'", curBack.ID)
+	if len(oldEndpoints) < len(curEndpoints) {
+		d.logger.InfoV(2, "added endpoints on backend '%s'", backname)
+		d.setReason("endpoints-added")
+		return false
+	}
+
+	// the backend can scale beyond its slots, e.g. an HPA changed its maxReplicas
+	if dynamic.DynUpdate && countSlots(oldEndpoints, false) < dynamic.MinSlots {
+		d.logger.InfoV(2, "backend '%s' has less than %d slots", backname, dynamic.MinSlots)
+		d.setReason("slots-resized")
 		return false
 	}
 
 	// most of the backends are equal, save some proc stopping here if deep equals
-	if reflect.DeepEqual(oldBack.Endpoints, curBack.Endpoints) {
+	if reflect.DeepEqual(oldEndpoints, curEndpoints) {
 		return true
 	}
 
-	// oldBack and curBack differs, DynUpdate is disabled, need to reload
+	// oldEndpoints and curEndpoints differs, DynUpdate is disabled, need to reload
 	// TODO check if endpoints are the same and only the order differ
-	if !curBack.Dynamic.DynUpdate {
-		d.logger.InfoV(2, "backend '%s' changed and its dynamic-scaling is 'false'", curBack.ID)
+	if !dynamic.DynUpdate {
+		d.logger.InfoV(2, "backend '%s' changed and its dynamic-scaling is 'false'", backname)
+		d.setReason("dynamic-scaling-disabled")
 		return false
 	}
 
 	// map endpoints of old and new config together
-	endpoints := make(map[string]*epPair, len(oldBack.Endpoints))
-	targets := make([]string, 0, len(oldBack.Endpoints))
-	var empty []string
-	for _, endpoint := range oldBack.Endpoints {
+	// empty slots whose server is still draining are only used if there
+	// isn't another one available
+	endpoints := make(map[string]*epPair, len(oldEndpoints))
+	targets := make([]string, 0, len(oldEndpoints))
+	var empty, draining []*hatypes.Endpoint
+	drainingTargets := map[string]*hatypes.Endpoint{}
+	for _, endpoint := range oldEndpoints {
 		if endpoint.Enabled {
 			endpoints[endpoint.Target] = &epPair{old: endpoint}
 			targets = append(targets, endpoint.Target)
+		} else if target := d.drainer.drainingTarget(backname, endpoint.Name); target != "" {
+			draining = append(draining, endpoint)
+			drainingTargets[target] = endpoint
 		} else {
-			empty = append(empty, endpoint.Name)
+			empty = append(empty, endpoint)
 		}
 	}
+	drainTimeout := parseTime(dynamic.DrainTimeout)
 
 	// From this point we cannot simply `return false` because endpoint.Name
 	// is being updated, need to be updated until the end, and endpoints slice
@@ -192,12 +299,25 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	updated := true
 
 	// reuse the backend/server which has the same target endpoint, if found,
-	// this will save some socket calls and will not mess endpoint metrics
-	var added []*hatypes.Endpoint
-	for _, endpoint := range curBack.Endpoints {
-		if pair, found := endpoints[endpoint.Target]; found {
+	// this will save some socket calls and will not mess endpoint metrics.
+	// backup state of a server cannot be changed, an endpoint which moved
+	// from or to the backup state is removed and added in another slot
+	// a target added back while its former slot is still draining reuses the slot
+	var added, readded []*hatypes.Endpoint
+	for _, endpoint := range curEndpoints {
+		if pair, found := endpoints[endpoint.Target]; found && pair.old.Backup == endpoint.Backup {
 			endpoint.Name = pair.old.Name
 			pair.cur = endpoint
+		} else if slot, found := drainingTargets[endpoint.Target]; found && slot.Backup == endpoint.Backup {
+			endpoint.Name = slot.Name
+			delete(drainingTargets, endpoint.Target)
+			for i := range draining {
+				if draining[i] == slot {
+					draining = append(draining[:i], draining[i+1:]...)
+					break
+				}
+			}
+			readded = append(readded, endpoint)
 		} else {
 			added = append(added, endpoint)
 		}
@@ -209,35 +329,78 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	for _, target := range targets {
 		pair := endpoints[target]
 		if pair.cur == nil {
-			if updated && !d.execDisableEndpoint(curBack.ID, pair.old) {
-				updated = false
+			if drainTimeout > 0 {
+				if updated && !d.execDrainEndpoint(backname, pair.old, drainTimeout) {
+					updated = false
+				}
+				draining = append(draining, pair.old)
+			} else {
+				if updated && !d.execDisableEndpoint(backname, pair.old) {
+					updated = false
+				}
+				empty = append(empty, pair.old)
 			}
-			empty = append(empty, pair.old.Name)
-		} else if updated && !d.checkEndpointPair(curBack.ID, pair) {
+		} else if updated && !d.checkEndpointPair(backname, pair, slowStart) {
 			updated = false
 		}
 	}
-	for i := range added {
-		// reusing empty slots from oldBack
-		added[i].Name = empty[i]
-		if updated && !d.execEnableEndpoint(curBack.ID, nil, added[i]) {
+	for _, endpoint := range readded {
+		d.drainer.stop(backname, endpoint.Name)
+		if updated && !d.execEnableEndpoint(backname, nil, endpoint, slowStart) {
+			updated = false
+		}
+	}
+	freeSlots := len(empty)
+	empty = append(empty, draining...)
+	for _, endpoint := range added {
+		// reusing empty slots from oldEndpoints, backup state of a
+		// server cannot be changed, so the slot should have the same one.
+		// HAProxy's slowstart doesn't cover servers enabled via the
+		// socket, so the weight of the slot is ramped by the controller
+		i := 0
+		for i < len(empty)-1 && empty[i].Backup != endpoint.Backup {
+			i++
+		}
+		slot := empty[i]
+		empty = append(empty[:i], empty[i+1:]...)
+		endpoint.Name = slot.Name
+		isDraining := i >= freeSlots
+		if !isDraining {
+			freeSlots--
+		}
+		if slot.Backup != endpoint.Backup {
+			d.logger.InfoV(2, "backup state of endpoint '%s' differs from the empty slots on backend '%s'", endpoint.Target, backname)
+			d.setReason("endpoints-backup")
+			updated = false
+		} else if isDraining {
+			// a reload also waits the connections of the old instance
+			d.logger.InfoV(2, "empty slots of backend '%s' are draining, cannot add endpoint '%s'", backname, endpoint.Target)
+			d.setReason("endpoints-draining")
+			updated = false
+		} else if updated && !d.execEnableEndpoint(backname, nil, endpoint, slowStart) {
 			updated = false
 		}
 	}
 
-	// copy remaining empty slots from oldBack to curBack, so it can be used in a future update
-	for i := len(added); i < len(empty); i++ {
-		curBack.AddEmptyEndpoint().Name = empty[i]
+	// copy remaining empty slots from oldEndpoints to the current backend, so it can be used in a future update
+	for _, slot := range empty {
+		endpoint := addEmpty()
+		endpoint.Name = slot.Name
+		endpoint.Backup = slot.Backup
 	}
 
 	return updated
 }
 
-func (d *dynUpdater) checkEndpointPair(backname string, pair *epPair) bool {
+func (d *dynUpdater) checkEndpointPair(backname string, pair *epPair, slowStart time.Duration) bool {
 	if reflect.DeepEqual(pair.old, pair.cur) {
 		return true
 	}
-	return d.execEnableEndpoint(backname, pair.old, pair.cur)
+	if pair.old.Weight > 0 {
+		// a server already receiving requests doesn't need a ramp
+		slowStart = 0
+	}
+	return d.execEnableEndpoint(backname, pair.old, pair.cur, slowStart)
 }
 
 func (d *dynUpdater) alignSlots() {
@@ -245,41 +408,79 @@ func (d *dynUpdater) alignSlots() {
 		return
 	}
 	for _, back := range d.cur.backends {
-		if !back.Dynamic.DynUpdate {
-			// no need to add empty slots if won't dynamically update
-			continue
+		for i := emptySlots(back.Dynamic, back.Endpoints, false); i > 0; i-- {
+			back.AddEmptyEndpoint()
 		}
-		minFreeSlots := back.Dynamic.MinFreeSlots
-		blockSize := back.Dynamic.BlockSize
-		if blockSize < 1 {
-			blockSize = 1
+		for i := emptySlots(back.Dynamic, back.Endpoints, true); i > 0; i-- {
+			back.AddEmptyEndpoint().Backup = true
 		}
-		var newFreeSlots int
-		if minFreeSlots == 0 && len(back.Endpoints) == 0 {
-			newFreeSlots = blockSize
-		} else {
-			totalFreeSlots := 0
-			for _, ep := range back.Endpoints {
-				if ep.IsEmpty() {
-					totalFreeSlots++
-				}
-			}
-			for i := totalFreeSlots; i < minFreeSlots; i++ {
-				back.AddEmptyEndpoint()
-			}
-			// * []endpoints == group of blocks
-			// * block == group of slots
-			// * slot == a single server
-			// newFreeSlots := blockSize - (1 <= <size-of-last-block> <= blockSize)
-			newFreeSlots = blockSize - (((len(back.Endpoints) + blockSize - 1) % blockSize) + 1)
-		}
-		for i := 0; i < newFreeSlots; i++ {
+	}
+	for _, back := range d.cur.tcpbackends {
+		for i := emptySlots(back.Dynamic, back.Endpoints, false); i > 0; i-- {
 			back.AddEmptyEndpoint()
 		}
+		for i := emptySlots(back.Dynamic, back.Endpoints, true); i > 0; i-- {
+			back.AddEmptyEndpoint().Backup = true
+		}
 	}
 }
 
+// countSlots returns the number of slots, either empty or not, whose
+// backup state is the same of backup.
+func countSlots(endpoints []*hatypes.Endpoint, backup bool) int {
+	count := 0
+	for _, ep := range endpoints {
+		if ep.Backup == backup {
+			count++
+		}
+	}
+	return count
+}
+
+// emptySlots returns the number of empty slots that should be added
+// to a backend, so it has at least MinFreeSlots empty slots, at least
+// MinSlots slots, and the number of slots is a multiple of BlockSize.
+// Slots of backup and non backup servers are counted apart, backup
+// slots are only added if BackupSlots is true.
+func emptySlots(dynamic hatypes.DynBackendConfig, endpoints []*hatypes.Endpoint, backup bool) int {
+	if !dynamic.DynUpdate || (backup && !dynamic.BackupSlots) {
+		// no need to add empty slots if won't dynamically update
+		return 0
+	}
+	slots := countSlots(endpoints, backup)
+	minFreeSlots := dynamic.MinFreeSlots
+	blockSize := dynamic.BlockSize
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	if minFreeSlots == 0 && dynamic.MinSlots == 0 && slots == 0 {
+		return blockSize
+	}
+	totalFreeSlots := 0
+	for _, ep := range endpoints {
+		if ep.Backup == backup && ep.IsEmpty() {
+			totalFreeSlots++
+		}
+	}
+	var missingFreeSlots int
+	if totalFreeSlots < minFreeSlots {
+		missingFreeSlots = minFreeSlots - totalFreeSlots
+	}
+	// MinSlots is the number of pods the backend can scale to
+	if missingSlots := dynamic.MinSlots - slots - missingFreeSlots; missingSlots > 0 {
+		missingFreeSlots += missingSlots
+	}
+	// * []endpoints == group of blocks
+	// * block == group of slots
+	// * slot == a single server
+	// newFreeSlots := blockSize - (1 <= <size-of-last-block> <= blockSize)
+	totalSlots := slots + missingFreeSlots
+	newFreeSlots := blockSize - (((totalSlots + blockSize - 1) % blockSize) + 1)
+	return missingFreeSlots + newFreeSlots
+}
+
 func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint) bool {
+	d.ramper.stop(backname, ep.Name)
 	server := fmt.Sprintf("set server %s/%s ", backname, ep.Name)
 	cmd := []string{
 		server + "state maint",
@@ -289,6 +490,7 @@ func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint)
 	msg, err := d.execCommand(cmd)
 	if err != nil {
 		d.logger.Error("error disabling endpoint %s/%s: %v", backname, ep.Name, err)
+		d.setReason("command-failed")
 		return false
 	}
 	d.logger.InfoV(2, "disabled endpoint '%s' on backend/server '%s/%s'", ep.Target, backname, ep.Name)
@@ -298,22 +500,63 @@ func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint)
 	return true
 }
 
-func (d *dynUpdater) execEnableEndpoint(backname string, oldEP, curEP *hatypes.Endpoint) bool {
+func (d *dynUpdater) execDrainEndpoint(backname string, ep *hatypes.Endpoint, timeout time.Duration) bool {
+	d.ramper.stop(backname, ep.Name)
+	server := fmt.Sprintf("set server %s/%s ", backname, ep.Name)
+	cmd := []string{
+		server + "state drain",
+	}
+	msg, err := d.execCommand(cmd)
+	if err != nil {
+		d.logger.Error("error draining endpoint %s/%s: %v", backname, ep.Name, err)
+		d.setReason("command-failed")
+		return false
+	}
+	d.logger.InfoV(2, "draining endpoint '%s' on backend/server '%s/%s'", ep.Target, backname, ep.Name)
+	for _, m := range msg {
+		d.logger.InfoV(2, m)
+	}
+	d.drains = append(d.drains, &endpointDrain{
+		backname: backname,
+		server:   ep.Name,
+		target:   ep.Target,
+		timeout:  timeout,
+	})
+	return true
+}
+
+func (d *dynUpdater) execEnableEndpoint(backname string, oldEP, curEP *hatypes.Endpoint, slowStart time.Duration) bool {
+	d.ramper.stop(backname, curEP.Name)
 	state := map[bool]string{true: "ready", false: "drain"}[curEP.Weight > 0]
+	weight := curEP.Weight
+	var ramp *weightRamp
+	if slowStart > 0 && weight > 1 {
+		ramp = &weightRamp{
+			backname: backname,
+			server:   curEP.Name,
+			weight:   curEP.Weight,
+			duration: slowStart,
+		}
+		weight = ramp.stepWeight(1)
+	}
 	server := fmt.Sprintf("set server %s/%s ", backname, curEP.Name)
 	cmd := []string{
 		server + "addr " + curEP.IP + " port " + strconv.Itoa(curEP.Port),
 		server + "state " + state,
-		server + "weight " + strconv.Itoa(curEP.Weight),
+		server + "weight " + strconv.Itoa(weight),
 	}
 	msg, err := d.execCommand(cmd)
 	if err != nil {
 		d.logger.Error("error adding/updating endpoint %s/%s: %v", backname, curEP.Name, err)
+		d.setReason("command-failed")
 		return false
 	}
 	event := map[bool]string{true: "updated", false: "added"}[oldEP != nil]
 	d.logger.InfoV(2, "%s endpoint '%s' weight '%d' state '%s' on backend/server '%s/%s'",
-		event, curEP.Target, curEP.Weight, state, backname, curEP.Name)
+		event, curEP.Target, weight, state, backname, curEP.Name)
+	if ramp != nil {
+		d.ramps = append(d.ramps, ramp)
+	}
 	for _, m := range msg {
 		d.logger.InfoV(2, m)
 	}
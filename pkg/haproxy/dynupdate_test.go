@@ -35,7 +35,10 @@ func TestDynUpdate(t *testing.T) {
 		doconfig2 func(c *testConfig)
 		expected  []string
 		dynamic   bool
+		reason    string
 		cmd       string
+		ramps     []string
+		drains    []string
 		logging   string
 	}{
 		// 0
@@ -43,18 +46,21 @@ func TestDynUpdate(t *testing.T) {
 			oldConfig: nil,
 			curConfig: nil,
 			dynamic:   false,
+			reason:    "initial",
 		},
 		// 1
 		{
 			oldConfig: nil,
 			curConfig: &config{},
 			dynamic:   false,
+			reason:    "initial",
 		},
 		// 2
 		{
 			oldConfig: &config{},
 			curConfig: nil,
 			dynamic:   false,
+			reason:    "initial",
 		},
 		// 3
 		{
@@ -69,6 +75,7 @@ func TestDynUpdate(t *testing.T) {
 				global: hatypes.Global{MaxConn: 1},
 			},
 			dynamic: false,
+			reason:  "global",
 			logging: `INFO-V(2) diff outside backends - [global]`,
 		},
 		// 5
@@ -95,6 +102,7 @@ func TestDynUpdate(t *testing.T) {
 				"srv008:127.0.0.1:1023:1",
 			},
 			dynamic: false,
+			reason:  "endpoints-added",
 			logging: `INFO-V(2) added endpoints on backend 'default_app_8080'`,
 		},
 		// 6
@@ -312,6 +320,7 @@ INFO-V(2) disabled endpoint '172.17.0.4:8080' on backend/server 'default_app_808
 				"srv007:127.0.0.1:1023:1",
 			},
 			dynamic: false,
+			reason:  "endpoints-added",
 			logging: `INFO-V(2) added endpoints on backend 'default_app_8080'`,
 		},
 		// 13
@@ -365,6 +374,7 @@ set server default_app_8080/srv002 weight 1
 				"srv002:172.17.0.3:8080:1",
 			},
 			dynamic: false,
+			reason:  "endpoints-added",
 			cmd:     ``,
 			logging: `INFO-V(2) added endpoints on backend 'default_app_8080'`,
 		},
@@ -377,6 +387,7 @@ set server default_app_8080/srv002 weight 1
 				"srv001:127.0.0.1:1023:1",
 			},
 			dynamic: false,
+			reason:  "initial",
 			cmd:     ``,
 			logging: ``,
 		},
@@ -394,6 +405,7 @@ set server default_app_8080/srv002 weight 1
 				"srv004:127.0.0.1:1023:1",
 			},
 			dynamic: false,
+			reason:  "initial",
 			cmd:     ``,
 			logging: ``,
 		},
@@ -414,6 +426,7 @@ set server default_app_8080/srv002 weight 1
 				"srv001:172.17.0.3:8080:1",
 			},
 			dynamic: false,
+			reason:  "dynamic-scaling-disabled",
 			logging: `INFO-V(2) backend 'default_app_8080' changed and its dynamic-scaling is 'false'`,
 		},
 		// 18
@@ -455,6 +468,385 @@ set server default_app_8080/srv002 weight 1
 			},
 			dynamic: true,
 		},
+		// 20
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+			},
+			dynamic: false,
+			reason:  "endpoints-backup",
+			cmd: `
+set server default_app_8080/srv002 state maint
+set server default_app_8080/srv002 addr 127.0.0.1 port 1023
+set server default_app_8080/srv002 weight 0
+`,
+			logging: `
+INFO-V(2) disabled endpoint '172.17.0.3:8080' on backend/server 'default_app_8080/srv002'
+INFO-V(2) backup state of endpoint '172.17.0.3:8080' differs from the empty slots on backend 'default_app_8080'`,
+		},
+		// 21
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.4", 8080, "").Backup = true
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.4:8080:1",
+				"srv003:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv002 state maint
+set server default_app_8080/srv002 addr 127.0.0.1 port 1023
+set server default_app_8080/srv002 weight 0
+set server default_app_8080/srv002 addr 172.17.0.4 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1
+`,
+			logging: `
+INFO-V(2) disabled endpoint '172.17.0.3:8080' on backend/server 'default_app_8080/srv002'
+INFO-V(2) added endpoint '172.17.0.4:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 22
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+			},
+			dynamic: false,
+			reason:  "endpoints-backup",
+			logging: `INFO-V(2) backup state of endpoint '172.17.0.3:8080' differs from the empty slots on backend 'default_app_8080'`,
+		},
+		// 23
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Server.SlowStart = "20s"
+				b.AcquireEndpoint("172.17.0.2", 8080, "").Weight = 0
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Server.SlowStart = "20s"
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "").Weight = 50
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Weight = 100
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:50",
+				"srv002:172.17.0.3:8080:100",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv001 addr 172.17.0.2 port 8080
+set server default_app_8080/srv001 state ready
+set server default_app_8080/srv001 weight 5
+set server default_app_8080/srv002 addr 172.17.0.3 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 10
+`,
+			ramps: []string{"default_app_8080/srv001:50:20s", "default_app_8080/srv002:100:20s"},
+			logging: `
+INFO-V(2) updated endpoint '172.17.0.2:8080' weight '5' state 'ready' on backend/server 'default_app_8080/srv001'
+INFO-V(2) added endpoint '172.17.0.3:8080' weight '10' state 'ready' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 24
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.DrainTimeout = "30s"
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv002:172.17.0.3:8080:1",
+				"srv001:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv001 state drain
+`,
+			drains:  []string{"default_app_8080/srv001:172.17.0.2:8080:30s"},
+			logging: `INFO-V(2) draining endpoint '172.17.0.2:8080' on backend/server 'default_app_8080/srv001'`,
+		},
+		// 25
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AddEmptyEndpoint()
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				c.instance.(*instance).drainer.running["default_app_8080/srv001"] = &endpointDrain{target: "172.17.0.8:8080"}
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+			},
+			dynamic: false,
+			reason:  "endpoints-draining",
+			logging: `INFO-V(2) empty slots of backend 'default_app_8080' are draining, cannot add endpoint '172.17.0.2:8080'`,
+		},
+		// 26
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AddEmptyEndpoint()
+				b.AddEmptyEndpoint()
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				c.instance.(*instance).drainer.running["default_app_8080/srv001"] = &endpointDrain{target: "172.17.0.8:8080"}
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv002:172.17.0.2:8080:1",
+				"srv003:172.17.0.3:8080:1",
+				"srv001:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.2 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1
+`,
+			logging: `INFO-V(2) added endpoint '172.17.0.2:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 27
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.MinFreeSlots = 1
+				b.Dynamic.MinSlots = 4
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:127.0.0.1:1023:1",
+				"srv003:127.0.0.1:1023:1",
+				"srv004:127.0.0.1:1023:1",
+			},
+			dynamic: false,
+			reason:  "slots-resized",
+			logging: `INFO-V(2) backend 'default_app_8080' has less than 4 slots`,
+		},
+		// 28
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AddEmptyEndpoint()
+				b.AddEmptyEndpoint()
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.MinFreeSlots = 1
+				b.Dynamic.MinSlots = 4
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+				"srv003:127.0.0.1:1023:1",
+				"srv004:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.3 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1
+`,
+			logging: `INFO-V(2) added endpoint '172.17.0.3:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 29
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AddEmptyEndpoint()
+				b.AddEmptyEndpoint().Backup = true
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.BackupSlots = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv003:172.17.0.3:8080:1",
+				"srv002:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv003 addr 172.17.0.3 port 8080
+set server default_app_8080/srv003 state ready
+set server default_app_8080/srv003 weight 1
+`,
+			logging: `INFO-V(2) added endpoint '172.17.0.3:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv003'`,
+		},
+		// 30
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+				b.AddEmptyEndpoint().Backup = true
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.BackupSlots = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv003:172.17.0.3:8080:1",
+				"srv002:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv002 state maint
+set server default_app_8080/srv002 addr 127.0.0.1 port 1023
+set server default_app_8080/srv002 weight 0
+set server default_app_8080/srv003 addr 172.17.0.3 port 8080
+set server default_app_8080/srv003 state ready
+set server default_app_8080/srv003 weight 1
+`,
+			logging: `
+INFO-V(2) disabled endpoint '172.17.0.3:8080' on backend/server 'default_app_8080/srv002'
+INFO-V(2) added endpoint '172.17.0.3:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv003'`,
+		},
+		// 31
+		{
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.BackupSlots = true
+				b.Dynamic.BlockSize = 2
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Backup = true
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+				"srv003:127.0.0.1:1023:1",
+				"srv004:127.0.0.1:1023:1",
+			},
+			dynamic: false,
+			reason:  "initial",
+		},
+		// 32
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AddEmptyEndpoint()
+				b.AddEmptyEndpoint()
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				c.instance.(*instance).drainer.running["default_app_8080/srv001"] = &endpointDrain{target: "172.17.0.8:8080"}
+				c.instance.(*instance).drainer.running["default_app_8080/srv002"] = &endpointDrain{target: "172.17.0.9:8080"}
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.4", 8080, "").Backup = true
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.4:8080:1",
+				"srv003:172.17.0.3:8080:1",
+			},
+			dynamic: false,
+			reason:  "endpoints-draining",
+			logging: `
+INFO-V(2) empty slots of backend 'default_app_8080' are draining, cannot add endpoint '172.17.0.2:8080'
+INFO-V(2) backup state of endpoint '172.17.0.4:8080' differs from the empty slots on backend 'default_app_8080'`,
+		},
+		// 33
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.AddEmptyEndpoint()
+				b.AddEmptyEndpoint()
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				c.instance.(*instance).drainer.running["default_app_8080/srv002"] = &endpointDrain{target: "172.17.0.2:8080"}
+				b := c.config.AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv002:172.17.0.2:8080:1",
+				"srv003:172.17.0.3:8080:1",
+				"srv001:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.2 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1
+`,
+			logging: `INFO-V(2) added endpoint '172.17.0.2:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'`,
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -492,6 +884,218 @@ set server default_app_8080/srv002 weight 1
 		if dynamic != test.dynamic {
 			t.Errorf("dynamic expected as '%t' on %d, but was '%t'", test.dynamic, i, dynamic)
 		}
+		if dynUpdater.reason != test.reason {
+			t.Errorf("reason expected as '%s' on %d, but was '%s'", test.reason, i, dynUpdater.reason)
+		}
+		cmd = strings.TrimSpace(cmd)
+		test.cmd = strings.TrimSpace(test.cmd)
+		if cmd != test.cmd {
+			t.Errorf("cmd differs on %d:\n%s", i, diff.Diff(test.cmd, cmd))
+		}
+		var ramps []string
+		for _, ramp := range dynUpdater.ramps {
+			ramps = append(ramps, fmt.Sprintf("%s/%s:%d:%v", ramp.backname, ramp.server, ramp.weight, ramp.duration))
+		}
+		if !reflect.DeepEqual(ramps, test.ramps) {
+			t.Errorf("ramps expected and actual differs on %d -- expected: %v -- actual: %v", i, test.ramps, ramps)
+		}
+		var drains []string
+		for _, drain := range dynUpdater.drains {
+			drains = append(drains, fmt.Sprintf("%s/%s:%s:%v", drain.backname, drain.server, drain.target, drain.timeout))
+		}
+		if !reflect.DeepEqual(drains, test.drains) {
+			t.Errorf("drains expected and actual differs on %d -- expected: %v -- actual: %v", i, test.drains, drains)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestDynUpdateTCP(t *testing.T) {
+	testCases := []struct {
+		doconfig1 func(c *testConfig)
+		doconfig2 func(c *testConfig)
+		expected  []string
+		dynamic   bool
+		reason    string
+		cmd       string
+		logging   string
+	}{
+		// 0
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv002:172.17.0.3:5432:1",
+				"srv001:127.0.0.1:1023:0",
+			},
+			dynamic: true,
+			cmd: `
+set server _tcp_default_pq_5432/srv001 state maint
+set server _tcp_default_pq_5432/srv001 addr 127.0.0.1 port 1023
+set server _tcp_default_pq_5432/srv001 weight 0
+`,
+			logging: `INFO-V(2) disabled endpoint '172.17.0.2:5432' on backend/server '_tcp_default_pq_5432/srv001'`,
+		},
+		// 1
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.2:5432:1",
+				"srv002:172.17.0.3:5432:1",
+			},
+			dynamic: true,
+			cmd: `
+set server _tcp_default_pq_5432/srv002 addr 172.17.0.3 port 5432
+set server _tcp_default_pq_5432/srv002 state ready
+set server _tcp_default_pq_5432/srv002 weight 1
+`,
+			logging: `INFO-V(2) added endpoint '172.17.0.3:5432' weight '1' state 'ready' on backend/server '_tcp_default_pq_5432/srv002'`,
+		},
+		// 2
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("172.17.0.2", 5432).Weight = 0
+			},
+			expected: []string{
+				"srv001:172.17.0.2:5432:0",
+			},
+			dynamic: true,
+			cmd: `
+set server _tcp_default_pq_5432/srv001 addr 172.17.0.2 port 5432
+set server _tcp_default_pq_5432/srv001 state drain
+set server _tcp_default_pq_5432/srv001 weight 0
+`,
+			logging: `INFO-V(2) updated endpoint '172.17.0.2:5432' weight '0' state 'drain' on backend/server '_tcp_default_pq_5432/srv001'`,
+		},
+		// 3
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.3:5432:1",
+			},
+			dynamic: false,
+			reason:  "dynamic-scaling-disabled",
+			logging: `INFO-V(2) backend '_tcp_default_pq_5432' changed and its dynamic-scaling is 'false'`,
+		},
+		// 4
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.Dynamic.DynUpdate = true
+				b.CheckInterval = "2s"
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.2:5432:1",
+			},
+			dynamic: false,
+			reason:  "backend-config",
+			logging: `INFO-V(2) diff outside endpoints of TCP service '_tcp_default_pq_5432'`,
+		},
+		// 5
+		{
+			doconfig1: func(c *testConfig) {
+				c.config.AcquireTCPBackend("default_pq", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				c.config.AcquireTCPBackend("default_pq", 5432)
+				c.config.AcquireTCPBackend("default_pq2", 5433)
+			},
+			dynamic: false,
+			reason:  "tcp-backends",
+			logging: `INFO-V(2) added or removed TCP service(s)`,
+		},
+		// 6
+		{
+			doconfig2: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("default_pq", 5432)
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.BlockSize = 4
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.2:5432:1",
+				"srv002:127.0.0.1:1023:0",
+				"srv003:127.0.0.1:1023:0",
+				"srv004:127.0.0.1:1023:0",
+			},
+			dynamic: false,
+			reason:  "initial",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		instance := c.instance.(*instance)
+		var oldConfig *config
+		if test.doconfig1 != nil {
+			test.doconfig1(c)
+			oldConfig = c.config.(*config)
+			instance.clearConfig()
+			c.config = c.newConfig()
+			instance.curConfig = c.config
+		}
+		test.doconfig2(c)
+		var cmd string
+		dynUpdater := instance.newDynUpdater()
+		dynUpdater.old = oldConfig
+		dynUpdater.cur = c.config.(*config)
+		dynUpdater.cmd = func(socket string, command ...string) ([]string, error) {
+			for _, c := range command {
+				cmd = cmd + c + "\n"
+			}
+			return []string{}, nil
+		}
+		dynamic := dynUpdater.update()
+		var actual []string
+		for _, ep := range c.config.AcquireTCPBackend("default_pq", 5432).Endpoints {
+			actual = append(actual, fmt.Sprintf("%s:%s:%d:%d", ep.Name, ep.IP, ep.Port, ep.Weight))
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("endpoints expected and actual differs on %d -- expected: %v -- actual: %v",
+				i, test.expected, actual)
+		}
+		if dynamic != test.dynamic {
+			t.Errorf("dynamic expected as '%t' on %d, but was '%t'", test.dynamic, i, dynamic)
+		}
+		if dynUpdater.reason != test.reason {
+			t.Errorf("reason expected as '%s' on %d, but was '%s'", test.reason, i, dynUpdater.reason)
+		}
 		cmd = strings.TrimSpace(cmd)
 		test.cmd = strings.TrimSpace(test.cmd)
 		if cmd != test.cmd {
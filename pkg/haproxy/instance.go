@@ -19,6 +19,7 @@ package haproxy
 import (
 	"fmt"
 	"os/exec"
+	"sync"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/template"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
@@ -31,6 +32,7 @@ type InstanceOptions struct {
 	MaxOldConfigFiles int
 	HAProxyCmd        string
 	HAProxyConfigFile string
+	Metrics           Metrics
 	ReloadCmd         string
 	ReloadStrategy    string
 	SortBackends      bool
@@ -41,28 +43,58 @@ type InstanceOptions struct {
 type Instance interface {
 	ParseTemplates() error
 	Config() Config
+	Stats() (*Stats, error)
 	Update(timer *utils.Timer)
 }
 
+// Metrics ...
+type Metrics interface {
+	IncUpdateNoop()
+	IncUpdateDynamic(commands int)
+	IncUpdateReload(reason string)
+	IncReloadError()
+	IncValidationError()
+	SetConfigObjects(hosts, backends, tcpServices, endpoints int)
+}
+
 // CreateInstance ...
-func CreateInstance(logger types.Logger, bindUtils hatypes.BindUtils, options InstanceOptions) Instance {
+func CreateInstance(logger types.Logger, options InstanceOptions) Instance {
+	metrics := options.Metrics
+	if metrics == nil {
+		metrics = &nullMetrics{}
+	}
 	return &instance{
 		logger:       logger,
-		bindUtils:    bindUtils,
 		options:      &options,
+		metrics:      metrics,
 		templates:    template.CreateConfig(),
 		mapsTemplate: template.CreateConfig(),
 		mapsDir:      "/etc/haproxy/maps",
+		ramper:       newWeightRamper(logger),
+		drainer:      newEndpointDrainer(logger),
 	}
 }
 
+// nullMetrics is used if the instance is created without metrics
+type nullMetrics struct{}
+
+func (*nullMetrics) IncUpdateNoop()                                               {}
+func (*nullMetrics) IncUpdateDynamic(commands int)                                {}
+func (*nullMetrics) IncUpdateReload(reason string)                                {}
+func (*nullMetrics) IncReloadError()                                              {}
+func (*nullMetrics) IncValidationError()                                          {}
+func (*nullMetrics) SetConfigObjects(hosts, backends, tcpServices, endpoints int) {}
+
 type instance struct {
 	logger       types.Logger
-	bindUtils    hatypes.BindUtils
 	options      *InstanceOptions
+	metrics      Metrics
 	templates    *template.Config
 	mapsTemplate *template.Config
 	mapsDir      string
+	ramper       *weightRamper
+	drainer      *endpointDrainer
+	configMutex  sync.Mutex
 	oldConfig    Config
 	curConfig    Config
 }
@@ -100,7 +132,8 @@ func (i *instance) ParseTemplates() error {
 
 func (i *instance) Config() Config {
 	if i.curConfig == nil {
-		config := createConfig(i.bindUtils, options{
+		config := createConfig(options{
+			logger:       i.logger,
 			mapsTemplate: i.mapsTemplate,
 			mapsDir:      i.mapsDir,
 		})
@@ -131,8 +164,10 @@ func (i *instance) Update(timer *utils.Timer) {
 		i.clearConfig()
 		return
 	}
+	i.updateConfigMetrics()
 	if i.curConfig.Equals(i.oldConfig) {
 		i.logger.InfoV(2, "old and new configurations match, skipping reload")
+		i.metrics.IncUpdateNoop()
 		i.clearConfig()
 		return
 	}
@@ -155,29 +190,66 @@ func (i *instance) Update(timer *utils.Timer) {
 			return
 		}
 	}
+	socket := i.curConfig.Global().AdminSocket
 	i.clearConfig()
 	if updated {
+		i.ramper.start(socket, updater.ramps)
+		i.drainer.start(socket, updater.drains)
 		if updater.cmdCnt > 0 {
 			if i.options.ValidateConfig {
 				if err := i.check(); err != nil {
 					i.logger.Error("error validating config file:\n%v", err)
+					i.metrics.IncValidationError()
 				}
 				timer.Tick("validate")
 			}
 			i.logger.Info("HAProxy updated without needing to reload. Commands sent: %d", updater.cmdCnt)
+			i.metrics.IncUpdateDynamic(updater.cmdCnt)
 		} else {
 			i.logger.Info("old and new configurations match")
+			i.metrics.IncUpdateNoop()
 		}
 		return
 	}
+	// the weight of all the servers is reset on reloads, and
+	// the old instance finishes the connections of draining servers
+	i.ramper.stopAll()
+	i.drainer.stopAll()
+	i.metrics.IncUpdateReload(updater.reason)
 	if err := i.reload(); err != nil {
 		i.logger.Error("error reloading server:\n%v", err)
+		i.metrics.IncReloadError()
 		return
 	}
 	timer.Tick("reload")
 	i.logger.Info("HAProxy successfully reloaded")
 }
 
+func (i *instance) updateConfigMetrics() {
+	countEndpoints := func(endpoints []*hatypes.Endpoint) int {
+		count := 0
+		for _, ep := range endpoints {
+			if !ep.IsEmpty() {
+				count++
+			}
+		}
+		return count
+	}
+	endpoints := 0
+	for _, backend := range i.curConfig.Backends() {
+		endpoints += countEndpoints(backend.Endpoints)
+	}
+	for _, backend := range i.curConfig.TCPBackends() {
+		endpoints += countEndpoints(backend.Endpoints)
+	}
+	i.metrics.SetConfigObjects(
+		len(i.curConfig.Hosts()),
+		len(i.curConfig.Backends()),
+		len(i.curConfig.TCPBackends()),
+		endpoints,
+	)
+}
+
 func (i *instance) check() error {
 	if i.options.HAProxyCmd == "" {
 		i.logger.Info("(test) check was skipped")
@@ -209,6 +281,8 @@ func (i *instance) reload() error {
 
 func (i *instance) clearConfig() {
 	// TODO releaseConfig (old support files, ...)
+	i.configMutex.Lock()
 	i.oldConfig = i.curConfig
+	i.configMutex.Unlock()
 	i.curConfig = nil
 }
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -27,7 +28,6 @@ import (
 	"github.com/kylelemons/godebug/diff"
 	yaml "gopkg.in/yaml.v2"
 
-	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
@@ -333,6 +333,85 @@ func TestBackends(t *testing.T) {
     option httpchk /check`,
 			srvsuffix: "check port 4000",
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.Method = "GET"
+				b.HealthCheck.URI = "/health"
+				b.HealthCheck.Host = "app.local"
+				b.HealthCheck.ExpectStatus = "^(200|3[0-9][0-9])$"
+			},
+			expected: `
+    option httpchk GET /health HTTP/1.1\r\nHost:\ app.local
+    http-check expect rstatus ^(200|3[0-9][0-9])$`,
+			srvsuffix: "check inter 2s",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.URI = "/"
+				b.HealthCheck.ExpectBody = "status:\\ ok"
+			},
+			expected: `
+    option httpchk /
+    http-check expect string status:\ ok`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.Protocol = "https"
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.Port = 8443
+				b.HealthCheck.SSL = true
+				b.HealthCheck.SNI = "app.local"
+			},
+			srvsuffix: "ssl verify none check port 8443 inter 2s check-ssl check-sni app.local",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.SSL = true
+				b.HealthCheck.SNI = "app.local"
+			},
+			srvsuffix: "check inter 2s",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.HealthCheck.Interval = "2s"
+				b.HealthCheck.DownInterval = "30s"
+				b.Server.Observe = "layer7"
+				b.Server.ErrorLimit = 5
+				b.Server.OnError = "mark-down"
+			},
+			srvsuffix: "check inter 2s downinter 30s observe layer7 error-limit 5 on-error mark-down",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.Observe = "layer7"
+				b.Server.ErrorLimit = 5
+				b.Server.OnError = "mark-down"
+			},
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.SlowStart = "30s"
+			},
+			srvsuffix: "slowstart 30s",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Retry.Count = "0"
+			},
+			expected: `
+    retries 0`,
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Retry.Count = "5"
+				b.Retry.Redispatch = "-2"
+			},
+			expected: `
+    retries 5
+    option redispatch -2`,
+		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.AgentCheck.Port = 8000
@@ -405,6 +484,16 @@ func TestBackends(t *testing.T) {
 			},
 			srvsuffix: "send-proxy-v2",
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Endpoints = append([]*hatypes.Endpoint{{
+					Name: "s0", IP: "172.17.0.10", Port: 8080, Enabled: true, Backup: true, Weight: 100,
+				}}, b.Endpoints...)
+			},
+			expected: `
+    option allbackups
+    server s0 172.17.0.10:8080 backup weight 100`,
+		},
 	}
 	for _, test := range testCases {
 		c := setup(t)
@@ -483,6 +572,73 @@ backend d1_app_8080
 	c.logger.CompareLogging(defaultLogging)
 }
 
+func TestInstanceMetrics(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	instance := c.instance.(*instance)
+	doconfig := func(maxconn int) {
+		c.config.Global().MaxConn = maxconn
+		b := c.config.AcquireBackend("d1", "app", "8080")
+		b.AcquireEndpoint("172.17.0.11", 8080, "")
+		b.AddEmptyEndpoint()
+		h := c.config.AcquireHost("d1.local")
+		h.AddPath(b, "/")
+		tb := c.config.AcquireTCPBackend("pq", 5432)
+		tb.AddEndpoint("172.17.0.21", 5432)
+		tb.AddEndpoint("172.17.0.22", 5432)
+	}
+	renew := func() {
+		c.config = c.newConfig()
+		instance.curConfig = c.config
+	}
+
+	doconfig(2000)
+	c.Update()
+	renew()
+	doconfig(2000)
+	c.Update()
+	renew()
+	doconfig(4000)
+	c.Update()
+
+	expUpdates := []string{
+		"reload reason=initial",
+		"noop",
+		"reload reason=global",
+	}
+	if !reflect.DeepEqual(c.metrics.Updates, expUpdates) {
+		t.Errorf("updates differ - expected: %v - actual: %v", expUpdates, c.metrics.Updates)
+	}
+	expObjects := "hosts=1 backends=1 tcpServices=1 endpoints=3"
+	if c.metrics.ConfigObjects != expObjects {
+		t.Errorf("config objects differ - expected: %s - actual: %s", expObjects, c.metrics.ConfigObjects)
+	}
+	c.logger.CompareLogging(defaultLogging + `
+INFO-V(2) old and new configurations match, skipping reload
+INFO-V(2) diff outside backends - [global]` + defaultLogging)
+}
+
+func TestInstanceNoMetrics(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	// metrics of an instance created without metrics
+	metrics := CreateInstance(c.logger, InstanceOptions{}).(*instance).metrics
+	c.instance.(*instance).metrics = metrics
+
+	b := c.config.AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h := c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+
+	c.Update()
+	if len(c.metrics.Updates) > 0 {
+		t.Errorf("expected no updates on the metrics mock, but found: %v", c.metrics.Updates)
+	}
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestInstanceGlobalBind(t *testing.T) {
 	testCases := []struct {
 		bind          hatypes.GlobalBindConfig
@@ -841,6 +997,7 @@ func TestInstanceTCPBackend(t *testing.T) {
 	testCases := []struct {
 		doconfig func(c *testConfig)
 		expected string
+		expMaps  map[string]string
 		logging  string
 	}{
 		// 0
@@ -853,7 +1010,7 @@ func TestInstanceTCPBackend(t *testing.T) {
 listen _tcp_postgresql_5432
     bind :5432
     mode tcp
-    server srv001 172.17.0.2:5432`,
+    server srv001 172.17.0.2:5432 weight 1`,
 		},
 		// 1
 		{
@@ -867,8 +1024,8 @@ listen _tcp_postgresql_5432
 listen _tcp_pq_5432
     bind :5432
     mode tcp
-    server srv001 172.17.0.2:5432 check port 5432 inter 2s
-    server srv002 172.17.0.3:5432 check port 5432 inter 2s`,
+    server srv001 172.17.0.2:5432 weight 1 check inter 2s
+    server srv002 172.17.0.3:5432 weight 1 check inter 2s`,
 		},
 		// 2
 		{
@@ -882,7 +1039,7 @@ listen _tcp_pq_5432
 listen _tcp_pq_5432
     bind :5432 ssl crt /var/haproxy/ssl/pq.pem
     mode tcp
-    server srv001 172.17.0.2:5432 send-proxy-v2`,
+    server srv001 172.17.0.2:5432 weight 1 send-proxy-v2`,
 		},
 		// 3
 		{
@@ -899,7 +1056,237 @@ listen _tcp_pq_5432
 listen _tcp_pq_5432
     bind 127.0.0.1:5432 ssl crt /var/haproxy/ssl/pq.pem accept-proxy
     mode tcp
-    server srv001 172.17.0.2:5432 check port 5432 inter 2s send-proxy`,
+    server srv001 172.17.0.2:5432 weight 1 check inter 2s send-proxy`,
+		},
+		// 4
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432).Weight = 0
+				b.AddEmptyEndpoint()
+				b.CheckInterval = "2s"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432
+    mode tcp
+    server srv001 172.17.0.2:5432 weight 1 check inter 2s
+    server srv002 172.17.0.3:5432 weight 0 check inter 2s
+    server srv003 127.0.0.1:1023 disabled weight 0 check inter 2s`,
+		},
+		// 5
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.BalanceAlgorithm = "leastconn"
+				b.MaxConnServer = 50
+				b.Timeout.Client = "1m"
+				b.Timeout.Connect = "5s"
+				b.Timeout.Server = "2m"
+				b.Whitelist = []string{"10.0.0.0/8", "192.168.0.0/16"}
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432
+    mode tcp
+    balance leastconn
+    timeout client 1m
+    timeout connect 5s
+    timeout server 2m
+    acl wlist_src src 10.0.0.0/8 192.168.0.0/16
+    tcp-request connection reject if !wlist_src
+    server srv001 172.17.0.2:5432 weight 1 maxconn 50
+    server srv002 172.17.0.3:5432 weight 1 maxconn 50`,
+		},
+		// 6
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("db1", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.SNI = "db1.local"
+				b.ProxyProt.Decode = true
+				b.Timeout.Client = "1m"
+				b.Timeout.Server = "2m"
+				b = c.config.AcquireTCPBackend("db2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.SNI = "*.db2.local"
+				b.ProxyProt.Decode = true
+				b.Timeout.Client = "1m"
+				b.Whitelist = []string{"10.0.0.0/8"}
+				b = c.config.AcquireTCPBackend("pq", 5433)
+				b.AddEndpoint("172.17.0.4", 5432)
+			},
+			expected: `
+frontend _front_tcp_5432
+    bind :5432 accept-proxy
+    mode tcp
+    timeout client 1m
+    tcp-request inspect-delay 5s
+    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map(/etc/haproxy/maps/_front_tcp_5432_sni.map,_nomatch)
+    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map_reg(/etc/haproxy/maps/_front_tcp_5432_sni_regex.map,_nomatch) if { var(req.tcpback) _nomatch }
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend %[var(req.tcpback)] unless { var(req.tcpback) _nomatch }
+backend _tcp_db1_5432
+    mode tcp
+    timeout server 2m
+    server srv001 172.17.0.2:5432 weight 1
+backend _tcp_db2_5432
+    mode tcp
+    acl wlist_src src 10.0.0.0/8
+    tcp-request content reject if !wlist_src
+    server srv001 172.17.0.3:5432 weight 1
+listen _tcp_pq_5433
+    bind :5433
+    mode tcp
+    server srv001 172.17.0.4:5432 weight 1`,
+			expMaps: map[string]string{
+				"_front_tcp_5432_sni.map": `
+db1.local _tcp_db1_5432
+`,
+				"_front_tcp_5432_sni_regex.map": `
+^[^.]+\.db2\.local$ _tcp_db2_5432
+`,
+			},
+		},
+		// 7
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("mqtt", 8883)
+				b.AddEndpoint("172.17.0.2", 1883)
+				b.SSL.Filename = "/var/haproxy/ssl/mqtt.pem"
+				b.SSL.ALPN = "mqtt"
+				b.SSL.Ciphers = "ECDHE-RSA-AES128-GCM-SHA256"
+				b.SSL.MinVersion = "TLSv1.2"
+				b.SSL.CAFilename = "/var/haproxy/ssl/ca.pem"
+				b.SSL.CRLFilename = "/var/haproxy/ssl/ca.crl"
+				b = c.config.AcquireTCPBackend("pq", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.SSL.Filename = "/var/haproxy/ssl/pq.pem"
+				b.SSL.CAFilename = "/var/haproxy/ssl/ca.pem"
+				b.SSL.CAVerifyOptional = true
+				b.ServerSSL.Enabled = true
+				b.ServerSSL.CAFilename = "/var/haproxy/ssl/upstream-ca.pem"
+				b.ServerSSL.CrtFilename = "/var/haproxy/ssl/client.pem"
+				b.ServerSSL.SNI = "pq.internal"
+				b = c.config.AcquireTCPBackend("redis", 6379)
+				b.AddEndpoint("172.17.0.4", 6379)
+				b.ServerSSL.Enabled = true
+			},
+			expected: `
+listen _tcp_mqtt_8883
+    bind :8883 ssl crt /var/haproxy/ssl/mqtt.pem alpn mqtt ciphers ECDHE-RSA-AES128-GCM-SHA256 ssl-min-ver TLSv1.2 ca-file /var/haproxy/ssl/ca.pem verify required crl-file /var/haproxy/ssl/ca.crl
+    mode tcp
+    server srv001 172.17.0.2:1883 weight 1
+listen _tcp_pq_5432
+    bind :5432 ssl crt /var/haproxy/ssl/pq.pem ca-file /var/haproxy/ssl/ca.pem verify optional
+    mode tcp
+    server srv001 172.17.0.3:5432 weight 1 ssl crt /var/haproxy/ssl/client.pem verify required ca-file /var/haproxy/ssl/upstream-ca.pem sni str(pq.internal)
+listen _tcp_redis_6379
+    bind :6379
+    mode tcp
+    server srv001 172.17.0.4:6379 weight 1 ssl verify none`,
+		},
+		// 8
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432).Backup = true
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432
+    mode tcp
+    option allbackups
+    server srv001 172.17.0.2:5432 weight 1
+    server srv002 172.17.0.3:5432 backup weight 1`,
+		},
+		// 9
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.AcquireTCPBackend("db1", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.SNI = "db1.local"
+				b.ProxyProt.Decode = true
+				b = c.config.AcquireTCPBackend("db2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.SNI = "db2.local"
+				b = c.config.AcquireTCPBackend("db3", 5432)
+				b.AddEndpoint("172.17.0.4", 5432)
+				b.SNI = "db3.local"
+				b.ProxyProt.Decode = true
+				b.Timeout.Client = "1m"
+			},
+			expected: `
+frontend _front_tcp_5432
+    bind :5432 accept-proxy
+    mode tcp
+    tcp-request inspect-delay 5s
+    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map(/etc/haproxy/maps/_front_tcp_5432_sni.map,_nomatch)
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend %[var(req.tcpback)] unless { var(req.tcpback) _nomatch }
+backend _tcp_db1_5432
+    mode tcp
+    server srv001 172.17.0.2:5432 weight 1
+backend _tcp_db2_5432
+    mode tcp
+    server srv001 172.17.0.3:5432 weight 1
+backend _tcp_db3_5432
+    mode tcp
+    server srv001 172.17.0.4:5432 weight 1`,
+			expMaps: map[string]string{
+				"_front_tcp_5432_sni.map": `
+db1.local _tcp_db1_5432
+`,
+			},
+			logging: `
+WARN skipping SNI hostname 'db2.local' of TCP backend 'db2': PROXY protocol and client timeout differ from the other backends on public port 5432
+WARN skipping SNI hostname 'db3.local' of TCP backend 'db3': PROXY protocol and client timeout differ from the other backends on public port 5432
+INFO (test) reload was skipped
+INFO HAProxy successfully reloaded`,
+		},
+		// 10
+		{
+			doconfig: func(c *testConfig) {
+				fb := c.config.AcquireBackend("default", "replica", "5432")
+				fb.ModeTCP = true
+				fb.Endpoints = []*hatypes.Endpoint{endpointS21}
+				b := c.config.AcquireTCPBackend("db1", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.SNI = "db1.local"
+				b.Fallback = "default_replica_5432"
+				b = c.config.AcquireTCPBackend("pq", 5433)
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.Fallback = "default_replica_5432"
+			},
+			expected: `
+frontend _front_tcp_5432
+    bind :5432
+    mode tcp
+    tcp-request inspect-delay 5s
+    tcp-request content set-var(req.tcpback) req.ssl_sni,lower,map(/etc/haproxy/maps/_front_tcp_5432_sni.map,_nomatch)
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend default_replica_5432 if { var(req.tcpback) -m str _tcp_db1_5432 } { nbsrv(_tcp_db1_5432) lt 1 }
+    use_backend %[var(req.tcpback)] unless { var(req.tcpback) _nomatch }
+backend _tcp_db1_5432
+    mode tcp
+    server srv001 172.17.0.2:5432 weight 1
+listen _tcp_pq_5433
+    bind :5433
+    mode tcp
+    use_backend default_replica_5432 if { nbsrv(_tcp_pq_5433) lt 1 }
+    server srv001 172.17.0.3:5432 weight 1
+backend default_replica_5432
+    mode tcp
+    server s21 172.17.0.121:8080 weight 100`,
+			expMaps: map[string]string{
+				"_front_tcp_5432_sni.map": `
+db1.local _tcp_db1_5432
+`,
+			},
 		},
 	}
 	for _, test := range testCases {
@@ -916,47 +1303,442 @@ backend _error404
 <<backend-errors>>
 <<frontend-http>>
     default_backend _error404
-<<frontend-https>>
+<<frontend-https>>
+    default_backend _error404
+<<support>>
+`)
+		for mapName, expected := range test.expMaps {
+			c.checkMap(mapName, expected)
+		}
+		logging := test.logging
+		if logging == "" {
+			logging = defaultLogging
+		}
+		c.logger.CompareLogging(logging)
+		c.teardown()
+	}
+}
+
+func TestInstanceDefaultHost(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	def := c.config.AcquireBackend("default", "default-backend", "8080")
+	def.Endpoints = []*hatypes.Endpoint{endpointS0}
+	c.config.ConfigDefaultBackend(def)
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.AcquireBackend("d1", "app", "8080")
+	h = c.config.AcquireHost("*")
+	h.AddPath(b, "/")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	b.SSLRedirect = b.CreateConfigBool(true)
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h.VarNamespace = true
+
+	b = c.config.AcquireBackend("d2", "app", "8080")
+	h = c.config.AcquireHost("d2.local")
+	h.AddPath(b, "/app")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	b.SSLRedirect = b.CreateConfigBool(true)
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h.VarNamespace = true
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend _default_backend
+    mode http
+    server s0 172.17.0.99:8080 weight 100
+<<backend-errors>>
+frontend _front_http
+    mode http
+    bind :80
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request redirect scheme https if { var(req.base),map_beg(/etc/haproxy/maps/_global_https_redir.map,_nomatch) yes }
+    http-request set-var(txn.namespace) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ns.map,-)
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_beg(/etc/haproxy/maps/_global_http_front.map,_nomatch)
+    use_backend %[var(req.backend)] unless { var(req.backend) _nomatch }
+    use_backend d1_app_8080
+    default_backend _default_backend
+frontend _front001
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
+    http-request set-var(txn.namespace) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ns.map,-)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
+    use_backend d1_app_8080
+    default_backend _default_backend
+<<support>>
+`)
+
+	c.checkMap("_global_http_front.map", `
+`)
+	c.checkMap("_global_https_redir.map", `
+d2.local/app yes
+`)
+	c.checkMap("_global_k8s_ns.map", `
+d2.local/app d2
+`)
+	c.checkMap("_front001_host.map", `
+d2.local/app d2_app_8080
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceFallback(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.AcquireBackend("d1", "static", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	b = c.config.AcquireBackend("d1", "app", "8080")
+	h = c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	b.SSLRedirect = b.CreateConfigBool(false)
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Fallback = "d1_static_8080"
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d1_static_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request redirect scheme https if { var(req.base),map_beg(/etc/haproxy/maps/_global_https_redir.map,_nomatch) yes }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_beg(/etc/haproxy/maps/_global_http_front.map,_nomatch)
+    use_backend d1_static_8080 if { var(req.backend) -m str d1_app_8080 } { nbsrv(d1_app_8080) lt 1 }
+    use_backend %[var(req.backend)] unless { var(req.backend) _nomatch }
+    default_backend _error404
+frontend _front001
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
+    http-request set-var(req.hostbackend) base,lower,regsub(:[0-9]+/,/),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
+    <<https-headers>>
+    use_backend d1_static_8080 if { var(req.hostbackend) -m str d1_app_8080 } { nbsrv(d1_app_8080) lt 1 }
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceNoAccessLog(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+
+	b = c.config.AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.AcquireHost("d2.local")
+	h.AddPath(b, "/health")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.NoAccessLog = true
+	h = c.config.AcquireHost("*.d2.local")
+	h.AddPath(b, "/")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.NoAccessLog = true
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request set-var(req.redir) var(req.base),map_beg(/etc/haproxy/maps/_global_https_redir.map,_nomatch)
+    http-request redirect scheme https if { var(req.redir) yes }
+    http-request redirect scheme https if { var(req.redir) _nomatch } { var(req.base),map_reg(/etc/haproxy/maps/_global_https_redir_regex.map,_nomatch) yes }
+    http-request set-log-level silent if { var(req.base),map_beg(/etc/haproxy/maps/_global_no_access_log.map) -m found }
+    http-request set-log-level silent if { var(req.base),map_reg(/etc/haproxy/maps/_global_no_access_log_regex.map) -m found }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_beg(/etc/haproxy/maps/_global_http_front.map,_nomatch)
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_global_http_front_regex.map,_nomatch) if { var(req.backend) _nomatch }
+    use_backend %[var(req.backend)] unless { var(req.backend) _nomatch }
+    default_backend _error404
+frontend _front001
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front001_host_regex.map,_nomatch) if { var(req.hostbackend) _nomatch }
+    http-request set-log-level silent if { var(req.base),map_beg(/etc/haproxy/maps/_global_no_access_log.map) -m found }
+    http-request set-log-level silent if { var(req.base),map_reg(/etc/haproxy/maps/_global_no_access_log_regex.map) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_global_no_access_log.map", `
+d2.local/health silent
+`)
+	c.checkMap("_global_no_access_log_regex.map", `
+^[^.]+\.d2\.local/ silent
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceLogFormat(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	c.config.Global().Syslog.Endpoint = "127.0.0.1:514"
+	c.config.Global().Syslog.Format = "rfc5424"
+	c.config.Global().Syslog.Tag = "ingress"
+	c.config.Global().Syslog.HTTPLogFormat = "%ci:%cp\\ %ft"
+
+	b = c.config.AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+	h.FindPath("/").Ingress = "d1/ing1"
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+
+	b = c.config.AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.AcquireHost("d2.local")
+	h.AddPath(b, "/")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.LogFormat = "%ci:%cp\\ %b\\ %s"
+
+	c.Update()
+	c.checkConfig(`
+global
+    daemon
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners
+    maxconn 2000
+    hard-stop-after 15m
+    log 127.0.0.1:514 format rfc5424 local0
+    log-tag ingress
+    lua-load /usr/local/etc/haproxy/lua/send-response.lua
+    lua-load /usr/local/etc/haproxy/lua/auth-request.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-options no-sslv3
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+listen _front__tls
+    mode tcp
+    bind :443
+    no log
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    ## _front001/_socket001
+    use-server _server_socket001 if { req.ssl_sni -i -f /etc/haproxy/maps/_socket001.list }
+    server _server_socket001 unix@/var/run/_socket001.sock send-proxy-v2 weight 0
+    ## _front002/_socket002
+    use-server _server_socket002 if { req.ssl_sni -i -f /etc/haproxy/maps/_socket002.list }
+    server _server_socket002 unix@/var/run/_socket002.sock send-proxy-v2 weight 0
+    # default backend
+    server _default_server_socket001 unix@/var/run/_socket001.sock send-proxy-v2
+frontend _front_http
+    mode http
+    bind :80
+    log-format %ci:%cp\ %ft
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request redirect scheme https if { var(req.base),map_beg(/etc/haproxy/maps/_global_https_redir.map,_nomatch) yes }
+    http-request set-var(txn.ingress) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ingress.map,-)
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_beg(/etc/haproxy/maps/_global_http_front.map,_nomatch)
+    use_backend %[var(req.backend)] unless { var(req.backend) _nomatch }
+    default_backend _error404
+frontend _front001
+    mode http
+    bind unix@/var/run/_socket001.sock accept-proxy ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
+    log-format %ci:%cp\ %ft
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
+    http-request set-var(txn.ingress) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ingress.map,-)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
+    default_backend _error404
+frontend _front002
+    mode http
+    bind unix@/var/run/_socket002.sock accept-proxy ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
+    log-format %ci:%cp\ %b\ %s
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front002_host.map,_nomatch)
+    http-request set-var(txn.ingress) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ingress.map,-)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
+    default_backend _error404
+<<support>>
+`)
+	c.checkMap("_global_k8s_ingress.map", `
+d1.local/ d1/ing1
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceRequestID(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+	h.RequestID = "x-request-id"
+
+	b = c.config.AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.AcquireHost("d2.local")
+	h.AddPath(b, "/")
+	h.RequestID = "traceparent"
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+
+	c.config.Global().RequestID.Format = "%{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid"
+	c.config.Global().RequestID.TrustedNetworks = []string{"10.0.0.0/8"}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request redirect scheme https if { var(req.base),map_beg(/etc/haproxy/maps/_global_https_redir.map,_nomatch) yes }
+    unique-id-format %{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid
+    http-request set-var(txn.reqidtype) var(req.base),map_beg(/etc/haproxy/maps/_global_request_id.map,-)
+    acl reqid-trusted src 10.0.0.0/8
+    http-request set-header X-Request-ID %[unique-id] if { var(txn.reqidtype) x-request-id } !{ req.hdr(X-Request-ID) -m found }
+    http-request set-header X-Request-ID %[unique-id] if { var(txn.reqidtype) x-request-id } !reqid-trusted
+    http-request set-header traceparent 00-%[unique-id,sha1,bytes(0,16),hex,lower]-%[unique-id,sha1,sha1,bytes(0,8),hex,lower]-01 if { var(txn.reqidtype) traceparent } !{ req.hdr(traceparent) -m found }
+    http-request set-header traceparent 00-%[unique-id,sha1,bytes(0,16),hex,lower]-%[unique-id,sha1,sha1,bytes(0,8),hex,lower]-01 if { var(txn.reqidtype) traceparent } !reqid-trusted
+    http-request set-var(txn.request_id) req.hdr(X-Request-ID) if { var(txn.reqidtype) x-request-id }
+    http-request set-var(txn.request_id) req.hdr(traceparent) if { var(txn.reqidtype) traceparent }
+    http-response set-header X-Request-ID %[var(txn.request_id)] if { var(txn.reqidtype) x-request-id }
+    http-response set-header traceparent %[var(txn.request_id)] if { var(txn.reqidtype) traceparent }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_beg(/etc/haproxy/maps/_global_http_front.map,_nomatch)
+    use_backend %[var(req.backend)] unless { var(req.backend) _nomatch }
+    default_backend _error404
+frontend _front001
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
+    unique-id-format %{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid
+    http-request set-var(txn.reqidtype) var(req.base),map_beg(/etc/haproxy/maps/_global_request_id.map,-)
+    acl reqid-trusted src 10.0.0.0/8
+    http-request set-header X-Request-ID %[unique-id] if { var(txn.reqidtype) x-request-id } !{ req.hdr(X-Request-ID) -m found }
+    http-request set-header X-Request-ID %[unique-id] if { var(txn.reqidtype) x-request-id } !reqid-trusted
+    http-request set-header traceparent 00-%[unique-id,sha1,bytes(0,16),hex,lower]-%[unique-id,sha1,sha1,bytes(0,8),hex,lower]-01 if { var(txn.reqidtype) traceparent } !{ req.hdr(traceparent) -m found }
+    http-request set-header traceparent 00-%[unique-id,sha1,bytes(0,16),hex,lower]-%[unique-id,sha1,sha1,bytes(0,8),hex,lower]-01 if { var(txn.reqidtype) traceparent } !reqid-trusted
+    http-request set-var(txn.request_id) req.hdr(X-Request-ID) if { var(txn.reqidtype) x-request-id }
+    http-request set-var(txn.request_id) req.hdr(traceparent) if { var(txn.reqidtype) traceparent }
+    http-response set-header X-Request-ID %[var(txn.request_id)] if { var(txn.reqidtype) x-request-id }
+    http-response set-header traceparent %[var(txn.request_id)] if { var(txn.reqidtype) traceparent }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
     default_backend _error404
 <<support>>
 `)
-		logging := test.logging
-		if logging == "" {
-			logging = defaultLogging
-		}
-		c.logger.CompareLogging(logging)
-		c.teardown()
-	}
+
+	c.checkMap("_global_request_id.map", `
+d1.local/ x-request-id
+d2.local/ traceparent
+`)
+
+	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceDefaultHost(t *testing.T) {
+func TestInstanceRequestIDNoTrustedNetworks(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	def := c.config.AcquireBackend("default", "default-backend", "8080")
-	def.Endpoints = []*hatypes.Endpoint{endpointS0}
-	c.config.ConfigDefaultBackend(def)
-
 	var h *hatypes.Host
 	var b *hatypes.Backend
 
 	b = c.config.AcquireBackend("d1", "app", "8080")
-	h = c.config.AcquireHost("*")
-	h.AddPath(b, "/")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	b.SSLRedirect = b.CreateConfigBool(true)
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h.VarNamespace = true
+	h = c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+	h.RequestID = "x-request-id"
 
 	b = c.config.AcquireBackend("d2", "app", "8080")
-	h = c.config.AcquireHost("d2.local")
-	h.AddPath(b, "/app")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	b.SSLRedirect = b.CreateConfigBool(true)
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h.VarNamespace = true
+	h = c.config.AcquireHost("d2.local")
+	h.AddPath(b, "/")
+	h.RequestID = "traceparent"
+
+	c.config.Global().RequestID.Format = "%{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid"
 
 	c.Update()
 	c.checkConfig(`
@@ -968,44 +1750,41 @@ backend d1_app_8080
 backend d2_app_8080
     mode http
     server s1 172.17.0.11:8080 weight 100
-backend _default_backend
-    mode http
-    server s0 172.17.0.99:8080 weight 100
-<<backend-errors>>
+<<backends-default>>
 frontend _front_http
     mode http
     bind :80
     http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
     http-request redirect scheme https if { var(req.base),map_beg(/etc/haproxy/maps/_global_https_redir.map,_nomatch) yes }
-    http-request set-var(txn.namespace) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ns.map,-)
+    unique-id-format %{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid
+    http-request set-var(txn.reqidtype) var(req.base),map_beg(/etc/haproxy/maps/_global_request_id.map,-)
+    http-request set-header X-Request-ID %[unique-id] if { var(txn.reqidtype) x-request-id }
+    http-request set-header traceparent 00-%[unique-id,sha1,bytes(0,16),hex,lower]-%[unique-id,sha1,sha1,bytes(0,8),hex,lower]-01 if { var(txn.reqidtype) traceparent }
+    http-request set-var(txn.request_id) req.hdr(X-Request-ID) if { var(txn.reqidtype) x-request-id }
+    http-request set-var(txn.request_id) req.hdr(traceparent) if { var(txn.reqidtype) traceparent }
+    http-response set-header X-Request-ID %[var(txn.request_id)] if { var(txn.reqidtype) x-request-id }
+    http-response set-header traceparent %[var(txn.request_id)] if { var(txn.reqidtype) traceparent }
     <<http-headers>>
     http-request set-var(req.backend) var(req.base),map_beg(/etc/haproxy/maps/_global_http_front.map,_nomatch)
     use_backend %[var(req.backend)] unless { var(req.backend) _nomatch }
-    use_backend d1_app_8080
-    default_backend _default_backend
+    default_backend _error404
 frontend _front001
     mode http
     bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
     http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
     http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
-    http-request set-var(txn.namespace) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ns.map,-)
+    unique-id-format %{+X}o%ci:%cp_%fi:%fp_%Ts_%rt:%pid
+    http-request set-var(txn.reqidtype) var(req.base),map_beg(/etc/haproxy/maps/_global_request_id.map,-)
+    http-request set-header X-Request-ID %[unique-id] if { var(txn.reqidtype) x-request-id }
+    http-request set-header traceparent 00-%[unique-id,sha1,bytes(0,16),hex,lower]-%[unique-id,sha1,sha1,bytes(0,8),hex,lower]-01 if { var(txn.reqidtype) traceparent }
+    http-request set-var(txn.request_id) req.hdr(X-Request-ID) if { var(txn.reqidtype) x-request-id }
+    http-request set-var(txn.request_id) req.hdr(traceparent) if { var(txn.reqidtype) traceparent }
+    http-response set-header X-Request-ID %[var(txn.request_id)] if { var(txn.reqidtype) x-request-id }
+    http-response set-header traceparent %[var(txn.request_id)] if { var(txn.reqidtype) traceparent }
     <<https-headers>>
     use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
-    use_backend d1_app_8080
-    default_backend _default_backend
+    default_backend _error404
 <<support>>
-`)
-
-	c.checkMap("_global_http_front.map", `
-`)
-	c.checkMap("_global_https_redir.map", `
-d2.local/app yes
-`)
-	c.checkMap("_global_k8s_ns.map", `
-d2.local/app d2
-`)
-	c.checkMap("_front001_host.map", `
-d2.local/app d2_app_8080
 `)
 
 	c.logger.CompareLogging(defaultLogging)
@@ -1122,6 +1901,8 @@ func TestInstanceSingleFrontendSingleBind(t *testing.T) {
 	h.VarNamespace = true
 	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d1.pem"
 	h.TLS.TLSHash = "1"
+	h.TLS.AddTLSExtraCert("/var/haproxy/ssl/certs/d1-ecdsa.pem", "3")
+	h.TLS.AddTLSExtraCert("/var/haproxy/ssl/certs/d1.pem", "1")
 
 	b = c.config.AcquireBackend("d2", "app", "8080")
 	h = c.config.AcquireHost("d2.local")
@@ -1157,7 +1938,7 @@ frontend _front_http
     default_backend _default_backend
 frontend _front001
     mode http
-    bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem crt /var/haproxy/certs/_public
+    bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem crt-list /etc/haproxy/maps/_public_crt.list
     http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
     http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
     http-request set-var(txn.namespace) var(req.base),map_beg(/etc/haproxy/maps/_global_k8s_ns.map,-)
@@ -1182,12 +1963,11 @@ d1.local/ d1
 d2.local/app -
 `)
 
-	c.checkCerts(`
-certdirs:
-- dir: /var/haproxy/certs/_public
-  certs:
-  - /var/haproxy/ssl/certs/d1.pem
-  - /var/haproxy/ssl/certs/d2.pem`)
+	c.checkMap("_public_crt.list", `
+/var/haproxy/ssl/certs/d1.pem
+/var/haproxy/ssl/certs/d1-ecdsa.pem
+/var/haproxy/ssl/certs/d2.pem
+`)
 
 	c.logger.CompareLogging(defaultLogging)
 }
@@ -1428,7 +2208,7 @@ frontend _front001
     default_backend _default_backend
 frontend _front002
     mode http
-    bind unix@/var/run/_socket002.sock accept-proxy ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem crt /var/haproxy/certs/_socket002 ca-file /var/haproxy/ssl/ca/d2.local.pem verify optional ca-ignore-err all crt-ignore-err all
+    bind unix@/var/run/_socket002.sock accept-proxy ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem crt-list /etc/haproxy/maps/_socket002_crt.list ca-file /var/haproxy/ssl/ca/d2.local.pem verify optional ca-ignore-err all crt-ignore-err all
     bind unix@/var/run/_socket003.sock accept-proxy ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
     timeout client 2s
     http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
@@ -1513,11 +2293,172 @@ d21.local http://d21.local/error.html
 d22.local http://d22.local/error.html
 `)
 
-	c.checkCerts(`
-certdirs:
-- dir: /var/haproxy/certs/_socket002
-  certs:
-  - /var/haproxy/ssl/certs/d.pem`)
+	c.checkMap("_socket002_crt.list", `
+/var/haproxy/ssl/certs/d.pem
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceAuthTLSPath(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	def := c.config.AcquireBackend("default", "default-backend", "8080")
+	def.Endpoints = []*hatypes.Endpoint{endpointS0}
+	c.config.ConfigDefaultBackend(def)
+
+	b := c.config.AcquireBackend("d", "app", "8080")
+	h := c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+	h.AddPath(b, "/admin")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d1.local.pem"
+	h.TLS.CAHash = "1"
+	h.TLS.CRLFilename = "/var/haproxy/ssl/ca/d1.local.crl.pem"
+	h.TLS.CRLHash = "2"
+	h.TLS.CAVerifyOptional = true
+
+	b.SSLRedirect = b.CreateConfigBool(true)
+	b.AuthTLS = []*hatypes.BackendConfigAuthTLS{
+		{
+			Paths: hatypes.NewBackendPaths(b.FindHostPath("d1.local/")),
+		},
+		{
+			Paths: hatypes.NewBackendPaths(b.FindHostPath("d1.local/admin")),
+			Config: hatypes.AuthTLS{
+				CertRequired: true,
+				MatchCN:      []string{"admin", "John Doe"},
+				MatchOU:      []string{"ops"},
+			},
+		},
+	}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d_app_8080
+    mode http
+    acl local-offload ssl_fc
+    # path01 = d1.local/
+    # path02 = d1.local/admin
+    http-request set-var(txn.pathID) base,lower,map_beg(/etc/haproxy/maps/_back_d_app_8080_idpath.map,_nomatch)
+    http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]
+    http-request set-header X-SSL-Client-DN   %{+Q}[ssl_c_s_dn]
+    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex]
+    http-request deny if { var(txn.pathID) path02 } !{ ssl_c_s_dn(cn) -m str "admin" "John Doe" }
+    http-request deny if { var(txn.pathID) path02 } !{ ssl_c_s_dn(ou) -m str "ops" }
+    server s1 172.17.0.11:8080 weight 100
+backend _default_backend
+    mode http
+    server s0 172.17.0.99:8080 weight 100
+<<backend-errors>>
+listen _front__tls
+    mode tcp
+    bind :443
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    ## _front001/_socket001
+    use-server _server_socket001 if { req.ssl_sni -i -f /etc/haproxy/maps/_socket001.list }
+    server _server_socket001 unix@/var/run/_socket001.sock send-proxy-v2 weight 0
+    # default backend
+    server _default_server_socket002 unix@/var/run/_socket002.sock send-proxy-v2
+<<frontend-http>>
+    default_backend _default_backend
+frontend _front001
+    mode http
+    bind unix@/var/run/_socket001.sock accept-proxy ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem ca-file /var/haproxy/ssl/ca/d1.local.pem crl-file /var/haproxy/ssl/ca/d1.local.crl.pem verify optional ca-ignore-err all crt-ignore-err all
+    bind unix@/var/run/_socket002.sock accept-proxy ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request set-var(req.hostbackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
+    http-request set-var(req.host) hdr(host),lower,regsub(:[0-9]+$,)
+    <<https-headers>>
+    acl tls-has-crt ssl_c_used
+    acl tls-need-crt ssl_fc_sni -i -f /etc/haproxy/maps/_front001_no_crt.list
+    acl tls-need-crt var(req.base),map_beg(/etc/haproxy/maps/_front001_need_crt_paths.map,no) -m str yes
+    acl tls-host-need-crt var(req.host) -i -f /etc/haproxy/maps/_front001_no_crt.list
+    acl tls-has-invalid-crt ssl_c_verify gt 0
+    acl tls-check-crt ssl_fc_sni -i -f /etc/haproxy/maps/_front001_inv_crt.list
+    http-request set-header x-ha-base %[ssl_fc_sni]%[path]
+    http-request set-var(req.snibackend) hdr(x-ha-base),lower,regsub(:[0-9]+/,/),map_beg(/etc/haproxy/maps/_front001_sni.map,_nomatch)
+    http-request set-var(req.snibackend) var(req.base),map_beg(/etc/haproxy/maps/_front001_sni.map,_nomatch) if { var(req.snibackend) _nomatch } !tls-has-crt !tls-host-need-crt
+    http-request set-var(req.tls_nocrt_redir) ssl_fc_sni,lower,map(/etc/haproxy/maps/_front001_no_crt_redir.map,_internal) if !tls-has-crt tls-need-crt
+    http-request set-var(req.tls_invalidcrt_redir) ssl_fc_sni,lower,map(/etc/haproxy/maps/_front001_inv_crt_redir.map,_internal) if tls-has-invalid-crt tls-check-crt
+    use_backend _error496 if { var(req.tls_nocrt_redir) _internal }
+    use_backend _error495 if { var(req.tls_invalidcrt_redir) _internal }
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
+    use_backend %[var(req.snibackend)] unless { var(req.snibackend) _nomatch }
+    default_backend _default_backend
+<<support>>
+`)
+
+	c.checkMap("_front001_no_crt.list", `
+`)
+	c.checkMap("_front001_need_crt_paths.map", `
+d1.local/admin yes
+d1.local/ no
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceTLSPolicy(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.AcquireBackend("d", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	h = c.config.AcquireHost("d1.local")
+	h.AddPath(b, "/")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d.pem"
+	h.TLS.TLSHash = "1"
+
+	h = c.config.AcquireHost("d2.local")
+	h.AddPath(b, "/")
+	h.Alias.AliasName = "legacy.d2.local"
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d.pem"
+	h.TLS.TLSHash = "1"
+	h.TLS.AddTLSExtraCert("/var/haproxy/ssl/certs/d-rsa.pem", "2")
+	h.TLS.ALPN = "http/1.1"
+	h.TLS.MinVersion = "TLSv1.0"
+
+	h = c.config.AcquireHost("d3.local")
+	h.AddPath(b, "/")
+	h.TLS.Ciphers = "ECDHE-RSA-AES128-SHA"
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+frontend _front001
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt /var/haproxy/ssl/certs/default.pem crt-list /etc/haproxy/maps/_public_crt.list
+    http-request set-var(req.hostbackend) base,lower,regsub(:[0-9]+/,/),map_beg(/etc/haproxy/maps/_front001_host.map,_nomatch)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] unless { var(req.hostbackend) _nomatch }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_public_crt.list", `
+/var/haproxy/ssl/certs/d.pem [alpn http/1.1 ssl-min-ver TLSv1.0] d2.local legacy.d2.local
+/var/haproxy/ssl/certs/d-rsa.pem [alpn http/1.1 ssl-min-ver TLSv1.0] d2.local legacy.d2.local
+/var/haproxy/ssl/certs/default.pem [ciphers ECDHE-RSA-AES128-SHA] d3.local
+/var/haproxy/ssl/certs/d.pem
+`)
 
 	c.logger.CompareLogging(defaultLogging)
 }
@@ -1657,6 +2598,70 @@ frontend _front001
 	c.logger.CompareLogging(defaultLogging)
 }
 
+func TestInstanceAcme(t *testing.T) {
+	testCases := []struct {
+		shared   bool
+		expected string
+	}{
+		// 0
+		{
+			shared: false,
+			expected: `
+    use_backend _acme_challenge if acme-challenge`,
+		},
+		// 1
+		{
+			shared: true,
+			expected: `
+    use_backend _acme_challenge if acme-challenge { var(req.backend) _nomatch }`,
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+
+		var h *hatypes.Host
+		var b *hatypes.Backend
+
+		b = c.config.AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h = c.config.AcquireHost("d1.local")
+		h.AddPath(b, "/")
+		acme := &c.config.Global().Acme
+		acme.Enabled = true
+		acme.Prefix = "/.well-known/acme-challenge/"
+		acme.Socket = "/var/run/acme.sock"
+		acme.Shared = test.shared
+
+		c.Update()
+		c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    acl acme-challenge path_beg /.well-known/acme-challenge/
+    http-request set-var(req.base) base,lower,regsub(:[0-9]+/,/)
+    http-request redirect scheme https if !acme-challenge { var(req.base),map_beg(/etc/haproxy/maps/_global_https_redir.map,_nomatch) yes }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_beg(/etc/haproxy/maps/_global_http_front.map,_nomatch)` + test.expected + `
+    use_backend %[var(req.backend)] unless { var(req.backend) _nomatch }
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404
+<<support>>
+backend _acme_challenge
+    mode http
+    server _acme_server unix@/var/run/acme.sock
+`)
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
 func TestInstanceSSLRedirect(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -2626,7 +3631,7 @@ d1.local/ d1_app_8080
 type testConfig struct {
 	t          *testing.T
 	logger     *helper_test.LoggerMock
-	bindUtils  *ha_helper.BindUtilsMock
+	metrics    *helper_test.MetricsMock
 	instance   Instance
 	config     Config
 	tempdir    string
@@ -2640,8 +3645,10 @@ func setup(t *testing.T) *testConfig {
 		t.Errorf("error creating tempdir: %v", err)
 	}
 	configfile := tempdir + "/haproxy.cfg"
-	instance := CreateInstance(logger, &ha_helper.BindUtilsMock{}, InstanceOptions{
+	metrics := helper_test.NewMetricsMock()
+	instance := CreateInstance(logger, InstanceOptions{
 		HAProxyConfigFile: configfile,
+		Metrics:           metrics,
 	}).(*instance)
 	if err := instance.templates.NewTemplate(
 		"haproxy.tmpl",
@@ -2661,8 +3668,8 @@ func setup(t *testing.T) *testConfig {
 	); err != nil {
 		t.Errorf("error parsing map.tmpl: %v", err)
 	}
-	bindUtils := &ha_helper.BindUtilsMock{}
-	config := createConfig(bindUtils, options{
+	config := createConfig(options{
+		logger:       logger,
 		mapsTemplate: instance.mapsTemplate,
 		mapsDir:      tempdir,
 	})
@@ -2671,7 +3678,7 @@ func setup(t *testing.T) *testConfig {
 	c := &testConfig{
 		t:          t,
 		logger:     logger,
-		bindUtils:  bindUtils,
+		metrics:    metrics,
 		instance:   instance,
 		config:     config,
 		tempdir:    tempdir,
@@ -2689,7 +3696,8 @@ func (c *testConfig) teardown() {
 }
 
 func (c *testConfig) newConfig() Config {
-	config := createConfig(c.bindUtils, options{
+	config := createConfig(options{
+		logger:       c.logger,
 		mapsTemplate: c.instance.(*instance).mapsTemplate,
 		mapsDir:      c.tempdir,
 	})
@@ -2910,11 +3918,6 @@ func (c *testConfig) checkMap(mapName, expected string) {
 	c.compareText(mapName, actual, expected)
 }
 
-func (c *testConfig) checkCerts(expected string) {
-	actual := _yamlMarshal(c.bindUtils)
-	c.compareText("certs", actual, expected)
-}
-
 var replaceComments = regexp.MustCompile(`(?m)^[ \t]{0,2}(#.*)?[\r\n]+`)
 
 func (c *testConfig) readConfig(fileName string) string {
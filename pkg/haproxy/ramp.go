@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// rampSteps is the number of weight changes of a ramp, including
+// the initial weight sent by the dynamic updater
+const rampSteps = 10
+
+// weightRamp is the slow start of a server dynamically enabled, eg an empty
+// slot receiving a new endpoint, or a server which was already up and had its
+// weight changed from zero. HAProxy's slowstart doesn't cover servers enabled
+// via the admin socket, so the weight of these servers is increased by the
+// controller.
+type weightRamp struct {
+	backname string
+	server   string
+	weight   int
+	duration time.Duration
+}
+
+func (r *weightRamp) stepWeight(step int) int {
+	weight := r.weight * step / rampSteps
+	if weight < 1 {
+		weight = 1
+	}
+	return weight
+}
+
+type weightRamper struct {
+	logger  types.Logger
+	cmd     func(socket string, commands ...string) ([]string, error)
+	mutex   sync.Mutex
+	running map[string]chan struct{}
+}
+
+func newWeightRamper(logger types.Logger) *weightRamper {
+	return &weightRamper{
+		logger:  logger,
+		cmd:     utils.HAProxyCommand,
+		running: map[string]chan struct{}{},
+	}
+}
+
+// start increases the weight of the servers in the background,
+// the first step should be already applied.
+func (r *weightRamper) start(socket string, ramps []*weightRamp) {
+	for _, ramp := range ramps {
+		key := ramp.backname + "/" + ramp.server
+		stopCh := make(chan struct{})
+		r.mutex.Lock()
+		if running, found := r.running[key]; found {
+			close(running)
+		}
+		r.running[key] = stopCh
+		r.mutex.Unlock()
+		go r.run(socket, ramp, stopCh)
+	}
+}
+
+func (r *weightRamper) run(socket string, ramp *weightRamp, stopCh chan struct{}) {
+	key := ramp.backname + "/" + ramp.server
+	defer func() {
+		r.mutex.Lock()
+		if r.running[key] == stopCh {
+			delete(r.running, key)
+		}
+		r.mutex.Unlock()
+	}()
+	interval := ramp.duration / rampSteps
+	for step := 2; step <= rampSteps; step++ {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(interval):
+		}
+		if !r.step(socket, ramp, step, stopCh) {
+			return
+		}
+	}
+}
+
+// step sends the weight of a step, unless the ramp was stopped. The
+// mutex is held so a stale weight is never sent after stop returns,
+// eg when the slot was already reassigned by the dynamic updater.
+func (r *weightRamper) step(socket string, ramp *weightRamp, step int, stopCh chan struct{}) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	select {
+	case <-stopCh:
+		return false
+	default:
+	}
+	key := ramp.backname + "/" + ramp.server
+	weight := ramp.stepWeight(step)
+	cmd := fmt.Sprintf("set server %s weight %d", key, weight)
+	if _, err := r.cmd(socket, cmd); err != nil {
+		r.logger.Error("error changing weight of server '%s': %v", key, err)
+		return false
+	}
+	r.logger.InfoV(2, "changed weight of server '%s' to %d of %d", key, weight, ramp.weight)
+	return true
+}
+
+// stop cancels the ramp of a server, the weight is left as is.
+// No other weight of the ramp is sent after stop returns.
+func (r *weightRamper) stop(backname, server string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := backname + "/" + server
+	if running, found := r.running[key]; found {
+		close(running)
+		delete(r.running, key)
+	}
+}
+
+// stopAll cancels all the running ramps, used before reloads
+func (r *weightRamper) stopAll() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for key, running := range r.running {
+		close(running)
+		delete(r.running, key)
+	}
+}
+
+// parseTime converts an HAProxy time, eg 30s, to a duration
+func parseTime(t string) time.Duration {
+	if strings.HasSuffix(t, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(t, "d"))
+		if err != nil {
+			return 0
+		}
+		return time.Duration(days) * 24 * time.Hour
+	}
+	duration, err := time.ParseDuration(t)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestWeightRampStep(t *testing.T) {
+	testCases := []struct {
+		weight   int
+		expected []int
+	}{
+		// 0
+		{
+			weight:   100,
+			expected: []int{10, 20, 30, 40, 50, 60, 70, 80, 90, 100},
+		},
+		// 1
+		{
+			weight:   5,
+			expected: []int{1, 1, 1, 2, 2, 3, 3, 4, 4, 5},
+		},
+	}
+	for i, test := range testCases {
+		ramp := &weightRamp{weight: test.weight}
+		var actual []int
+		for step := 1; step <= rampSteps; step++ {
+			actual = append(actual, ramp.stepWeight(step))
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("weights differ on %d - expected: %v - actual: %v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestWeightRamper(t *testing.T) {
+	var mutex sync.Mutex
+	var cmds []string
+	ramper := newWeightRamper(&helper_test.LoggerMock{T: t})
+	ramper.cmd = func(socket string, commands ...string) ([]string, error) {
+		mutex.Lock()
+		cmds = append(cmds, commands...)
+		mutex.Unlock()
+		return nil, nil
+	}
+	ramper.start("/var/run/haproxy.sock", []*weightRamp{
+		{backname: "default_app_8080", server: "srv001", weight: 20, duration: 20 * time.Millisecond},
+		{backname: "default_app_8080", server: "srv002", weight: 20, duration: time.Hour},
+	})
+	ramper.stop("default_app_8080", "srv002")
+	for i := 0; i < 100; i++ {
+		ramper.mutex.Lock()
+		count := len(ramper.running)
+		ramper.mutex.Unlock()
+		if count == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	expected := []string{
+		"set server default_app_8080/srv001 weight 4",
+		"set server default_app_8080/srv001 weight 6",
+		"set server default_app_8080/srv001 weight 8",
+		"set server default_app_8080/srv001 weight 10",
+		"set server default_app_8080/srv001 weight 12",
+		"set server default_app_8080/srv001 weight 14",
+		"set server default_app_8080/srv001 weight 16",
+		"set server default_app_8080/srv001 weight 18",
+		"set server default_app_8080/srv001 weight 20",
+	}
+	if !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("commands differ - expected: %v - actual: %v", expected, cmds)
+	}
+}
+
+func TestWeightRamperStop(t *testing.T) {
+	var mutex sync.Mutex
+	var cmds []string
+	ramper := newWeightRamper(&helper_test.LoggerMock{T: t})
+	ramper.cmd = func(socket string, commands ...string) ([]string, error) {
+		// a slow admin socket
+		time.Sleep(2 * time.Millisecond)
+		mutex.Lock()
+		cmds = append(cmds, commands...)
+		mutex.Unlock()
+		return nil, nil
+	}
+	ramper.start("/var/run/haproxy.sock", []*weightRamp{
+		{backname: "default_app_8080", server: "srv001", weight: 20, duration: 10 * time.Millisecond},
+	})
+	time.Sleep(5 * time.Millisecond)
+	ramper.stop("default_app_8080", "srv001")
+	mutex.Lock()
+	count := len(cmds)
+	mutex.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(cmds) != count {
+		t.Errorf("commands sent after stop: %v", cmds[count:])
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	testCases := map[string]time.Duration{
+		"":      0,
+		"500ms": 500 * time.Millisecond,
+		"30s":   30 * time.Second,
+		"2m":    2 * time.Minute,
+		"1d":    24 * time.Hour,
+		"10":    0,
+	}
+	for input, expected := range testCases {
+		if actual := parseTime(input); actual != expected {
+			t.Errorf("duration of '%s' differs - expected: %v - actual: %v", input, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+)
+
+// Stats ...
+type Stats struct {
+	Info    map[string]string
+	Proxies []*ProxyStats
+}
+
+// ProxyStats has the `show stat` fields of a frontend, backend or server.
+// Backends and servers created from Kubernetes services have also the
+// namespace, service and port of the service, and the pod of the server.
+type ProxyStats struct {
+	Type      ProxyType
+	Proxy     string
+	Server    string
+	Namespace string
+	Service   string
+	Port      string
+	Pod       string
+	Fields    map[string]string
+}
+
+// ProxyType ...
+type ProxyType string
+
+// ...
+const (
+	ProxyFrontend ProxyType = "frontend"
+	ProxyBackend  ProxyType = "backend"
+	ProxyServer   ProxyType = "server"
+)
+
+type statsBackend struct {
+	namespace string
+	service   string
+	port      string
+	pods      map[string]string
+}
+
+// Stats reads the statistics of the running HAProxy from its admin socket,
+// using the last applied configuration to translate backend and server
+// names back to Kubernetes objects.
+func (i *instance) Stats() (*Stats, error) {
+	i.configMutex.Lock()
+	config := i.oldConfig
+	i.configMutex.Unlock()
+	if config == nil {
+		return nil, fmt.Errorf("HAProxy wasn't configured yet")
+	}
+	socket := config.Global().AdminSocket
+	info, err := utils.HAProxyCommandOutput(socket, "show info")
+	if err != nil {
+		return nil, err
+	}
+	stat, err := utils.HAProxyCommandOutput(socket, "show stat")
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := parseStat(stat)
+	if err != nil {
+		return nil, err
+	}
+	backends := statsBackends(config)
+	for _, proxy := range proxies {
+		if proxy.Type == ProxyFrontend {
+			continue
+		}
+		if backend, found := backends[proxy.Proxy]; found {
+			proxy.Namespace = backend.namespace
+			proxy.Service = backend.service
+			proxy.Port = backend.port
+			proxy.Pod = backend.pods[proxy.Server]
+		}
+	}
+	return &Stats{
+		Info:    parseInfo(info),
+		Proxies: proxies,
+	}, nil
+}
+
+func statsBackends(config Config) map[string]*statsBackend {
+	backends := map[string]*statsBackend{}
+	for _, backend := range config.Backends() {
+		b := &statsBackend{
+			namespace: backend.Namespace,
+			service:   backend.Name,
+			port:      backend.Port,
+			pods:      endpointPods(backend.Endpoints),
+		}
+		backends[backend.ID] = b
+	}
+	for _, backend := range config.TCPBackends() {
+		// TCP services are named as <namespace>_<service>, an underscore
+		// isn't a valid char of a namespace name
+		name := strings.SplitN(backend.Name, "_", 2)
+		b := &statsBackend{
+			port: strconv.Itoa(backend.Port),
+			pods: endpointPods(backend.Endpoints),
+		}
+		if len(name) == 2 {
+			b.namespace = name[0]
+			b.service = name[1]
+		}
+		backends[backend.BackendName()] = b
+	}
+	return backends
+}
+
+// endpointPods maps server names to the name of their pods
+func endpointPods(endpoints []*hatypes.Endpoint) map[string]string {
+	pods := make(map[string]string, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.TargetRef != "" {
+			pod := strings.Split(ep.TargetRef, "/")
+			pods[ep.Name] = pod[len(pod)-1]
+		}
+	}
+	return pods
+}
+
+// parseInfo parses the `name: value` lines of `show info`
+func parseInfo(info string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(info, "\n") {
+		if kv := strings.SplitN(line, ":", 2); len(kv) == 2 {
+			fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return fields
+}
+
+// parseStat parses the CSV output of `show stat`. The first line
+// is the header, prefixed with `# `. Listeners are ignored.
+func parseStat(stat string) ([]*ProxyStats, error) {
+	lines := strings.Split(strings.TrimSpace(stat), "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "# ") {
+		return nil, fmt.Errorf("invalid show stat header: %s", lines[0])
+	}
+	header := strings.Split(strings.TrimPrefix(lines[0], "# "), ",")
+	var proxies []*ProxyStats
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		values := strings.Split(line, ",")
+		if len(values) < 2 {
+			return nil, fmt.Errorf("invalid show stat line: %s", line)
+		}
+		fields := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(values) && name != "" {
+				fields[name] = values[i]
+			}
+		}
+		proxy := &ProxyStats{
+			Proxy:  fields["pxname"],
+			Fields: fields,
+		}
+		switch fields["svname"] {
+		case "FRONTEND":
+			proxy.Type = ProxyFrontend
+		case "BACKEND":
+			proxy.Type = ProxyBackend
+		default:
+			if fields["type"] != "2" {
+				// listeners
+				continue
+			}
+			proxy.Type = ProxyServer
+			proxy.Server = fields["svname"]
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies, nil
+}
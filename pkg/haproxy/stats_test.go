@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/diff"
+)
+
+const statsShowInfo = `Name: HAProxy
+Version: 1.9.8
+Uptime_sec: 120
+CurrConns: 5
+`
+
+const statsShowStat = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,
+_front_http,FRONTEND,,,2,10,2000,150,1000,2000,0,0,3,,,,,OPEN,,,,,,,,,1,2,0,,,,0,
+_front_http,http,,,2,10,2000,150,1000,2000,0,0,3,,,,,OPEN,,,,,,,,,1,2,1,,,,3,
+default_echo_8080,srv001,0,0,1,4,,80,500,900,,0,,0,0,0,0,UP,1,1,0,0,0,60,0,,1,3,1,,80,,2,
+default_echo_8080,srv002,0,0,0,0,,0,0,0,,0,,0,0,0,0,MAINT,0,1,0,0,0,60,60,,1,3,2,,0,,2,
+default_echo_8080,BACKEND,0,0,1,4,200,80,500,900,0,0,,0,0,0,0,UP,1,1,0,,0,60,0,,1,3,0,,80,,1,
+_tcp_default_db_5432,srv001,0,0,1,1,,3,10,20,,0,,0,0,0,0,UP,1,1,0,0,0,60,0,,1,4,1,,3,,2,
+_tcp_default_db_5432,BACKEND,0,0,1,1,200,3,10,20,0,0,,0,0,0,0,UP,1,1,0,,0,60,0,,1,4,0,,3,,1,
+`
+
+func TestStats(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	socket := c.tempdir + "/admin.sock"
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("error listening admin socket: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			cmd, _ := bufio.NewReader(conn).ReadString('\n')
+			switch strings.TrimSpace(cmd) {
+			case "show info":
+				conn.Write([]byte(statsShowInfo))
+			case "show stat":
+				conn.Write([]byte(statsShowStat))
+			}
+			conn.Close()
+		}
+	}()
+
+	instance := c.instance.(*instance)
+	if _, err := instance.Stats(); err == nil || err.Error() != "HAProxy wasn't configured yet" {
+		t.Errorf("expected error reading stats of a not configured instance, but was: %v", err)
+	}
+
+	c.config.Global().AdminSocket = socket
+	b := c.config.AcquireBackend("default", "echo", "8080")
+	b.AcquireEndpoint("172.17.0.11", 8080, "default/echo-xxx01")
+	b.AddEmptyEndpoint()
+	tb := c.config.AcquireTCPBackend("default_db", 5432)
+	tb.AcquireEndpoint("172.17.0.21", 5432, "default/db-0")
+	instance.clearConfig()
+
+	stats, err := instance.Stats()
+	if err != nil {
+		t.Fatalf("error reading stats: %v", err)
+	}
+	var actual []string
+	for _, proxy := range stats.Proxies {
+		actual = append(actual, fmt.Sprintf("%s proxy=%s server=%s namespace=%s service=%s port=%s pod=%s scur=%s stot=%s status=%s",
+			proxy.Type, proxy.Proxy, proxy.Server, proxy.Namespace, proxy.Service, proxy.Port, proxy.Pod,
+			proxy.Fields["scur"], proxy.Fields["stot"], proxy.Fields["status"]))
+	}
+	var info []string
+	for name, value := range stats.Info {
+		info = append(info, name+"="+value)
+	}
+	sort.Strings(info)
+	actual = append(actual, "info "+strings.Join(info, " "))
+	expected := `
+frontend proxy=_front_http server= namespace= service= port= pod= scur=2 stot=150 status=OPEN
+server proxy=default_echo_8080 server=srv001 namespace=default service=echo port=8080 pod=echo-xxx01 scur=1 stot=80 status=UP
+server proxy=default_echo_8080 server=srv002 namespace=default service=echo port=8080 pod= scur=0 stot=0 status=MAINT
+backend proxy=default_echo_8080 server= namespace=default service=echo port=8080 pod= scur=1 stot=80 status=UP
+server proxy=_tcp_default_db_5432 server=srv001 namespace=default service=db port=5432 pod=db-0 scur=1 stot=3 status=UP
+backend proxy=_tcp_default_db_5432 server= namespace=default service=db port=5432 pod= scur=1 stot=3 status=UP
+info CurrConns=5 Name=HAProxy Uptime_sec=120 Version=1.9.8`
+	if out := strings.Join(actual, "\n"); out != strings.TrimSpace(expected) {
+		t.Errorf("stats differ:\n%s", diff.Diff(strings.TrimSpace(expected), out))
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// Storages ...
+func (acme *AcmeData) Storages() *acmeStorages {
+	return &acme.storages
+}
+
+// Acquire ...
+func (c *acmeStorages) Acquire(name string) *AcmeCerts {
+	if c.items == nil {
+		c.items = map[string]*AcmeCerts{}
+	}
+	storage, found := c.items[name]
+	if !found {
+		storage = &AcmeCerts{
+			dnsnames: map[string]bool{},
+		}
+		c.items[name] = storage
+	}
+	return storage
+}
+
+// BuildAcmeStorages builds a sorted list of certificates to be signed,
+// each item in the format: `<secret-name>,<dnsname1>[,<dnsname2>...]`
+func (c *acmeStorages) BuildAcmeStorages() []string {
+	storages := make([]string, 0, len(c.items))
+	for name, certs := range c.items {
+		if len(certs.dnsnames) == 0 {
+			continue
+		}
+		dnsnames := make([]string, 0, len(certs.dnsnames))
+		for dnsname := range certs.dnsnames {
+			dnsnames = append(dnsnames, dnsname)
+		}
+		sort.Strings(dnsnames)
+		storages = append(storages, name+","+strings.Join(dnsnames, ","))
+	}
+	sort.Strings(storages)
+	return storages
+}
+
+// AddDomains ...
+func (c *AcmeCerts) AddDomains(domains []string) {
+	for _, domain := range domains {
+		if domain != "" {
+			c.dnsnames[domain] = true
+		}
+	}
+}
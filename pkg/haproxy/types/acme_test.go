@@ -0,0 +1,76 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildAcmeStorages(t *testing.T) {
+	testCases := []struct {
+		certs    [][]string
+		expected []string
+	}{
+		// 0
+		{
+			certs:    [][]string{},
+			expected: []string{},
+		},
+		// 1
+		{
+			certs: [][]string{
+				{"cert1", "d1.local"},
+			},
+			expected: []string{"cert1,d1.local"},
+		},
+		// 2
+		{
+			certs: [][]string{
+				{"cert1", "d1.local", "d2.local"},
+				{"cert1", "d2.local", "d3.local"},
+			},
+			expected: []string{"cert1,d1.local,d2.local,d3.local"},
+		},
+		// 3
+		{
+			certs: [][]string{
+				{"cert2", "d2.local"},
+				{"cert1", "d1.local"},
+			},
+			expected: []string{"cert1,d1.local", "cert2,d2.local"},
+		},
+		// 4
+		{
+			certs: [][]string{
+				{"cert1", ""},
+				{"cert2", "d2.local"},
+			},
+			expected: []string{"cert2,d2.local"},
+		},
+	}
+	for i, test := range testCases {
+		acme := AcmeData{}
+		for _, cert := range test.certs {
+			acme.Storages().Acquire(cert[0]).AddDomains(cert[1:])
+		}
+		storages := acme.Storages().BuildAcmeStorages()
+		if !reflect.DeepEqual(storages, test.expected) {
+			t.Errorf("acme certs differ on %d: expected '%+v' but was '%+v'", i, test.expected, storages)
+		}
+	}
+}
@@ -219,11 +219,23 @@ func (b *Backend) MaxBodySizeHostpath(hostpath string) int64 {
 	return 0
 }
 
+// TLSCertRequiredHostpath ...
+func (b *Backend) TLSCertRequiredHostpath(hostpath string) bool {
+	for _, authtls := range b.AuthTLS {
+		for _, path := range authtls.Paths.Items {
+			if path.Hostpath == hostpath {
+				return authtls.Config.CertRequired
+			}
+		}
+	}
+	return false
+}
+
 // NeedACL ...
 func (b *Backend) NeedACL() bool {
 	return len(b.HSTS) > 1 ||
 		len(b.MaxBodySize) > 1 || len(b.RewriteURL) > 1 || len(b.WhitelistHTTP) > 1 ||
-		len(b.Cors) > 1 || len(b.AuthHTTP) > 1 || len(b.WAF) > 1
+		len(b.Cors) > 1 || len(b.AuthHTTP) > 1 || len(b.AuthTLS) > 1 || len(b.WAF) > 1
 }
 
 // IsEmpty ...
@@ -231,6 +243,30 @@ func (ep *Endpoint) IsEmpty() bool {
 	return ep.IP == "127.0.0.1"
 }
 
+// HasBackup ...
+func (b *Backend) HasBackup() bool {
+	return hasBackup(b.Endpoints)
+}
+
+func hasBackup(endpoints []*Endpoint) bool {
+	for _, ep := range endpoints {
+		if ep.Backup {
+			return true
+		}
+	}
+	return false
+}
+
+// InZone ...
+func (ep *Endpoint) InZone(zone string) bool {
+	for _, z := range ep.Zones {
+		if z == zone {
+			return true
+		}
+	}
+	return false
+}
+
 // IDList ...
 func (p *BackendPaths) IDList() string {
 	ids := make([]string, len(p.Items))
@@ -259,8 +295,8 @@ func (b *TCPBackend) String() string {
 }
 
 // String ...
-func (ep *TCPEndpoint) String() string {
-	return fmt.Sprintf("%+v", *ep)
+func (f *TCPFrontend) String() string {
+	return fmt.Sprintf("%+v", *f)
 }
 
 // String ...
@@ -273,6 +309,11 @@ func (b *BackendConfigAuth) String() string {
 	return fmt.Sprintf("%+v", *b)
 }
 
+// String ...
+func (b *BackendConfigAuthTLS) String() string {
+	return fmt.Sprintf("%+v", *b)
+}
+
 // String ...
 func (b *BackendConfigBool) String() string {
 	return fmt.Sprintf("%+v", *b)
@@ -86,6 +86,14 @@ func (hm *HostsMap) AppendPath(hostpath, id string) {
 	}
 }
 
+// AppendItem adds a generic item to the map, as is and preserving the order
+func (hm *HostsMap) AppendItem(key, value string) {
+	hm.Match = append(hm.Match, &HostsMapEntry{
+		Key:   key,
+		Value: value,
+	})
+}
+
 // HasRegex ...
 func (hm *HostsMap) HasRegex() bool {
 	return len(hm.Regex) > 0
@@ -170,7 +178,7 @@ func (f *Frontend) HasTLSMandatory() bool {
 			return true
 		}
 	}
-	return false
+	return f.TLSNeedCrtPathsMap.HasHost()
 }
 
 // HasMaxBody ...
@@ -259,7 +267,8 @@ func NewFrontend(host *Host) *Frontend {
 		return &Frontend{}
 	}
 	return &Frontend{
-		Timeout: host.Timeout,
+		LogFormat: host.LogFormat,
+		Timeout:   host.Timeout,
 	}
 }
 
@@ -270,8 +279,10 @@ func NewFrontendBind(host *Host) *BindConfig {
 	}
 	return &BindConfig{
 		TLS: BindTLSConfig{
-			CAFilename: host.TLS.CAFilename,
-			CAHash:     host.TLS.CAHash,
+			CAFilename:  host.TLS.CAFilename,
+			CAHash:      host.TLS.CAHash,
+			CRLFilename: host.TLS.CRLFilename,
+			CRLHash:     host.TLS.CRLHash,
 		},
 	}
 }
@@ -280,11 +291,11 @@ func (f *Frontend) match(host *Host) bool {
 	if len(f.Hosts) == 0 {
 		return true
 	}
-	return reflect.DeepEqual(f.Timeout, host.Timeout)
+	return f.LogFormat == host.LogFormat && reflect.DeepEqual(f.Timeout, host.Timeout)
 }
 
 func (b *BindConfig) match(host *Host) bool {
-	return b.TLS.CAHash == host.TLS.CAHash
+	return b.TLS.CAHash == host.TLS.CAHash && b.TLS.CRLHash == host.TLS.CRLHash
 }
 
 func (b *BindConfig) supportDefault() bool {
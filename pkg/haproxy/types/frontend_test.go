@@ -84,6 +84,7 @@ func TestBuildFrontend(t *testing.T) {
 	h10CA1_1 := &Host{Hostname: "h4.local", Timeout: timeout10, TLS: ca1}
 	h10CA2_1 := &Host{Hostname: "h5.local", Timeout: timeout10, TLS: ca2}
 	h10CA2_2 := &Host{Hostname: "h6.local", Timeout: timeout10, TLS: ca2}
+	h10Log_1 := &Host{Hostname: "h7.local", Timeout: timeout10, LogFormat: "%ci"}
 	testCases := []struct {
 		hosts    []*Host
 		expected []*Frontend
@@ -172,6 +173,33 @@ func TestBuildFrontend(t *testing.T) {
 				},
 			},
 		},
+		// 4
+		{
+			hosts: []*Host{h10_1, h10Log_1, h10_2},
+			expected: []*Frontend{
+				{
+					Name:    "_front001",
+					Timeout: timeout10,
+					Hosts:   []*Host{h10_1, h10_2},
+					Binds: []*BindConfig{
+						&BindConfig{
+							Hosts: []*Host{h10_1, h10_2},
+						},
+					},
+				},
+				{
+					Name:      "_front002",
+					LogFormat: "%ci",
+					Timeout:   timeout10,
+					Hosts:     []*Host{h10Log_1},
+					Binds: []*BindConfig{
+						&BindConfig{
+							Hosts: []*Host{h10Log_1},
+						},
+					},
+				},
+			},
+		},
 	}
 	for i, test := range testCases {
 		frontends, _, _ := BuildRawFrontends(test.hosts)
@@ -19,6 +19,7 @@ package types
 import (
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // FindPath ...
@@ -69,3 +70,56 @@ func (h *Host) String() string {
 func (h *HostTLSConfig) HasTLS() bool {
 	return h.TLSFilename != ""
 }
+
+// AddTLSExtraCert adds another certificate to the host, eg an ECDSA
+// certificate to a host that already has a RSA one. Certificates
+// already assigned to the host are ignored.
+func (h *HostTLSConfig) AddTLSExtraCert(filename, hash string) {
+	if hash == h.TLSHash {
+		return
+	}
+	for _, crt := range h.TLSExtraCerts {
+		if crt.Hash == hash {
+			return
+		}
+	}
+	h.TLSExtraCerts = append(h.TLSExtraCerts, HostTLSCert{
+		Filename: filename,
+		Hash:     hash,
+	})
+}
+
+// CrtListOptions returns the TLS policy of the host as crt-list
+// ssl options, or an empty string if the global policy should be used.
+func (h *HostTLSConfig) CrtListOptions() string {
+	var options []string
+	if h.ALPN != "" {
+		options = append(options, "alpn "+h.ALPN)
+	}
+	if h.Ciphers != "" {
+		options = append(options, "ciphers "+h.Ciphers)
+	}
+	if h.CipherSuites != "" {
+		options = append(options, "ciphersuites "+h.CipherSuites)
+	}
+	if h.MinVersion != "" {
+		options = append(options, "ssl-min-ver "+h.MinVersion)
+	}
+	if h.MaxVersion != "" {
+		options = append(options, "ssl-max-ver "+h.MaxVersion)
+	}
+	return strings.Join(options, " ")
+}
+
+// TLSFilenames returns the main certificate followed by the extra ones
+func (h *HostTLSConfig) TLSFilenames() []string {
+	if h.TLSFilename == "" {
+		return nil
+	}
+	filenames := make([]string, 0, len(h.TLSExtraCerts)+1)
+	filenames = append(filenames, h.TLSFilename)
+	for _, crt := range h.TLSExtraCerts {
+		filenames = append(filenames, crt.Filename)
+	}
+	return filenames
+}
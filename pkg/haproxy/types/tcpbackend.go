@@ -20,14 +20,47 @@ import (
 	"fmt"
 )
 
+// AcquireEndpoint ...
+func (b *TCPBackend) AcquireEndpoint(ip string, port int, targetRef string) *Endpoint {
+	target := fmt.Sprintf("%s:%d", ip, port)
+	for _, ep := range b.Endpoints {
+		if ep.Target == target {
+			return ep
+		}
+	}
+	ep := b.AddEndpoint(ip, port)
+	ep.TargetRef = targetRef
+	return ep
+}
+
 // AddEndpoint ...
-func (b *TCPBackend) AddEndpoint(ip string, port int) *TCPEndpoint {
-	ep := &TCPEndpoint{
-		Name:   fmt.Sprintf("srv%03d", len(b.Endpoints)+1),
-		IP:     ip,
-		Port:   port,
-		Target: fmt.Sprintf("%s:%d", ip, port),
+func (b *TCPBackend) AddEndpoint(ip string, port int) *Endpoint {
+	ep := &Endpoint{
+		Name:    fmt.Sprintf("srv%03d", len(b.Endpoints)+1),
+		IP:      ip,
+		Port:    port,
+		Target:  fmt.Sprintf("%s:%d", ip, port),
+		Enabled: true,
+		Weight:  1,
 	}
 	b.Endpoints = append(b.Endpoints, ep)
 	return ep
 }
+
+// AddEmptyEndpoint ...
+func (b *TCPBackend) AddEmptyEndpoint() *Endpoint {
+	ep := b.AddEndpoint("127.0.0.1", 1023)
+	ep.Enabled = false
+	ep.Weight = 0
+	return ep
+}
+
+// HasBackup ...
+func (b *TCPBackend) HasBackup() bool {
+	return hasBackup(b.Endpoints)
+}
+
+// BackendName ...
+func (b *TCPBackend) BackendName() string {
+	return fmt.Sprintf("_tcp_%s_%d", b.Name, b.Port)
+}
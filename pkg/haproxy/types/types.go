@@ -16,8 +16,31 @@ limitations under the License.
 
 package types
 
+import (
+	"time"
+)
+
+// AcmeData ...
+type AcmeData struct {
+	Emails      string
+	Endpoint    string
+	Expiring    time.Duration
+	TermsAgreed bool
+	storages    acmeStorages
+}
+
+type acmeStorages struct {
+	items map[string]*AcmeCerts
+}
+
+// AcmeCerts ...
+type AcmeCerts struct {
+	dnsnames map[string]bool
+}
+
 // Global ...
 type Global struct {
+	Acme            AcmeConfig
 	Bind            GlobalBindConfig
 	Procs           ProcsConfig
 	Syslog          SyslogConfig
@@ -29,6 +52,7 @@ type Global struct {
 	Cookie          CookieConfig
 	DrainSupport    DrainConfig
 	ForwardFor      string
+	RequestID       RequestIDConfig
 	LoadServerState bool
 	AdminSocket     string
 	Healthz         HealthzConfig
@@ -39,6 +63,20 @@ type Global struct {
 	CustomFrontend  []string
 }
 
+// RequestIDConfig ...
+type RequestIDConfig struct {
+	Format          string
+	TrustedNetworks []string
+}
+
+// AcmeConfig ...
+type AcmeConfig struct {
+	Enabled bool
+	Prefix  string
+	Shared  bool
+	Socket  string
+}
+
 // GlobalBindConfig ...
 type GlobalBindConfig struct {
 	AcceptProxy    bool
@@ -70,6 +108,10 @@ type SyslogConfig struct {
 	TCPLogFormat   string
 }
 
+// SyslogStdoutSocket is the local socket HAProxy logs to when the syslog
+// endpoint is `stdout`, the controller forwards its messages to its own stdout.
+const SyslogStdoutSocket = "/var/run/haproxy-log.sock"
+
 // TimeoutConfig ...
 type TimeoutConfig struct {
 	HostTimeoutConfig
@@ -82,7 +124,10 @@ type SSLConfig struct {
 	ALPN          string
 	DHParam       DHParamConfig
 	Ciphers       string
+	CipherSuites  string
 	Options       string
+	MaxVersion    string
+	MinVersion    string
 	Engine        string
 	ModeAsync     bool
 	HeadersPrefix string
@@ -158,25 +203,55 @@ type ModSecurityTimeoutConfig struct {
 
 // TCPBackend ...
 type TCPBackend struct {
-	Name          string
-	Port          int
-	Endpoints     []*TCPEndpoint
-	CheckInterval string
-	SSL           TCPSSL
-	ProxyProt     TCPProxyProt
+	Name             string
+	Port             int
+	SNI              string
+	Endpoints        []*Endpoint
+	BalanceAlgorithm string
+	CheckInterval    string
+	Dynamic          DynBackendConfig
+	Fallback         string
+	MaxConnServer    int
+	SSL              TCPSSL
+	ServerSSL        TCPServerSSL
+	ProxyProt        TCPProxyProt
+	Timeout          TCPTimeoutConfig
+	Whitelist        []string
 }
 
-// TCPEndpoint ...
-type TCPEndpoint struct {
-	Name   string
-	IP     string
-	Port   int
-	Target string
+// TCPFrontend ...
+type TCPFrontend struct {
+	Port          int
+	AcceptProxy   bool
+	TimeoutClient string
+	//
+	Maps           *HostsMaps
+	SNIBackendsMap *HostsMap
 }
 
 // TCPSSL ...
 type TCPSSL struct {
-	Filename string
+	Filename         string
+	ALPN             string
+	CAFilename       string
+	CAHash           string
+	CAVerifyOptional bool
+	Ciphers          string // TLS up to 1.2
+	CipherSuites     string // TLS 1.3
+	CRLFilename      string
+	CRLHash          string
+	MaxVersion       string
+	MinVersion       string
+}
+
+// TCPServerSSL ...
+type TCPServerSSL struct {
+	Enabled     bool
+	CAFilename  string
+	CAHash      string
+	CrtFilename string
+	CrtHash     string
+	SNI         string
 }
 
 // TCPProxyProt ...
@@ -185,6 +260,13 @@ type TCPProxyProt struct {
 	EncodeVersion string
 }
 
+// TCPTimeoutConfig ...
+type TCPTimeoutConfig struct {
+	Client  string
+	Connect string
+	Server  string
+}
+
 // HostsMapEntry ...
 type HostsMapEntry struct {
 	Key   string
@@ -216,7 +298,10 @@ type FrontendGroup struct {
 	HTTPFrontsMap     *HostsMap
 	HTTPRootRedirMap  *HostsMap
 	HTTPSRedirMap     *HostsMap
+	NoAccessLogMap    *HostsMap
+	RequestIDMap      *HostsMap
 	SSLPassthroughMap *HostsMap
+	VarIngressMap     *HostsMap
 	VarNamespaceMap   *HostsMap
 }
 
@@ -226,7 +311,8 @@ type Frontend struct {
 	Binds []*BindConfig
 	Hosts []*Host
 	//
-	Timeout HostTimeoutConfig
+	LogFormat string
+	Timeout   HostTimeoutConfig
 	//
 	Maps                       *HostsMaps
 	HostBackendsMap            *HostsMap
@@ -235,6 +321,7 @@ type Frontend struct {
 	SNIBackendsMap             *HostsMap
 	TLSInvalidCrtErrorList     *HostsMap
 	TLSInvalidCrtErrorPagesMap *HostsMap
+	TLSNeedCrtPathsMap         *HostsMap
 	TLSNoCrtErrorList          *HostsMap
 	TLSNoCrtErrorPagesMap      *HostsMap
 }
@@ -255,11 +342,13 @@ type BindConfig struct {
 
 // BindTLSConfig ...
 type BindTLSConfig struct {
-	ALPN       string
-	CAFilename string
-	CAHash     string
-	TLSCert    string
-	TLSCertDir string
+	ALPN        string
+	CAFilename  string
+	CAHash      string
+	CRLFilename string
+	CRLHash     string
+	TLSCert     string
+	CrtList     *HostsMap
 }
 
 // Host ...
@@ -274,6 +363,9 @@ type Host struct {
 	//
 	Alias                  HostAliasConfig
 	HTTPPassthroughBackend string
+	LogFormat              string
+	NoAccessLog            bool
+	RequestID              string
 	RootRedirect           string
 	SSLPassthrough         bool
 	Timeout                HostTimeoutConfig
@@ -290,6 +382,7 @@ type Host struct {
 type HostPath struct {
 	Path    string
 	Backend HostBackend
+	Ingress string
 }
 
 // HostBackend ...
@@ -314,12 +407,26 @@ type HostTimeoutConfig struct {
 
 // HostTLSConfig ...
 type HostTLSConfig struct {
+	ALPN             string
 	CAErrorPage      string
 	CAFilename       string
 	CAHash           string
 	CAVerifyOptional bool
+	Ciphers          string
+	CRLFilename      string
+	CRLHash          string
+	CipherSuites     string
+	MaxVersion       string
+	MinVersion       string
 	TLSFilename      string
 	TLSHash          string
+	TLSExtraCerts    []HostTLSCert
+}
+
+// HostTLSCert ...
+type HostTLSCert struct {
+	Filename string
+	Hash     string
 }
 
 // EndpointNaming ...
@@ -353,11 +460,13 @@ type Backend struct {
 	Cookie           Cookie
 	CustomConfig     []string
 	Dynamic          DynBackendConfig
+	Fallback         string
 	HealthCheck      HealthCheck
 	Limit            BackendLimit
 	ModeTCP          bool
 	OAuth            OAuthConfig
 	Resolver         string
+	Retry            BackendRetry
 	Server           ServerConfig
 	Timeout          BackendTimeoutConfig
 	TLS              BackendTLSConfig
@@ -385,6 +494,7 @@ type Backend struct {
 	//      has two or more paths, and so need to be configured with ACL.
 	//
 	AuthHTTP      []*BackendConfigAuth
+	AuthTLS       []*BackendConfigAuthTLS
 	Cors          []*BackendConfigCors
 	HSTS          []*BackendConfigHSTS
 	MaxBodySize   []*BackendConfigInt
@@ -396,6 +506,7 @@ type Backend struct {
 
 // Endpoint ...
 type Endpoint struct {
+	Backup    bool
 	Enabled   bool
 	IP        string
 	Name      string
@@ -403,6 +514,7 @@ type Endpoint struct {
 	Target    string
 	TargetRef string
 	Weight    int
+	Zones     []string
 }
 
 // BackendPaths ...
@@ -443,6 +555,12 @@ type BackendConfigAuth struct {
 	Realm        string
 }
 
+// BackendConfigAuthTLS ...
+type BackendConfigAuthTLS struct {
+	Paths  BackendPaths
+	Config AuthTLS
+}
+
 // BackendConfigCors ...
 type BackendConfigCors struct {
 	Paths  BackendPaths
@@ -470,20 +588,38 @@ type AgentCheck struct {
 }
 
 // DynBackendConfig ...
+//
+// BackupSlots, if true, also keeps empty slots of backup servers, so
+// endpoints can be dynamically added or moved to the backup state.
 type DynBackendConfig struct {
+	BackupSlots  bool
 	BlockSize    int
+	DrainTimeout string
 	DynUpdate    bool
 	MinFreeSlots int
+	MinSlots     int
 }
 
 // HealthCheck ...
+//
+// ExpectStatus is a regex of the expected status codes and ExpectBody is
+// a string with escaped spaces, quotes and comment chars, both ready to be
+// used by `http-check expect`.
+// SSL and SNI are only used on secure backends.
 type HealthCheck struct {
-	Addr      string
-	FallCount int
-	Interval  string
-	Port      int
-	RiseCount int
-	URI       string
+	Addr         string
+	DownInterval string
+	ExpectBody   string
+	ExpectStatus string
+	FallCount    int
+	Host         string
+	Interval     string
+	Method       string
+	Port         int
+	RiseCount    int
+	SNI          string
+	SSL          bool
+	URI          string
 }
 
 // BackendLimit ...
@@ -501,6 +637,12 @@ type OAuthConfig struct {
 	Headers     map[string]string
 }
 
+// BackendRetry ...
+type BackendRetry struct {
+	Count      string
+	Redispatch string
+}
+
 // ServerConfig ...
 type ServerConfig struct {
 	CAFilename    string
@@ -511,13 +653,17 @@ type ServerConfig struct {
 	CRLHash       string
 	CrtFilename   string
 	CrtHash       string
+	ErrorLimit    int
 	InitialWeight int
 	MaxConn       int
 	MaxQueue      int
+	Observe       string
+	OnError       string
 	Options       string
 	Protocol      string
 	Secure        bool
 	SendProxy     string
+	SlowStart     string
 }
 
 // BackendTimeoutConfig ...
@@ -551,6 +697,13 @@ type Cookie struct {
 	Strategy string
 }
 
+// AuthTLS ...
+type AuthTLS struct {
+	CertRequired bool
+	MatchCN      []string
+	MatchOU      []string
+}
+
 // Cors ...
 type Cors struct {
 	Enabled bool
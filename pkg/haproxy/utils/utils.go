@@ -18,7 +18,9 @@ package utils
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"time"
 )
 
 // HAProxyCommand ...
@@ -45,3 +47,22 @@ func HAProxyCommand(socket string, command ...string) ([]string, error) {
 	}
 	return msg, nil
 }
+
+// HAProxyCommandOutput sends a single command to the admin socket and
+// returns its whole response, eg the output of `show stat`.
+func HAProxyCommandOutput(socket, command string) (string, error) {
+	c, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to unix socket %s: %v", socket, err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.Write([]byte(command + "\n")); err != nil {
+		return "", fmt.Errorf("error sending to unix socket %s: %v", socket, err)
+	}
+	out, err := ioutil.ReadAll(c)
+	if err != nil {
+		return "", fmt.Errorf("error reading from unix socket %s: %v", socket, err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper_test
+
+import (
+	"fmt"
+)
+
+// MetricsMock ...
+type MetricsMock struct {
+	Updates       []string
+	ConfigObjects string
+}
+
+// NewMetricsMock ...
+func NewMetricsMock() *MetricsMock {
+	return &MetricsMock{}
+}
+
+// IncUpdateNoop ...
+func (m *MetricsMock) IncUpdateNoop() {
+	m.Updates = append(m.Updates, "noop")
+}
+
+// IncUpdateDynamic ...
+func (m *MetricsMock) IncUpdateDynamic(commands int) {
+	m.Updates = append(m.Updates, fmt.Sprintf("dynamic commands=%d", commands))
+}
+
+// IncUpdateReload ...
+func (m *MetricsMock) IncUpdateReload(reason string) {
+	m.Updates = append(m.Updates, fmt.Sprintf("reload reason=%s", reason))
+}
+
+// IncReloadError ...
+func (m *MetricsMock) IncReloadError() {
+	m.Updates = append(m.Updates, "reload-error")
+}
+
+// IncValidationError ...
+func (m *MetricsMock) IncValidationError() {
+	m.Updates = append(m.Updates, "validation-error")
+}
+
+// SetConfigObjects ...
+func (m *MetricsMock) SetConfigObjects(hosts, backends, tcpServices, endpoints int) {
+	m.ConfigObjects = fmt.Sprintf("hosts=%d backends=%d tcpServices=%d endpoints=%d",
+		hosts, backends, tcpServices, endpoints)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// SyslogForwarder ...
+type SyslogForwarder interface {
+	Listen(stopCh chan struct{}) error
+}
+
+// NewSyslogForwarder creates a forwarder of the rfc5424 messages
+// received on a unix datagram socket. The syslog header is removed
+// and the message is written to out, one message per line.
+func NewSyslogForwarder(logger types.Logger, socket string, out io.Writer) SyslogForwarder {
+	return &syslogForwarder{
+		logger: logger,
+		socket: socket,
+		out:    out,
+	}
+}
+
+type syslogForwarder struct {
+	logger types.Logger
+	socket string
+	out    io.Writer
+}
+
+func (f *syslogForwarder) Listen(stopCh chan struct{}) error {
+	f.logger.Info("starting log forwarder on %s", f.socket)
+	if err := os.Remove(f.socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: f.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				select {
+				case <-stopCh:
+				default:
+					f.logger.Error("error reading log socket: %v", err)
+				}
+				return
+			}
+			f.out.Write([]byte(syslogMessage(string(buf[:n])) + "\n"))
+		}
+	}()
+	go func() {
+		<-stopCh
+		f.logger.Info("closing log forwarder")
+		conn.Close()
+	}()
+	return nil
+}
+
+// syslogMessage removes the header of a rfc5424 message:
+// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func syslogMessage(msg string) string {
+	msg = strings.TrimRight(msg, "\n")
+	if !strings.HasPrefix(msg, "<") {
+		return msg
+	}
+	fields := strings.SplitN(msg, " ", 8)
+	if len(fields) < 8 {
+		return msg
+	}
+	return fields[7]
+}
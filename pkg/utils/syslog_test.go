@@ -0,0 +1,54 @@
+/*
+Copyright 2019 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestSyslogMessage(t *testing.T) {
+	testCases := []struct {
+		msg      string
+		expected string
+	}{
+		// 0
+		{
+			msg:      `<134>1 2019-10-18T10:00:00+00:00 - ingress 15 - - {"status":200}` + "\n",
+			expected: `{"status":200}`,
+		},
+		// 1
+		{
+			msg:      `<134>1 2019-10-18T10:00:00+00:00 haproxy ingress 15 - - 10.0.0.1:5000 [18/Oct/2019:10:00:00.000] _front_http`,
+			expected: `10.0.0.1:5000 [18/Oct/2019:10:00:00.000] _front_http`,
+		},
+		// 2
+		{
+			msg:      `plain message`,
+			expected: `plain message`,
+		},
+		// 3
+		{
+			msg:      `<134>1 incomplete header`,
+			expected: `<134>1 incomplete header`,
+		},
+	}
+	for i, test := range testCases {
+		if actual := syslogMessage(test.msg); actual != test.expected {
+			t.Errorf("message differs on %d - expected: %s - actual: %s", i, test.expected, actual)
+		}
+	}
+}